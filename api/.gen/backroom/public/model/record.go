@@ -9,10 +9,15 @@ package model
 
 import (
 	"github.com/octacian/backroom/api/db"
+	"time"
 )
 
 type Record struct {
-	UUID db.UUID `sql:"primary_key"`
-	Cage string
-	Data db.JSONB
+	UUID      db.UUID `sql:"primary_key"`
+	Cage      string
+	Data      db.JSONB
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time
+	Version   int32
 }
@@ -17,9 +17,13 @@ type recordTable struct {
 	postgres.Table
 
 	// Columns
-	UUID postgres.ColumnString
-	Cage postgres.ColumnString
-	Data postgres.ColumnString
+	UUID      postgres.ColumnString
+	Cage      postgres.ColumnString
+	Data      postgres.ColumnString
+	CreatedAt postgres.ColumnTimestampz
+	UpdatedAt postgres.ColumnTimestampz
+	DeletedAt postgres.ColumnTimestampz
+	Version   postgres.ColumnInteger
 
 	AllColumns     postgres.ColumnList
 	MutableColumns postgres.ColumnList
@@ -61,21 +65,29 @@ func newRecordTable(schemaName, tableName, alias string) *RecordTable {
 
 func newRecordTableImpl(schemaName, tableName, alias string) recordTable {
 	var (
-		UUIDColumn     = postgres.StringColumn("uuid")
-		CageColumn     = postgres.StringColumn("cage")
-		DataColumn     = postgres.StringColumn("data")
-		allColumns     = postgres.ColumnList{UUIDColumn, CageColumn, DataColumn}
-		mutableColumns = postgres.ColumnList{CageColumn, DataColumn}
-		defaultColumns = postgres.ColumnList{}
+		UUIDColumn      = postgres.StringColumn("uuid")
+		CageColumn      = postgres.StringColumn("cage")
+		DataColumn      = postgres.StringColumn("data")
+		CreatedAtColumn = postgres.TimestampzColumn("created_at")
+		UpdatedAtColumn = postgres.TimestampzColumn("updated_at")
+		DeletedAtColumn = postgres.TimestampzColumn("deleted_at")
+		VersionColumn   = postgres.IntegerColumn("version")
+		allColumns      = postgres.ColumnList{UUIDColumn, CageColumn, DataColumn, CreatedAtColumn, UpdatedAtColumn, DeletedAtColumn, VersionColumn}
+		mutableColumns  = postgres.ColumnList{CageColumn, DataColumn, CreatedAtColumn, UpdatedAtColumn, DeletedAtColumn, VersionColumn}
+		defaultColumns  = postgres.ColumnList{CreatedAtColumn, UpdatedAtColumn, VersionColumn}
 	)
 
 	return recordTable{
 		Table: postgres.NewTable(schemaName, tableName, alias, allColumns...),
 
 		//Columns
-		UUID: UUIDColumn,
-		Cage: CageColumn,
-		Data: DataColumn,
+		UUID:      UUIDColumn,
+		Cage:      CageColumn,
+		Data:      DataColumn,
+		CreatedAt: CreatedAtColumn,
+		UpdatedAt: UpdatedAtColumn,
+		DeletedAt: DeletedAtColumn,
+		Version:   VersionColumn,
 
 		AllColumns:     allColumns,
 		MutableColumns: mutableColumns,
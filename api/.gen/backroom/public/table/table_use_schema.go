@@ -10,6 +10,12 @@ package table
 // UseSchema sets a new schema name for all generated table SQL builder types. It is recommended to invoke
 // this method only once at the beginning of the program.
 func UseSchema(schema string) {
+	APIKey = APIKey.FromSchema(schema)
 	GooseDbVersion = GooseDbVersion.FromSchema(schema)
+	HookOutbox = HookOutbox.FromSchema(schema)
+	HookRun = HookRun.FromSchema(schema)
+	IdempotencyKey = IdempotencyKey.FromSchema(schema)
 	Record = Record.FromSchema(schema)
+	RecordEvent = RecordEvent.FromSchema(schema)
+	RecordRevision = RecordRevision.FromSchema(schema)
 }
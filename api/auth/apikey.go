@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/db"
+)
+
+var (
+	ErrInvalidKey  = errors.New("invalid api key")
+	ErrKeyNotFound = errors.New("api key not found")
+)
+
+// keyPrefix is prepended to generated keys, making them easy to recognise.
+const keyPrefix = "br_"
+
+// APIKey is a named credential granting a set of scopes. Only a hash of the
+// secret key is stored.
+// Wraps generated model.APIKey type.
+type APIKey model.APIKey
+
+// NewAPIKey returns a new API key with the given name and scopes, along with
+// its secret. The secret cannot be recovered once discarded.
+func NewAPIKey(name string, scopes []string) (*APIKey, string, error) {
+	for _, scope := range scopes {
+		if err := ValidateScope(scope); err != nil {
+			return nil, "", err
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	secret := keyPrefix + hex.EncodeToString(buf)
+
+	return &APIKey{
+		UUID:   db.NewUUID(),
+		Name:   name,
+		Hash:   HashKey(secret),
+		Scopes: strings.Join(scopes, " "),
+	}, secret, nil
+}
+
+// HashKey returns the stored representation of a secret key.
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// ScopeList returns the scopes granted by the key.
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// CreateAPIKey stores a new API key in the database.
+func CreateAPIKey(key *APIKey) error {
+	return store.InsertAPIKey(db.SQLDB, key)
+}
+
+// ListAPIKeys retrieves all API keys, including revoked keys, from the database.
+func ListAPIKeys() ([]*APIKey, error) {
+	return store.ListAPIKeys(db.SQLDB)
+}
+
+// RevokeAPIKey revokes the active API key with the given name.
+// Returns ErrKeyNotFound if there is no such key.
+func RevokeAPIKey(name string) error {
+	count, err := store.RevokeAPIKey(db.SQLDB, name)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrKeyNotFound
+	}
+
+	return nil
+}
+
+// Authenticate retrieves the active API key matching a secret and records
+// that it was used. Returns ErrInvalidKey if no active key matches.
+func Authenticate(secret string) (*APIKey, error) {
+	return store.UseAPIKey(db.SQLDB, HashKey(secret))
+}
+
+// GetActiveAPIKey retrieves the active API key with the given UUID, without
+// recording that it was used. Returns ErrInvalidKey if there is no such key
+// or it has been revoked.
+func GetActiveAPIKey(uuid db.UUID) (*APIKey, error) {
+	return store.GetActiveAPIKey(db.SQLDB, uuid)
+}
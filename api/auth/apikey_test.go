@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+func TestSQLiteAPIKeyLifecycle(t *testing.T) {
+	dbtest.Open(t, dbtest.Store(SetStore, Store(&SQLiteStore{})))
+
+	key, secret, err := NewAPIKey("reader", []string{"cage:read:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+
+	used, err := Authenticate(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used.UUID != key.UUID || used.LastUsedAt == nil {
+		t.Errorf("authenticated %s last used at %v, want %s with a last use", used.Name, used.LastUsedAt, key.Name)
+	}
+	if _, err := Authenticate(secret + "0"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("wrong secret error = %v, want ErrInvalidKey", err)
+	}
+
+	if err := RevokeAPIKey("reader"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RevokeAPIKey("reader"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("second revoke error = %v, want ErrKeyNotFound", err)
+	}
+	if _, err := Authenticate(secret); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("revoked key error = %v, want ErrInvalidKey", err)
+	}
+	if _, err := GetActiveAPIKey(key.UUID); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("get revoked key error = %v, want ErrInvalidKey", err)
+	}
+
+	keys, err := ListAPIKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("listed %d keys, want the revoked key", len(keys))
+	}
+}
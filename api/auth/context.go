@@ -0,0 +1,18 @@
+package auth
+
+import "context"
+
+// contextKey is the type of context keys defined by this package.
+type contextKey struct{}
+
+// WithKey returns a copy of ctx carrying an authenticated API key.
+func WithKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// FromContext returns the API key carried by ctx, or nil if the context is
+// anonymous.
+func FromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(contextKey{}).(*APIKey)
+	return key
+}
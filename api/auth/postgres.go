@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/octacian/backroom/api/.gen/backroom/public/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// PostgresStore is a Store backed by the Postgres database.
+type PostgresStore struct{}
+
+// InsertAPIKey implements Store.
+func (s *PostgresStore) InsertAPIKey(exec db.Executor, key *APIKey) error {
+	insert := table.APIKey.INSERT(
+		table.APIKey.UUID,
+		table.APIKey.Name,
+		table.APIKey.Hash,
+		table.APIKey.Scopes,
+	).MODEL(key)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// ListAPIKeys implements Store.
+func (s *PostgresStore) ListAPIKeys(exec db.Executor) ([]*APIKey, error) {
+	stmt := table.APIKey.SELECT(table.APIKey.AllColumns).
+		ORDER_BY(table.APIKey.UUID.ASC())
+
+	var keys []*APIKey
+	err := stmt.Query(exec, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey implements Store.
+func (s *PostgresStore) RevokeAPIKey(exec db.Executor, name string) (int64, error) {
+	stmt := table.APIKey.UPDATE().
+		SET(table.APIKey.RevokedAt.SET(postgres.NOW())).
+		WHERE(
+			table.APIKey.Name.EQ(postgres.String(name)).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		)
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// UseAPIKey implements Store.
+func (s *PostgresStore) UseAPIKey(exec db.Executor, hash string) (*APIKey, error) {
+	stmt := table.APIKey.UPDATE().
+		SET(table.APIKey.LastUsedAt.SET(postgres.NOW())).
+		WHERE(
+			table.APIKey.Hash.EQ(postgres.String(hash)).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		).
+		RETURNING(table.APIKey.AllColumns)
+
+	var key APIKey
+	err := stmt.Query(exec, &key)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetActiveAPIKey implements Store.
+func (s *PostgresStore) GetActiveAPIKey(exec db.Executor, uuid db.UUID) (*APIKey, error) {
+	stmt := table.APIKey.SELECT(table.APIKey.AllColumns).
+		WHERE(
+			table.APIKey.UUID.EQ(postgres.UUID(uuid)).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		)
+
+	var key APIKey
+	err := stmt.Query(exec, &key)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/octacian/backroom/api/config"
+)
+
+var ErrBadScope = errors.New("bad scope")
+
+// ScopeAdmin grants every permission on every cage.
+const ScopeAdmin = "admin"
+
+// Permission is an operation on caged records that may be granted by a scope.
+type Permission string
+
+const (
+	// PermRead allows records to be retrieved and listed.
+	PermRead Permission = "read"
+	// PermCreate allows new records to be created.
+	PermCreate Permission = "create"
+	// PermWrite allows records to be updated and deleted.
+	PermWrite Permission = "write"
+)
+
+// ValidateScope returns ErrBadScope if the scope is malformed. Valid scopes
+// are "admin", "cage:read:<glob>" and "cage:write:<glob>", where glob is a
+// path.Match pattern matched against cage keys.
+func ValidateScope(scope string) error {
+	if scope == ScopeAdmin {
+		return nil
+	}
+
+	parts := strings.SplitN(scope, ":", 3)
+	if len(parts) != 3 || parts[0] != "cage" || parts[2] == "" {
+		return fmt.Errorf("%w: %q", ErrBadScope, scope)
+	}
+
+	if parts[1] != string(PermRead) && parts[1] != string(PermWrite) {
+		return fmt.Errorf("%w: %q: unknown permission %q", ErrBadScope, scope, parts[1])
+	}
+
+	if _, err := path.Match(parts[2], ""); err != nil {
+		return fmt.Errorf("%w: %q: %v", ErrBadScope, scope, err)
+	}
+
+	return nil
+}
+
+// scopeAllows returns whether a single scope grants a permission on a cage.
+func scopeAllows(scope string, perm Permission, cage string) bool {
+	if scope == ScopeAdmin {
+		return true
+	}
+
+	parts := strings.SplitN(scope, ":", 3)
+	if len(parts) != 3 || parts[0] != "cage" {
+		return false
+	}
+
+	// Write scopes grant both creating and modifying records
+	granted := Permission(parts[1])
+	if granted != perm && !(granted == PermWrite && perm == PermCreate) {
+		return false
+	}
+
+	ok, err := path.Match(parts[2], cage)
+	return err == nil && ok
+}
+
+// IsPublicCage returns whether a cage accepts anonymous record creation.
+// See config.RC.Auth.PublicCages.
+func IsPublicCage(cage string) bool {
+	for _, pattern := range config.RC.Auth.PublicCages {
+		if ok, err := path.Match(pattern, cage); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed returns whether a key may perform an operation on a cage. A nil key
+// represents an anonymous request, which may only create records in public cages.
+func Allowed(key *APIKey, perm Permission, cage string) bool {
+	if key == nil {
+		return perm == PermCreate && IsPublicCage(cage)
+	}
+
+	for _, scope := range key.ScopeList() {
+		if scopeAllows(scope, perm, cage) {
+			return true
+		}
+	}
+	return false
+}
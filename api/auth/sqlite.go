@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/go-jet/jet/v2/sqlite"
+	"github.com/octacian/backroom/api/.gen/backroom/sqlite/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// SQLiteStore is a Store backed by an SQLite database.
+type SQLiteStore struct{}
+
+// InsertAPIKey implements Store.
+func (s *SQLiteStore) InsertAPIKey(exec db.Executor, key *APIKey) error {
+	insert := table.APIKey.INSERT(
+		table.APIKey.UUID,
+		table.APIKey.Name,
+		table.APIKey.Hash,
+		table.APIKey.Scopes,
+	).MODEL(key)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// ListAPIKeys implements Store.
+func (s *SQLiteStore) ListAPIKeys(exec db.Executor) ([]*APIKey, error) {
+	stmt := table.APIKey.SELECT(table.APIKey.AllColumns).
+		ORDER_BY(table.APIKey.UUID.ASC())
+
+	var keys []*APIKey
+	err := stmt.Query(exec, &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey implements Store.
+func (s *SQLiteStore) RevokeAPIKey(exec db.Executor, name string) (int64, error) {
+	stmt := table.APIKey.UPDATE().
+		SET(table.APIKey.RevokedAt.SET(db.SQLiteNow())).
+		WHERE(
+			table.APIKey.Name.EQ(sqlite.String(name)).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		)
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// UseAPIKey implements Store.
+func (s *SQLiteStore) UseAPIKey(exec db.Executor, hash string) (*APIKey, error) {
+	stmt := table.APIKey.UPDATE().
+		SET(table.APIKey.LastUsedAt.SET(db.SQLiteNow())).
+		WHERE(
+			table.APIKey.Hash.EQ(sqlite.String(hash)).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		).
+		RETURNING(table.APIKey.AllColumns)
+
+	var key APIKey
+	err := stmt.Query(exec, &key)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// GetActiveAPIKey implements Store.
+func (s *SQLiteStore) GetActiveAPIKey(exec db.Executor, uuid db.UUID) (*APIKey, error) {
+	stmt := table.APIKey.SELECT(table.APIKey.AllColumns).
+		WHERE(
+			table.APIKey.UUID.EQ(sqlite.String(uuid.String())).
+				AND(table.APIKey.RevokedAt.IS_NULL()),
+		)
+
+	var key APIKey
+	err := stmt.Query(exec, &key)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrInvalidKey
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"github.com/octacian/backroom/api/db"
+)
+
+// Store persists API keys. Each method runs on the given executor, so that
+// writes may take part in a wider transaction.
+type Store interface {
+	// InsertAPIKey inserts a new API key.
+	InsertAPIKey(exec db.Executor, key *APIKey) error
+	// ListAPIKeys retrieves all API keys, including revoked keys.
+	ListAPIKeys(exec db.Executor) ([]*APIKey, error)
+	// RevokeAPIKey revokes the active API key with the given name, returning
+	// the number of keys revoked.
+	RevokeAPIKey(exec db.Executor, name string) (int64, error)
+	// UseAPIKey retrieves the active API key with the given hash and records
+	// that it was used. Returns ErrInvalidKey if there is no such key.
+	UseAPIKey(exec db.Executor, hash string) (*APIKey, error)
+	// GetActiveAPIKey retrieves the active API key with the given UUID.
+	// Returns ErrInvalidKey if there is no such key.
+	GetActiveAPIKey(exec db.Executor, uuid db.UUID) (*APIKey, error)
+}
+
+// store is the Store used by the package functions.
+var store Store = &PostgresStore{}
+
+// InitStore selects the Store matching the configured database driver.
+func InitStore() {
+	if db.IsSQLite() {
+		store = &SQLiteStore{}
+	} else {
+		store = &PostgresStore{}
+	}
+}
+
+// SetStore replaces the Store used by the package functions, returning the
+// previous one.
+func SetStore(s Store) Store {
+	prev := store
+	store = s
+	return prev
+}
@@ -0,0 +1,169 @@
+// Package backup writes every table of the database to a compressed archive
+// and restores them from one, so that a deployment can be moved or recovered
+// without external tools.
+//
+// An archive is a gzip compressed stream of JSON lines. The first line is a
+// Manifest, and each following line is a single table row. Archives written
+// by the postgres driver are restored by the postgres driver, and those
+// written by the sqlite or temp drivers by either of those, as the drivers
+// store columns in different types.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+	"github.com/pressly/goose/v3"
+)
+
+// ArchiveFormat identifies backup archives, and ArchiveVersion is the version
+// of their layout written by this build.
+const (
+	ArchiveFormat  = "backroom-backup"
+	ArchiveVersion = 1
+)
+
+var (
+	ErrBadArchive   = errors.New("bad backup archive")
+	ErrNewerArchive = errors.New("backup archive is newer than this build")
+	ErrBadStrategy  = errors.New("bad conflict strategy")
+	ErrConflict     = errors.New("row already exists")
+	ErrUnknownTable = errors.New("unknown table")
+	ErrWrongDriver  = errors.New("backup archive was written by another driver")
+)
+
+// maxRowLine is the longest row accepted when restoring an archive.
+const maxRowLine = 64 << 20
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	// Driver is the driver which wrote the archive, missing from archives
+	// written before SQLite support, which were all postgres.
+	Driver    string    `json:"driver,omitempty"`
+	Migration int64     `json:"migration"` // Goose version of the backed up database
+	CreatedAt time.Time `json:"created_at"`
+	Tables    []string  `json:"tables"`
+}
+
+// row is a single table row in an archive.
+type row struct {
+	Table string          `json:"table"`
+	Row   json.RawMessage `json:"row"`
+}
+
+// Write streams every table of the database to w as a compressed archive,
+// reading from a consistent snapshot. The goose version table is left out, as
+// restores apply their own migrations.
+func Write(w io.Writer) (*Manifest, error) {
+	driver := currentDriver()
+	manifest := &Manifest{
+		Format:    ArchiveFormat,
+		Version:   ArchiveVersion,
+		Driver:    driverName(),
+		CreatedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if manifest.Migration, err = goose.GetDBVersion(db.SQLDB); err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	encoder := json.NewEncoder(gz)
+
+	err = db.Snapshot(func(tx *sql.Tx) error {
+		var err error
+		if manifest.Tables, err = driver.listTables(tx); err != nil {
+			return err
+		}
+		if err := encoder.Encode(manifest); err != nil {
+			return err
+		}
+
+		for _, table := range manifest.Tables {
+			err := driver.scanTable(tx, table, func(data json.RawMessage) error {
+				return encoder.Encode(row{Table: table, Row: data})
+			})
+			if err != nil {
+				return fmt.Errorf("table %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, gz.Close()
+}
+
+// ReadManifest reads the manifest at the start of an archive, returning a
+// reader positioned at its first row.
+func ReadManifest(r io.Reader) (*Manifest, *bufio.Scanner, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRowLine)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+		return nil, nil, fmt.Errorf("%w: missing manifest", ErrBadArchive)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(scanner.Bytes(), &manifest); err != nil || manifest.Format != ArchiveFormat {
+		return nil, nil, fmt.Errorf("%w: missing manifest", ErrBadArchive)
+	}
+	if manifest.Version > ArchiveVersion {
+		return nil, nil, fmt.Errorf("%w: archive version %d", ErrNewerArchive, manifest.Version)
+	}
+
+	return &manifest, scanner, nil
+}
+
+// driver reads and writes tables in the SQL dialect of a database driver.
+type driver interface {
+	// listTables returns the name of every table other than the goose version
+	// table, in alphabetical order.
+	listTables(exec db.Executor) ([]string, error)
+	// scanTable calls fn with every row of a table as a JSON object.
+	scanTable(tx *sql.Tx, table string, fn func(data json.RawMessage) error) error
+	// describeTables returns the columns and primary key of every table.
+	describeTables(exec db.Executor) (map[string]tableInfo, error)
+	// insertRow inserts the given columns of a row, ending the statement with
+	// conflict, and returns whether a row was written.
+	insertRow(tx *sql.Tx, entry row, fields map[string]json.RawMessage, columns []string, info tableInfo, conflict string) (bool, error)
+	// resetSequences advances generated keys past the largest restored value,
+	// so that new rows don't collide with restored ones.
+	resetSequences(tx *sql.Tx) error
+}
+
+// currentDriver returns the driver for the configured database.
+func currentDriver() driver {
+	if db.IsSQLite() {
+		return &sqliteDriver{}
+	}
+	return &postgresDriver{}
+}
+
+// driverName returns the driver recorded in archives of the configured
+// database. The sqlite and temp drivers share archives.
+func driverName() string {
+	if db.IsSQLite() {
+		return db.DriverSQLite
+	}
+	return db.DriverPostgres
+}
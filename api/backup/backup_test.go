@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+// tableResult returns the result of restoring table.
+func tableResult(t *testing.T, result *RestoreResult, table string) TableResult {
+	t.Helper()
+	for _, res := range result.Tables {
+		if res.Table == table {
+			return res
+		}
+	}
+	t.Fatalf("no result for table %s", table)
+	return TableResult{}
+}
+
+func TestSQLiteBackupRestore(t *testing.T) {
+	dbtest.Open(t, dbtest.Store(auth.SetStore, auth.Store(&auth.SQLiteStore{})))
+
+	key, secret, err := auth.NewAPIKey("reader", []string{"cage:read:*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.CreateAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	created, err := auth.GetActiveAPIKey(key.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	manifest, err := Write(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Driver != db.DriverSQLite || manifest.Migration == 0 {
+		t.Errorf("manifest driver %q at migration %d, want sqlite at a migration", manifest.Driver, manifest.Migration)
+	}
+
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), ConflictFail); !errors.Is(err, ErrConflict) {
+		t.Errorf("restore over existing rows error = %v, want ErrConflict", err)
+	}
+
+	result, err := Restore(bytes.NewReader(archive.Bytes()), ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := tableResult(t, result, "api_key"); res.Restored != 0 || res.Skipped != 1 {
+		t.Errorf("skip restored %d and skipped %d keys, want 0 and 1", res.Restored, res.Skipped)
+	}
+
+	if err := auth.RevokeAPIKey("reader"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(bytes.NewReader(archive.Bytes()), ConflictOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.Authenticate(secret); err != nil {
+		t.Errorf("overwritten key error = %v, want the revocation undone", err)
+	}
+
+	if _, err := db.SQLDB.Exec("DELETE FROM api_key"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Restore(bytes.NewReader(archive.Bytes()), ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := tableResult(t, result, "api_key"); res.Restored != 1 {
+		t.Errorf("restored %d keys, want 1", res.Restored)
+	}
+
+	restored, err := auth.GetActiveAPIKey(key.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("restored key created at %v, want %v", restored.CreatedAt, created.CreatedAt)
+	}
+}
+
+func TestRestoreWrongDriver(t *testing.T) {
+	dbtest.Open(t)
+
+	// Archives written before SQLite support have no driver, and are postgres
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	if err := json.NewEncoder(gz).Encode(Manifest{Format: ArchiveFormat, Version: ArchiveVersion, Migration: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(&archive, ConflictFail); !errors.Is(err, ErrWrongDriver) {
+		t.Errorf("restore postgres archive error = %v, want ErrWrongDriver", err)
+	}
+}
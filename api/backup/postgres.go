@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/octacian/backroom/api/db"
+	"github.com/pressly/goose/v3"
+)
+
+// fetchSize is the number of rows read from a table at a time.
+const fetchSize = 500
+
+// postgresDriver reads and writes tables in a Postgres database.
+type postgresDriver struct{}
+
+// listTables returns the name of every table in the current schema other than
+// the goose version table, in alphabetical order.
+func (d *postgresDriver) listTables(exec db.Executor) ([]string, error) {
+	rows, err := exec.Query(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_type = 'BASE TABLE' AND table_name <> $1
+		ORDER BY table_name`, goose.TableName())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// scanTable calls fn with every row of a table as a JSON object, reading them
+// through a cursor a batch at a time.
+func (d *postgresDriver) scanTable(tx *sql.Tx, table string, fn func(data json.RawMessage) error) error {
+	declare := fmt.Sprintf("DECLARE backup_rows NO SCROLL CURSOR FOR SELECT row_to_json(t)::text FROM %s t", pq.QuoteIdentifier(table))
+	if _, err := tx.Exec(declare); err != nil {
+		return err
+	}
+
+	for {
+		rows, err := tx.Query(fmt.Sprintf("FETCH FORWARD %d FROM backup_rows", fetchSize))
+		if err != nil {
+			return err
+		}
+
+		count := 0
+		for rows.Next() {
+			count++
+			var data string
+			if err := rows.Scan(&data); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := fn(json.RawMessage(data)); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if count < fetchSize {
+			break
+		}
+	}
+
+	_, err := tx.Exec("CLOSE backup_rows")
+	return err
+}
+
+// describeTables returns the columns and primary key of every table in the
+// current schema.
+func (d *postgresDriver) describeTables(exec db.Executor) (map[string]tableInfo, error) {
+	tables := make(map[string]tableInfo)
+
+	rows, err := exec.Query(`SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+
+		info, ok := tables[table]
+		if !ok {
+			info = tableInfo{columns: make(map[string]bool)}
+		}
+		info.columns[column] = true
+		tables[table] = info
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	keys, err := exec.Query(`SELECT kcu.table_name, kcu.column_name FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		WHERE tc.table_schema = current_schema() AND tc.constraint_type = 'PRIMARY KEY'
+		ORDER BY kcu.table_name, kcu.ordinal_position`)
+	if err != nil {
+		return nil, err
+	}
+	defer keys.Close()
+
+	for keys.Next() {
+		var table, column string
+		if err := keys.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+
+		if info, ok := tables[table]; ok {
+			info.keys = append(info.keys, column)
+			tables[table] = info
+		}
+	}
+	return tables, keys.Err()
+}
+
+// insertRow inserts a row by populating a record of the table from its JSON,
+// which converts each field to the type of its column.
+func (d *postgresDriver) insertRow(tx *sql.Tx, entry row, fields map[string]json.RawMessage, columns []string, info tableInfo, conflict string) (bool, error) {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+	}
+
+	list := strings.Join(quoted, ", ")
+	query := fmt.Sprintf("INSERT INTO %[1]s (%[2]s) SELECT %[2]s FROM json_populate_record(NULL::%[1]s, $1::json)",
+		pq.QuoteIdentifier(entry.Table), list) + conflict
+
+	res, err := tx.Exec(query, string(entry.Row))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+		return false, fmt.Errorf("%w: %s", ErrConflict, pqErr.Detail)
+	} else if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// resetSequences advances the sequence behind every serial column past the
+// largest restored value, so that new rows don't collide with restored ones.
+func (d *postgresDriver) resetSequences(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT table_name, column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND (column_default LIKE 'nextval(%' OR is_identity = 'YES')`)
+	if err != nil {
+		return err
+	}
+
+	type serial struct{ table, column string }
+	serials := make([]serial, 0)
+	for rows.Next() {
+		var s serial
+		if err := rows.Scan(&s.table, &s.column); err != nil {
+			rows.Close()
+			return err
+		}
+		serials = append(serials, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range serials {
+		table, column := pq.QuoteIdentifier(s.table), pq.QuoteIdentifier(s.column)
+		query := fmt.Sprintf("SELECT setval(pg_get_serial_sequence($1, $2), COALESCE(MAX(%s), 0) + 1, false) FROM %s", column, table)
+		if _, err := tx.Exec(query, table, s.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package backup
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/migrations"
+	"github.com/pressly/goose/v3"
+)
+
+// Conflict strategies, deciding what happens when a restored row has the same
+// key as one already in the database.
+const (
+	ConflictSkip      = "skip"      // Keep the existing row
+	ConflictOverwrite = "overwrite" // Replace the existing row with the restored one
+	ConflictFail      = "fail"      // Abort the restore, rolling back every restored row
+)
+
+// TableResult counts the rows restored into a table.
+type TableResult struct {
+	Table    string `json:"table"`
+	Restored int    `json:"restored"`
+	Skipped  int    `json:"skipped"`
+}
+
+// RestoreResult summarizes a restore.
+type RestoreResult struct {
+	Manifest *Manifest     `json:"manifest"`
+	Tables   []TableResult `json:"tables"`
+}
+
+// tableInfo describes a table in the database being restored into.
+type tableInfo struct {
+	columns    map[string]bool
+	keys       []string        // Primary key columns
+	timestamps map[string]bool // Timestamp columns, only described by SQLite
+}
+
+// Restore loads an archive written by Write into the database, after applying
+// any pending migrations. Rows are inserted in a single transaction, and
+// conflicts with existing rows are handled according to strategy. Columns no
+// longer in the database are ignored, and columns missing from the archive
+// take their defaults. Archives taken at a newer migration than this build
+// knows of, or written by another driver, are refused.
+func Restore(r io.Reader, strategy string) (*RestoreResult, error) {
+	switch strategy {
+	case ConflictSkip, ConflictOverwrite, ConflictFail:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrBadStrategy, strategy)
+	}
+
+	manifest, scanner, err := ReadManifest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	archived := manifest.Driver
+	if archived == "" {
+		archived = db.DriverPostgres
+	}
+	if archived != driverName() {
+		return nil, fmt.Errorf("%w: archive is from %s, database is %s", ErrWrongDriver, archived, driverName())
+	}
+
+	goose.SetBaseFS(migrations.Migrations)
+	if err := goose.Up(db.SQLDB, db.MigrationsDir()); err != nil {
+		return nil, fmt.Errorf("applying migrations: %w", err)
+	}
+
+	version, err := goose.GetDBVersion(db.SQLDB)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Migration > version {
+		return nil, fmt.Errorf("%w: archive is at migration %d, database is at %d", ErrNewerArchive, manifest.Migration, version)
+	}
+
+	result := &RestoreResult{Manifest: manifest, Tables: make([]TableResult, 0, len(manifest.Tables))}
+	index := make(map[string]int)
+	for i, table := range manifest.Tables {
+		result.Tables = append(result.Tables, TableResult{Table: table})
+		index[table] = i
+	}
+
+	driver := currentDriver()
+	err = db.Transact(func(tx *sql.Tx) error {
+		tables, err := driver.describeTables(tx)
+		if err != nil {
+			return err
+		}
+
+		line := 1
+		for scanner.Scan() {
+			line++
+
+			var entry row
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrBadArchive, line, err)
+			}
+
+			info, ok := tables[entry.Table]
+			i, listed := index[entry.Table]
+			if !ok || !listed {
+				return fmt.Errorf("%w: line %d: %q", ErrUnknownTable, line, entry.Table)
+			}
+
+			inserted, err := restoreRow(tx, driver, entry, info, strategy)
+			if err != nil {
+				return fmt.Errorf("line %d: table %s: %w", line, entry.Table, err)
+			}
+			if inserted {
+				result.Tables[i].Restored++
+			} else {
+				result.Tables[i].Skipped++
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("%w: %v", ErrBadArchive, err)
+		}
+
+		return driver.resetSequences(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// restoreRow inserts a single row, returning false if it was skipped.
+func restoreRow(tx *sql.Tx, driver driver, entry row, info tableInfo, strategy string) (bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Row, &fields); err != nil {
+		return false, fmt.Errorf("%w: %v", ErrBadArchive, err)
+	}
+
+	columns := make([]string, 0, len(fields))
+	for column := range fields {
+		if info.columns[column] {
+			columns = append(columns, column)
+		}
+	}
+	slices.Sort(columns)
+	if len(columns) == 0 {
+		return false, fmt.Errorf("%w: row has no known columns", ErrBadArchive)
+	}
+
+	conflict, err := conflictClause(columns, info, strategy)
+	if err != nil {
+		return false, err
+	}
+
+	return driver.insertRow(tx, entry, fields, columns, info, conflict)
+}
+
+// conflictClause returns the ON CONFLICT clause for inserting columns of a
+// row with strategy, which both drivers share.
+func conflictClause(columns []string, info tableInfo, strategy string) (string, error) {
+	switch strategy {
+	case ConflictSkip:
+		return " ON CONFLICT DO NOTHING", nil
+	case ConflictOverwrite:
+		if len(info.keys) == 0 {
+			return "", fmt.Errorf("can't overwrite rows of a table without a primary key")
+		}
+
+		keys := make([]string, len(info.keys))
+		for i, key := range info.keys {
+			keys[i] = pq.QuoteIdentifier(key)
+		}
+
+		updates := make([]string, 0, len(columns))
+		for _, column := range columns {
+			if !slices.Contains(info.keys, column) {
+				column = pq.QuoteIdentifier(column)
+				updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+			}
+		}
+
+		clause := fmt.Sprintf(" ON CONFLICT (%s)", strings.Join(keys, ", "))
+		if len(updates) == 0 {
+			return clause + " DO NOTHING", nil
+		}
+		return clause + " DO UPDATE SET " + strings.Join(updates, ", "), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,192 @@
+package backup
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/octacian/backroom/api/db"
+	"github.com/pressly/goose/v3"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteDriver reads and writes tables in an SQLite database. Identifiers are
+// quoted the same way as in Postgres.
+type sqliteDriver struct{}
+
+// listTables returns the name of every table other than the goose version
+// table and those internal to SQLite, in alphabetical order.
+func (d *sqliteDriver) listTables(exec db.Executor) ([]string, error) {
+	rows, err := exec.Query(`SELECT name FROM sqlite_master
+		WHERE type = 'table' AND name NOT LIKE 'sqlite\_%' ESCAPE '\' AND name <> ?
+		ORDER BY name`, goose.TableName())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make([]string, 0)
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// scanTable calls fn with every row of a table as a JSON object. Timestamps
+// are written in UTC, and JSON columns as the text SQLite stores them as.
+func (d *sqliteDriver) scanTable(tx *sql.Tx, table string, fn func(data json.RawMessage) error) error {
+	rows, err := tx.Query(fmt.Sprintf("SELECT * FROM %s", pq.QuoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		fields := make(map[string]any, len(columns))
+		for i, column := range columns {
+			if t, ok := values[i].(time.Time); ok {
+				values[i] = t.UTC()
+			}
+			fields[column] = values[i]
+		}
+
+		data, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// describeTables returns the columns, primary key and timestamp columns of
+// every table.
+func (d *sqliteDriver) describeTables(exec db.Executor) (map[string]tableInfo, error) {
+	rows, err := exec.Query(`SELECT m.name, c.name, c.type, c.pk FROM sqlite_master AS m
+		JOIN pragma_table_info(m.name) AS c
+		WHERE m.type = 'table'
+		ORDER BY m.name, c.pk`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make(map[string]tableInfo)
+	for rows.Next() {
+		var table, column, kind string
+		var pk int
+		if err := rows.Scan(&table, &column, &kind, &pk); err != nil {
+			return nil, err
+		}
+
+		info, ok := tables[table]
+		if !ok {
+			info = tableInfo{columns: make(map[string]bool), timestamps: make(map[string]bool)}
+		}
+		info.columns[column] = true
+		if pk > 0 {
+			info.keys = append(info.keys, column)
+		}
+		switch strings.ToUpper(kind) {
+		case "DATE", "DATETIME", "TIMESTAMP":
+			info.timestamps[column] = true
+		}
+		tables[table] = info
+	}
+	return tables, rows.Err()
+}
+
+// insertRow inserts a row, binding each field by its JSON type. Timestamps
+// are bound as times, so that they are stored the same way as when written by
+// the API.
+func (d *sqliteDriver) insertRow(tx *sql.Tx, entry row, fields map[string]json.RawMessage, columns []string, info tableInfo, conflict string) (bool, error) {
+	quoted := make([]string, len(columns))
+	params := make([]string, len(columns))
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+		params[i] = "?"
+
+		arg, err := sqliteArg(fields[column], info.timestamps[column])
+		if err != nil {
+			return false, fmt.Errorf("%w: column %s: %v", ErrBadArchive, column, err)
+		}
+		args[i] = arg
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", pq.QuoteIdentifier(entry.Table),
+		strings.Join(quoted, ", "), strings.Join(params, ", ")) + conflict
+
+	res, err := tx.Exec(query, args...)
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY) {
+		return false, fmt.Errorf("%w: %s", ErrConflict, sqliteErr.Error())
+	} else if err != nil {
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// resetSequences does nothing, as SQLite advances the next key of a table
+// past any larger key inserted into it.
+func (d *sqliteDriver) resetSequences(tx *sql.Tx) error {
+	return nil
+}
+
+// sqliteArg decodes a field of an archived row into a query argument.
+// Objects and arrays are bound as JSON text.
+func sqliteArg(field json.RawMessage, timestamp bool) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(field))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		return v.Float64()
+	case string:
+		if timestamp {
+			return time.Parse(time.RFC3339Nano, v)
+		}
+		return v, nil
+	case map[string]any, []any:
+		return string(field), nil
+	default:
+		return v, nil
+	}
+}
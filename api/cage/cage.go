@@ -1,14 +1,23 @@
 package cage
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
-	"github.com/go-jet/jet/v2/postgres"
 	"github.com/octacian/backroom/api/.gen/backroom/public/model"
-	"github.com/octacian/backroom/api/.gen/backroom/public/table"
 	"github.com/octacian/backroom/api/db"
 )
 
+var (
+	ErrRecordNotFound  = errors.New("record not found")
+	ErrVersionMismatch = errors.New("record version mismatch")
+)
+
 // Record is a caged entry, identified by UUID, grouped by cage key, and
 // containing some data.
 // Wraps generated model.Record type.
@@ -34,104 +43,268 @@ func NewRecordFromString(key string, data string) (*Record, error) {
 	return NewRecord(key, jsonb), nil
 }
 
-// CreateRecord creates a new caged record in the database
-func CreateRecord(cage *Record) error {
-	insert := table.Record.INSERT(table.Record.AllColumns).MODEL(cage)
+// CreateRecord creates a new caged record in the database, recording actor
+// in its history. Returns a *SchemaError if the record doesn't match its
+// cage schema. If the record duplicates another at the unique paths of its
+// cage, it is handled as configured by the cage on_conflict option, leaving
+// record holding the existing record if it was merged or ignored. Returns
+// ErrDuplicate if such records are rejected.
+func CreateRecord(cage *Record, actor string) error {
+	return CreateRecordContext(context.Background(), cage, actor)
+}
 
-	_, err := insert.Exec(db.SQLDB)
-	if err != nil {
+// CreateRecordContext is like CreateRecord, but is cancelled once ctx is done.
+func CreateRecordContext(ctx context.Context, cage *Record, actor string) error {
+	return db.TransactContext(ctx, func(tx *sql.Tx) error {
+		_, _, err := CreateRecordTx(db.WithContext(ctx, tx), cage, actor, true)
 		return err
+	})
+}
+
+// CreateRecordTx creates a new caged record using the given executor, allowing
+// the insert to take part in a wider transaction. Returns the outcome of the
+// create, along with the data of the existing record before the merge if
+// the record was merged into it. If merge is false, as for callers which may
+// not change existing records, records the cage would merge are ignored.
+func CreateRecordTx(exec db.Executor, cage *Record, actor string, merge bool) (Outcome, db.JSONB, error) {
+	if err := ValidateRecord(cage); err != nil {
+		return Created, nil, err
 	}
 
-	return nil
+	existing, def, err := findDuplicate(exec, cage, true)
+	if err != nil {
+		return Created, nil, err
+	} else if existing == nil && (def == nil || (def.OnConflict != ConflictMerge && def.OnConflict != ConflictIgnore)) {
+		return Created, nil, insertRecordsTx(exec, []*Record{cage}, actor)
+	} else if existing == nil {
+		// A concurrent create may claim the same values first, in which case
+		// the record is merged into or ignored in favour of the one it created
+		if existing, err = insertOrFindDuplicate(exec, cage, actor); err != nil || existing == nil {
+			return Created, nil, err
+		}
+	}
+
+	onConflict := def.OnConflict
+	if onConflict == ConflictMerge && !merge {
+		onConflict = ConflictIgnore
+	}
+
+	switch onConflict {
+	case ConflictIgnore:
+		*cage = *existing
+		return Ignored, nil, nil
+	case ConflictMerge:
+		patch, err := json.Marshal(cage.Data)
+		if err != nil {
+			return Created, nil, err
+		}
+
+		before := existing.Data
+		existing.Data, err = ApplyPatch(before, MergePatch, patch)
+		if err != nil {
+			return Created, nil, err
+		}
+		if err := updateRecordTx(exec, existing, RevisionUpdate, actor); err != nil {
+			return Created, nil, err
+		}
+
+		*cage = *existing
+		return Merged, before, nil
+	default:
+		return Created, nil, fmt.Errorf("%w: matches record %s at %s", ErrDuplicate,
+			existing.UUID, strings.Join(def.Unique, ", "))
+	}
 }
 
-// GetRecord retrieves a specific record from the database by its UUID.
-func GetRecord(uuid db.UUID) (*Record, error) {
-	stmt := table.Record.SELECT(table.Record.AllColumns).
-		WHERE(table.Record.UUID.EQ(postgres.UUID(uuid))).
-		ORDER_BY(table.Record.UUID.DESC()).
-		LIMIT(1)
+// insertOrFindDuplicate inserts a new record, unless another holding the same
+// values at the unique paths of its cage was committed since it was looked
+// for, in which case that record is returned instead. The insert is made
+// within a savepoint, as a failed statement aborts the rest of a Postgres
+// transaction.
+func insertOrFindDuplicate(exec db.Executor, record *Record, actor string) (*Record, error) {
+	if _, err := exec.Exec("SAVEPOINT create_record"); err != nil {
+		return nil, err
+	}
 
-	var cage Record
-	err := stmt.Query(db.SQLDB, &cage)
-	if err != nil {
+	err := insertRecordsTx(exec, []*Record{record}, actor)
+	if !errors.Is(err, ErrDuplicate) {
+		if err == nil {
+			_, err = exec.Exec("RELEASE SAVEPOINT create_record")
+		}
+		return nil, err
+	}
+
+	if _, err := exec.Exec("ROLLBACK TO SAVEPOINT create_record"); err != nil {
 		return nil, err
 	}
 
-	return &cage, nil
+	existing, _, err := findDuplicate(exec, record, true)
+	if err == nil && existing == nil {
+		err = fmt.Errorf("%w: duplicate record was deleted concurrently", ErrDuplicate)
+	}
+	return existing, err
+}
+
+// insertRecordsTx inserts new records at their first version.
+func insertRecordsTx(exec db.Executor, records []*Record, actor string) error {
+	now := time.Now().UTC()
+	for _, record := range records {
+		record.CreatedAt = now
+		record.UpdatedAt = now
+		record.Version = 1
+	}
+
+	return duplicateError(store.InsertRecords(exec, records, actor))
+}
+
+// GetRecord retrieves a specific record from the database by its UUID.
+// Records in the trash are not retrieved.
+func GetRecord(uuid db.UUID) (*Record, error) {
+	return GetRecordContext(context.Background(), uuid)
+}
+
+// GetRecordContext is like GetRecord, but is cancelled once ctx is done.
+func GetRecordContext(ctx context.Context, uuid db.UUID) (*Record, error) {
+	return store.GetRecord(db.WithContext(ctx, db.SQLDB), uuid, false)
 }
 
-// ListRecordsByCage retrieves all records belonging to a common cage from the database.
-func ListRecordsByCage(cage string) ([]*Record, error) {
-	stmt := table.Record.SELECT(table.Record.AllColumns).
-		WHERE(table.Record.Cage.EQ(postgres.String(cage))).
-		ORDER_BY(table.Record.UUID.DESC())
+// ListRecordsByCage retrieves records belonging to a common cage from the
+// database, newest first and narrowed by query. If the query limit cut the
+// listing short, a cursor is returned for retrieving the next page.
+func ListRecordsByCage(cage string, query ListQuery) ([]*Record, string, error) {
+	return ListRecordsByCageContext(context.Background(), cage, query)
+}
 
-	var cages []*Record
-	err := stmt.Query(db.SQLDB, &cages)
+// ListRecordsByCageContext is like ListRecordsByCage, but is cancelled once
+// ctx is done.
+func ListRecordsByCageContext(ctx context.Context, cage string, query ListQuery) ([]*Record, string, error) {
+	// Fetch an extra record to find out whether there is another page
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
+
+	cages, err := store.ListRecords(db.WithContext(ctx, db.SQLDB), cage, query)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return cages, nil
+	var next string
+	if limit > 0 && len(cages) > limit {
+		cages = cages[:limit]
+		next = query.nextCursor(cages[len(cages)-1])
+	}
+
+	return cages, next, nil
 }
 
-// ListCages retrieves all unique cages from the database.
+// ListCages retrieves all unique cages with records outside of the trash
+// from the database.
 func ListCages() ([]string, error) {
-	stmt := table.Record.SELECT(table.Record.Cage).DISTINCT()
+	return ListCagesContext(context.Background())
+}
 
-	var cages []string
-	err := stmt.Query(db.SQLDB, &cages)
-	if err != nil {
-		return nil, err
-	}
+// ListCagesContext is like ListCages, but is cancelled once ctx is done.
+func ListCagesContext(ctx context.Context) ([]string, error) {
+	return store.ListCages(db.WithContext(ctx, db.SQLDB))
+}
+
+// UpdateRecord updates an existing record in the database, recording actor
+// in its history. Returns ErrVersionMismatch if the record has been changed
+// since it was read, a *SchemaError if the record doesn't match its cage
+// schema, or ErrDuplicate if it duplicates another at the unique paths of
+// its cage.
+func UpdateRecord(record *Record, actor string) error {
+	return UpdateRecordContext(context.Background(), record, actor)
+}
 
-	return cages, nil
+// UpdateRecordContext is like UpdateRecord, but is cancelled once ctx is done.
+func UpdateRecordContext(ctx context.Context, record *Record, actor string) error {
+	return db.TransactContext(ctx, func(tx *sql.Tx) error {
+		return UpdateRecordTx(db.WithContext(ctx, tx), record, actor)
+	})
 }
 
-// UpdateRecord updates an existing record in the database.
-func UpdateRecord(record *Record) error {
-	stmt := table.Record.UPDATE(table.Record.Data).
-		MODEL(record).
-		WHERE(table.Record.UUID.EQ(postgres.UUID(record.UUID)))
+// UpdateRecordTx updates an existing record using the given executor, allowing
+// the update to take part in a wider transaction.
+func UpdateRecordTx(exec db.Executor, record *Record, actor string) error {
+	return updateRecordTx(exec, record, RevisionUpdate, actor)
+}
 
-	_, err := stmt.Exec(db.SQLDB)
-	if err != nil {
+// updateRecordTx updates an existing record, recording the change in its
+// history as action. The update only succeeds if the record is still at the
+// version it was read at, after which its version is incremented.
+func updateRecordTx(exec db.Executor, record *Record, action, actor string) error {
+	if err := ValidateRecord(record); err != nil {
 		return err
 	}
 
-	return nil
-}
-
-// DeleteRecord deletes a record from the database by its UUID.
-func DeleteRecord(uuid db.UUID) error {
-	stmt := table.Record.DELETE().
-		WHERE(table.Record.UUID.EQ(postgres.UUID(uuid)))
+	expected := record.Version
+	record.UpdatedAt = time.Now().UTC()
+	record.Version++
 
-	_, err := stmt.Exec(db.SQLDB)
+	ok, err := store.UpdateRecord(exec, record, expected, action, actor)
 	if err != nil {
-		return err
+		record.Version = expected
+		return duplicateError(err)
+	} else if !ok {
+		// The record changed since it was read
+		record.Version = expected
+		return ErrVersionMismatch
 	}
 
 	return nil
 }
 
-// DeleteCage deletes all records belonging to a common cage from the database.
-// Returns the number of deleted records.
-func DeleteCage(cage string) (int64, error) {
-	stmt := table.Record.DELETE().
-		WHERE(table.Record.Cage.EQ(postgres.String(cage)))
+// DeleteRecord moves a record to the trash by its UUID, recording actor in
+// its history. Trashed records may be restored until they are purged. If
+// version is not zero, the record is only deleted if it is at that version,
+// returning ErrVersionMismatch otherwise.
+func DeleteRecord(uuid db.UUID, version int32, actor string) error {
+	return DeleteRecordContext(context.Background(), uuid, version, actor)
+}
+
+// DeleteRecordContext is like DeleteRecord, but is cancelled once ctx is done.
+func DeleteRecordContext(ctx context.Context, uuid db.UUID, version int32, actor string) error {
+	return db.TransactContext(ctx, func(tx *sql.Tx) error {
+		return DeleteRecordTx(db.WithContext(ctx, tx), uuid, version, actor)
+	})
+}
 
-	res, err := stmt.Exec(db.SQLDB)
+// DeleteRecordTx moves a record to the trash by its UUID using the given
+// executor, allowing the delete to take part in a wider transaction.
+func DeleteRecordTx(exec db.Executor, uuid db.UUID, version int32, actor string) error {
+	trashed, err := store.TrashRecord(exec, uuid, version, actor)
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	count, err := res.RowsAffected()
-	if err != nil {
-		return 0, err
+	if version != 0 && len(trashed) == 0 {
+		return ErrVersionMismatch
 	}
 
-	return count, nil
+	return nil
+}
+
+// DeleteCage moves all records belonging to a common cage to the trash,
+// recording actor in their history. Returns the trashed records.
+func DeleteCage(cage string, actor string) ([]*Record, error) {
+	return DeleteCageContext(context.Background(), cage, actor)
+}
+
+// DeleteCageContext is like DeleteCage, but is cancelled once ctx is done.
+func DeleteCageContext(ctx context.Context, cage string, actor string) ([]*Record, error) {
+	var trashed []*Record
+	err := db.TransactContext(ctx, func(tx *sql.Tx) error {
+		var err error
+		trashed, err = DeleteCageTx(db.WithContext(ctx, tx), cage, actor)
+		return err
+	})
+	return trashed, err
+}
+
+// DeleteCageTx moves all records belonging to a common cage to the trash using
+// the given executor, allowing the delete to take part in a wider transaction.
+func DeleteCageTx(exec db.Executor, cage string, actor string) ([]*Record, error) {
+	return store.TrashCage(exec, cage, actor)
 }
@@ -0,0 +1,102 @@
+package cage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/db"
+	"github.com/segmentio/ksuid"
+)
+
+// Event actions, describing the change to a record which produced an event.
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventRestore = "restore"
+	EventPurge   = "purge"
+)
+
+var ErrEventNotFound = errors.New("event not found")
+
+// Event is a change to a caged record, kept for a while so that change
+// streams may resume from the last event they saw. Event UUIDs are KSUIDs,
+// which only sort roughly by the time they were published, so streams resume
+// on Seq, a sequence number assigned by the database as events are recorded.
+//
+// Postgres assigns sequence numbers as events are inserted rather than as
+// they are committed, so an event of a transaction still open when a stream
+// is resumed may be skipped, though live streams still receive it.
+type Event = model.RecordEvent
+
+// newEvents returns an event for each of records, ordered as records are.
+func newEvents(action, actor string, records []*Record) []*Event {
+	// KSUIDs made within the same second sort randomly, so sort them to
+	// keep the events in order
+	uuids := make([]ksuid.KSUID, len(records))
+	for i := range uuids {
+		uuids[i] = ksuid.New()
+	}
+	ksuid.Sort(uuids)
+
+	now := time.Now().UTC()
+	events := make([]*Event, len(records))
+	for i, record := range records {
+		events[i] = &Event{
+			UUID:       db.UUID{KSUID: uuids[i]},
+			RecordUUID: record.UUID,
+			Cage:       record.Cage,
+			Action:     action,
+			Version:    record.Version,
+			Data:       record.Data,
+			Actor:      &actor,
+			CreatedAt:  now,
+		}
+	}
+
+	return events
+}
+
+// GetEvent retrieves an event by its UUID. Returns ErrEventNotFound if there
+// is no such event, e.g. because it has been pruned.
+func GetEvent(uuid db.UUID) (*Event, error) {
+	return GetEventContext(context.Background(), uuid)
+}
+
+// GetEventContext is like GetEvent, but is cancelled once ctx is done.
+func GetEventContext(ctx context.Context, uuid db.UUID) (*Event, error) {
+	return store.GetEvent(db.WithContext(ctx, db.SQLDB), uuid)
+}
+
+// ListEvents retrieves the events of a cage published after the event with
+// the given UUID, oldest first. Every event still kept is returned if the
+// given event has been pruned.
+func ListEvents(cage string, after db.UUID) ([]*Event, error) {
+	return ListEventsContext(context.Background(), cage, after)
+}
+
+// ListEventsContext is like ListEvents, but is cancelled once ctx is done.
+func ListEventsContext(ctx context.Context, cage string, after db.UUID) ([]*Event, error) {
+	return store.ListEvents(db.WithContext(ctx, db.SQLDB), cage, after)
+}
+
+// PruneEvents deletes events published before a time, after which change
+// streams can no longer resume from them. Returns the number deleted.
+func PruneEvents(before time.Time) (int64, error) {
+	return PruneEventsContext(context.Background(), before)
+}
+
+// PruneEventsContext is like PruneEvents, but is cancelled once ctx is done.
+func PruneEventsContext(ctx context.Context, before time.Time) (int64, error) {
+	return store.PruneEvents(db.WithContext(ctx, db.SQLDB), before)
+}
+
+// WatchEvents calls fn with each event as it is published by any process
+// sharing the database, until ctx is done. fn is called with nil once
+// watching has started, and again if events may have been missed, e.g.
+// while reconnecting to the database.
+func WatchEvents(ctx context.Context, fn func(*Event)) error {
+	return store.WatchEvents(ctx, fn)
+}
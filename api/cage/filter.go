@@ -0,0 +1,520 @@
+package cage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/sqlite"
+)
+
+var ErrBadFilter = errors.New("bad filter")
+
+// pathSegment matches a single segment of a dotted JSON path.
+var pathSegment = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Filter is a parsed record filter, selecting records by their data.
+// See ParseFilter for syntax.
+type Filter struct {
+	root filterNode
+}
+
+// filterNode is a node in a parsed filter expression tree.
+type filterNode interface {
+	// postgres returns the node as a condition on the record data column.
+	postgres() postgres.BoolExpression
+	// sqlite returns the node as a condition on the record data column,
+	// using the SQLite JSON functions.
+	sqlite() sqlite.BoolExpression
+}
+
+// ParseFilter parses a filter expression made up of comparisons between
+// dotted JSON paths into the record data and JSON values, for example:
+//
+//	status = "approved" and (age >= 18 or guardian exists) and not email ~ example.com
+//
+// Supported operators are = and != (JSON equality), <, <=, > and >= (ordering
+// between values of the same JSON type), ~ (case-insensitive substring match)
+// and the postfix exists. Like the other comparisons, != only matches records
+// holding a value at the path, so use "not path = value" to also match
+// records missing it. Comparisons may be combined with and, or, not and
+// parentheses. Values are JSON strings, numbers, true, false or null; bare
+// words are treated as strings. Numeric path segments index into arrays.
+//
+// A mini-language is used rather than expr-lang, as used by hook conditions,
+// so that every filter can be translated into indexable JSONB operators.
+func ParseFilter(s string) (*Filter, error) {
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrBadFilter, tok.text, tok.pos)
+	}
+
+	return &Filter{root: root}, nil
+}
+
+// ParsePath parses a dotted JSON path into its segments.
+func ParsePath(s string) ([]string, error) {
+	segments := strings.Split(s, ".")
+	for _, segment := range segments {
+		if !pathSegment.MatchString(segment) {
+			return nil, fmt.Errorf("%w: invalid path %q", ErrBadFilter, s)
+		}
+	}
+	return segments, nil
+}
+
+// sqlPath returns a path in the Postgres text array form accepted by #>.
+func sqlPath(path []string) string {
+	return "{" + strings.Join(path, ",") + "}"
+}
+
+// jsonPath returns a path in the form accepted by the SQLite JSON functions.
+// Numeric segments index into arrays.
+func jsonPath(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, segment := range path {
+		if _, err := strconv.Atoi(segment); err == nil {
+			b.WriteString("[" + segment + "]")
+		} else {
+			b.WriteString(`."` + segment + `"`)
+		}
+	}
+	return b.String()
+}
+
+// hasIndex returns whether any segment of path is numeric, indexing into an
+// array.
+func hasIndex(path []string) bool {
+	for _, segment := range path {
+		if _, err := strconv.Atoi(segment); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// nestValue returns value nested in objects along path, such that the result
+// contains value at path.
+func nestValue(path []string, value any) any {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = map[string]any{path[i]: value}
+	}
+	return value
+}
+
+// filterAnd matches records matching both sides.
+type filterAnd struct {
+	left, right filterNode
+}
+
+func (n *filterAnd) postgres() postgres.BoolExpression {
+	return n.left.postgres().AND(n.right.postgres())
+}
+
+func (n *filterAnd) sqlite() sqlite.BoolExpression {
+	return n.left.sqlite().AND(n.right.sqlite())
+}
+
+// filterOr matches records matching either side.
+type filterOr struct {
+	left, right filterNode
+}
+
+func (n *filterOr) postgres() postgres.BoolExpression {
+	return n.left.postgres().OR(n.right.postgres())
+}
+
+func (n *filterOr) sqlite() sqlite.BoolExpression {
+	return n.left.sqlite().OR(n.right.sqlite())
+}
+
+// filterNot matches records not matching the inner node.
+type filterNot struct {
+	inner filterNode
+}
+
+func (n *filterNot) postgres() postgres.BoolExpression {
+	return postgres.NOT(n.inner.postgres())
+}
+
+func (n *filterNot) sqlite() sqlite.BoolExpression {
+	return sqlite.NOT(n.inner.sqlite())
+}
+
+// filterExists matches records containing a value at path.
+type filterExists struct {
+	path []string
+}
+
+func (n *filterExists) postgres() postgres.BoolExpression {
+	if hasIndex(n.path) {
+		// ? matches array elements rather than indexes
+		return postgres.RawBool("record.data #> #path::text[] IS NOT NULL", postgres.RawArgs{"#path": sqlPath(n.path)})
+	}
+
+	key := n.path[len(n.path)-1]
+	if len(n.path) == 1 {
+		return postgres.RawBool("record.data ? #key", postgres.RawArgs{"#key": key})
+	}
+
+	return postgres.RawBool("(record.data #> #parent::text[]) ? #key", postgres.RawArgs{
+		"#parent": sqlPath(n.path[:len(n.path)-1]),
+		"#key":    key,
+	})
+}
+
+func (n *filterExists) sqlite() sqlite.BoolExpression {
+	return sqlite.RawBool("json_type(record.data, #path) IS NOT NULL", sqlite.RawArgs{"#path": jsonPath(n.path)})
+}
+
+// filterCompare matches records by comparing the value at path with a
+// JSON value.
+type filterCompare struct {
+	path  []string
+	op    string
+	value any
+}
+
+func (n *filterCompare) postgres() postgres.BoolExpression {
+	switch n.op {
+	case "=", "!=":
+		var cond postgres.BoolExpression
+		if hasIndex(n.path) {
+			// Containment would treat indexes as object keys, so compare
+			// the value at the path as the other operators do
+			value, _ := json.Marshal(n.value)
+			cond = postgres.RawBool("record.data #> #path::text[] = #value::jsonb", postgres.RawArgs{
+				"#path":  sqlPath(n.path),
+				"#value": string(value),
+			})
+		} else {
+			// Containment is able to use the GIN index on record data
+			doc, _ := json.Marshal(nestValue(n.path, n.value))
+			cond = postgres.RawBool("record.data @> #doc::jsonb", postgres.RawArgs{"#doc": string(doc)})
+		}
+		if n.op == "!=" {
+			// Records missing the value don't match, as with other operators
+			exists := &filterExists{path: n.path}
+			return exists.postgres().AND(postgres.NOT(cond))
+		}
+		return cond
+	case "~":
+		pattern := "%" + likeEscaper.Replace(fmt.Sprint(n.value)) + "%"
+		return postgres.RawBool("record.data #>> #path::text[] ILIKE #pattern", postgres.RawArgs{
+			"#path":    sqlPath(n.path),
+			"#pattern": pattern,
+		})
+	default:
+		// JSONB values of different types are ordered by type, so only
+		// compare values of the same type
+		value, _ := json.Marshal(n.value)
+		return postgres.RawBool(
+			"jsonb_typeof(record.data #> #path::text[]) = jsonb_typeof(#value::jsonb) AND record.data #> #path::text[] "+n.op+" #value::jsonb",
+			postgres.RawArgs{"#path": sqlPath(n.path), "#value": string(value)},
+		)
+	}
+}
+
+func (n *filterCompare) sqlite() sqlite.BoolExpression {
+	args := sqlite.RawArgs{"#path": jsonPath(n.path)}
+
+	if n.op == "~" {
+		args["#pattern"] = "%" + likeEscaper.Replace(fmt.Sprint(n.value)) + "%"
+		return sqlite.RawBool(`json_extract(record.data, #path) LIKE #pattern ESCAPE '\'`, args)
+	}
+
+	// Only compare values of the same JSON type, as Postgres does
+	typeOf, value := "json_type(record.data, #path) = 'null'", "0"
+	switch v := n.value.(type) {
+	case string:
+		typeOf, value = "json_type(record.data, #path) = 'text'", "json_extract(record.data, #path)"
+		args["#value"] = v
+	case json.Number:
+		typeOf, value = "json_type(record.data, #path) IN ('integer', 'real')", "json_extract(record.data, #path)"
+		args["#value"], _ = v.Float64()
+	case bool:
+		typeOf, value = "json_type(record.data, #path) IN ('true', 'false')", "json_extract(record.data, #path)"
+		args["#value"] = 0
+		if v {
+			args["#value"] = 1
+		}
+	default:
+		args["#value"] = 0
+	}
+
+	op := n.op
+	if op == "!=" {
+		op = "="
+	}
+	cond := typeOf + " AND " + value + " " + op + " #value"
+
+	switch n.op {
+	case "=":
+		// Missing values never match, rather than comparing as NULL
+		return sqlite.RawBool("IFNULL("+cond+", FALSE)", args)
+	case "!=":
+		// Records missing the value don't match, as with other operators
+		return sqlite.RawBool("json_type(record.data, #path) IS NOT NULL AND NOT IFNULL("+cond+", FALSE)", args)
+	default:
+		return sqlite.RawBool(cond, args)
+	}
+}
+
+// likeEscaper escapes LIKE pattern metacharacters.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Filter token kinds.
+const (
+	tokenEOF = iota
+	tokenWord
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+// filterToken is a lexical token of a filter expression.
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// lexFilter splits a filter expression into tokens.
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, filterToken{tokenLParen, "(", start})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{tokenRParen, ")", start})
+			i++
+		case r == '"':
+			// Find the closing quote, skipping escaped characters
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("%w: unterminated string at position %d", ErrBadFilter, start)
+			}
+			i++
+			tokens = append(tokens, filterToken{tokenString, string(runes[start:i]), start})
+		case strings.ContainsRune("=!<>~", r):
+			i++
+			if i < len(runes) && runes[i] == '=' && r != '=' && r != '~' {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("%w: unexpected \"!\" at position %d", ErrBadFilter, start)
+			}
+			tokens = append(tokens, filterToken{tokenOp, op, start})
+		case r == '-' || unicode.IsDigit(r):
+			i++
+			for i < len(runes) && strings.ContainsRune("0123456789.eE+-", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{tokenNumber, string(runes[start:i]), start})
+		case r == '_' || r == '.' || unicode.IsLetter(r):
+			for i < len(runes) && (runes[i] == '_' || runes[i] == '-' || runes[i] == '.' || runes[i] == '@' ||
+				unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, filterToken{tokenWord, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrBadFilter, r, start)
+		}
+	}
+
+	return append(tokens, filterToken{tokenEOF, "end of filter", len(runes)}), nil
+}
+
+// filterParser is a recursive descent parser for filter expressions.
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// peek returns the next token without consuming it.
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the next token.
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// keyword consumes the next token if it is the given keyword.
+func (p *filterParser) keyword(word string) bool {
+	tok := p.peek()
+	if tok.kind == tokenWord && strings.EqualFold(tok.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// parseOr parses: and ("or" and)*
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterOr{left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses: unary ("and" unary)*
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &filterAnd{left, right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses: "not" unary | "(" or ")" | comparison
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.keyword("not") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &filterNot{inner}, nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokenRParen {
+			return nil, fmt.Errorf("%w: expected \")\" at position %d, got %q", ErrBadFilter, tok.pos, tok.text)
+		}
+		return inner, nil
+	}
+
+	return p.parseComparison()
+}
+
+// parseComparison parses: path "exists" | path op value
+func (p *filterParser) parseComparison() (filterNode, error) {
+	tok := p.next()
+	if tok.kind != tokenWord {
+		return nil, fmt.Errorf("%w: expected path at position %d, got %q", ErrBadFilter, tok.pos, tok.text)
+	}
+
+	path, err := ParsePath(tok.text)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.keyword("exists") {
+		return &filterExists{path}, nil
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, fmt.Errorf("%w: expected operator at position %d, got %q", ErrBadFilter, op.pos, op.text)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	if op.text == "~" {
+		switch value.(type) {
+		case string, json.Number:
+		default:
+			return nil, fmt.Errorf("%w: operator ~ at position %d requires a string or number", ErrBadFilter, op.pos)
+		}
+	}
+
+	return &filterCompare{path: path, op: op.text, value: value}, nil
+}
+
+// parseValue parses a JSON literal or bare word.
+func (p *filterParser) parseValue() (any, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenString:
+		var value string
+		if err := json.Unmarshal([]byte(tok.text), &value); err != nil {
+			return nil, fmt.Errorf("%w: invalid string at position %d", ErrBadFilter, tok.pos)
+		}
+		return value, nil
+	case tokenNumber:
+		var value json.Number
+		if err := json.Unmarshal([]byte(tok.text), &value); err != nil {
+			return nil, fmt.Errorf("%w: invalid number %q at position %d", ErrBadFilter, tok.text, tok.pos)
+		}
+		return value, nil
+	case tokenWord:
+		switch tok.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return tok.text, nil
+	}
+
+	return nil, fmt.Errorf("%w: expected value at position %d, got %q", ErrBadFilter, tok.pos, tok.text)
+}
@@ -0,0 +1,73 @@
+package cage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-jet/jet/v2/postgres"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		filter string
+		bad    bool
+	}{
+		{`status = approved`, false},
+		{`status = "approved" and (age >= 18 or guardian exists) and not email ~ example.com`, false},
+		{`tags.0 != null`, false},
+		{`address.country ~ 12`, false},
+		{`status =`, true},
+		{`= approved`, true},
+		{`status ! approved`, true},
+		{`status = "approved`, true},
+		{`(status = approved`, true},
+		{`status = approved)`, true},
+		{`status..name = approved`, true},
+		{`status ~ true`, true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.filter)
+		if tt.bad && !errors.Is(err, ErrBadFilter) {
+			t.Errorf("%s: error = %v, want ErrBadFilter", tt.filter, err)
+		} else if !tt.bad && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.filter, err)
+		}
+	}
+}
+
+func TestFilterPostgres(t *testing.T) {
+	tests := []struct {
+		filter string
+		where  string
+		args   []any
+	}{
+		{`status = approved`, `record.data @> $2::jsonb`, []any{`{"status":"approved"}`}},
+		{`address.country = "NZ"`, `record.data @> $2::jsonb`, []any{`{"address":{"country":"NZ"}}`}},
+		{`tags.0 = x`, `record.data #> $2::text[] = $3::jsonb`, []any{`{tags,0}`, `"x"`}},
+		{`status != approved`, `(record.data ? $2) AND (NOT (record.data @> $3::jsonb))`, []any{`status`, `{"status":"approved"}`}},
+		{`tags.0 != x`, `(record.data #> $2::text[] IS NOT NULL) AND (NOT (record.data #> $3::text[] = $4::jsonb))`, []any{`{tags,0}`, `{tags,0}`, `"x"`}},
+		{`address.country exists`, `(record.data #> $2::text[]) ? $3`, []any{`{address}`, `country`}},
+		{`tags.1 exists`, `record.data #> $2::text[] IS NOT NULL`, []any{`{tags,1}`}},
+		{`age >= 18`, `jsonb_typeof(record.data #> $2::text[]) = jsonb_typeof($3::jsonb) AND record.data #> $2::text[] >= $3::jsonb`, []any{`{age}`, `18`}},
+		{`email ~ "50%"`, `record.data #>> $2::text[] ILIKE $3`, []any{`{email}`, `%50\%%`}},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sql, args := postgres.SELECT(postgres.Int(1)).WHERE(filter.root.postgres()).Sql()
+		where := strings.TrimSuffix(sql[strings.Index(sql, "WHERE ")+len("WHERE "):], ";\n")
+		if where != tt.where {
+			t.Errorf("%s: WHERE %s, want %s", tt.filter, where, tt.where)
+		}
+		if got, want := fmt.Sprint(args[1:]), fmt.Sprint(tt.args); got != want {
+			t.Errorf("%s: args %s, want %s", tt.filter, got, want)
+		}
+	}
+}
@@ -0,0 +1,431 @@
+package cage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+// Import formats.
+const (
+	FormatNDJSON = "ndjson" // One JSON object per line
+	FormatJSON   = "json"   // A top-level JSON array of objects
+	FormatCSV    = "csv"    // A header row naming fields, then one record per row
+)
+
+var ErrBadFormat = errors.New("bad format")
+
+// DefaultImportBatch is the number of records inserted per transaction when
+// ImportOptions.BatchSize is unset.
+const DefaultImportBatch = 500
+
+// maxImportLine is the longest NDJSON line accepted by an import.
+const maxImportLine = 16 << 20
+
+// ImportOptions configures an import.
+type ImportOptions struct {
+	// Format is the input format. If empty, it is detected from the input.
+	Format string
+
+	// Mapping maps CSV columns to dotted JSON paths in the record data.
+	// Unmapped columns are stored at the path named by their header, and
+	// columns mapped to "-" are skipped.
+	Mapping map[string]string
+
+	// Coerce converts CSV values which look like numbers, booleans or null
+	// into those types, rather than storing every value as a string.
+	Coerce bool
+
+	// BatchSize is the number of records inserted per transaction.
+	BatchSize int
+
+	// OnBatch is called with the executor of the transaction inserting each
+	// batch of records, e.g. to queue their hooks. An error aborts the batch.
+	OnBatch func(exec db.Executor, records []*Record) error
+}
+
+// ImportError describes an input which failed to import. Line is the line
+// number for NDJSON and CSV input, or the position of the element for
+// JSON arrays.
+type ImportError struct {
+	Line  int    `json:"line"`
+	Err   error  `json:"-"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarizes an import.
+type ImportResult struct {
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors"`
+}
+
+// fail records an error for an input line.
+func (r *ImportResult) fail(line int, err error) {
+	r.Errors = append(r.Errors, ImportError{Line: line, Err: err, Error: err.Error()})
+}
+
+// DetectFormat guesses the format of input from its first non-space byte:
+// "[" for a JSON array, "{" for NDJSON, and CSV otherwise.
+func DetectFormat(peek []byte) string {
+	trimmed := bytes.TrimLeft(peek, " \t\r\n\ufeff")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return FormatJSON
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return FormatNDJSON
+	default:
+		return FormatCSV
+	}
+}
+
+// importSource yields the records of an import input in order. Returns
+// io.EOF once the input is exhausted. A non-nil error alongside a positive
+// line is a problem with that line alone, after which reading may continue.
+type importSource interface {
+	next() (line int, data db.JSONB, err error)
+}
+
+// ImportRecords streams records into a cage from r, inserting them in batched
+// transactions and recording actor in their history. Inputs which are
+// malformed or don't match the cage schema are reported in the result rather
+// than aborting the import, as are batches which fail to insert. Inputs which
+// duplicate an existing record or an earlier input at the unique paths of the
+// cage are also reported, rather than merged, unless the cage ignores them. An
+// error is only returned if the input can't be read at all.
+func ImportRecords(r io.Reader, cage string, opts ImportOptions, actor string) (*ImportResult, error) {
+	return ImportRecordsContext(context.Background(), r, cage, opts, actor)
+}
+
+// ImportRecordsContext is like ImportRecords, but stops once ctx is done,
+// returning the result so far along with the context error. Batches already
+// inserted are kept.
+func ImportRecordsContext(ctx context.Context, r io.Reader, cage string, opts ImportOptions, actor string) (*ImportResult, error) {
+	reader := bufio.NewReader(r)
+	if opts.Format == "" {
+		peek, _ := reader.Peek(512)
+		opts.Format = DetectFormat(peek)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatch
+	}
+
+	var source importSource
+	var err error
+	switch opts.Format {
+	case FormatNDJSON:
+		source = newNDJSONSource(reader)
+	case FormatJSON:
+		source, err = newJSONSource(reader)
+	case FormatCSV:
+		source, err = newCSVSource(reader, opts.Mapping, opts.Coerce)
+	default:
+		err = fmt.Errorf("%w: %q", ErrBadFormat, opts.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Errors: make([]ImportError, 0)}
+	batch := make([]*Record, 0, opts.BatchSize)
+	lines := make([]int, 0, opts.BatchSize)
+
+	// Duplicates of earlier lines can't be found in the database until their
+	// batch is inserted, so their unique values are tracked as they are read
+	seen := make(map[string]int)
+	ignore := false
+	if def := GetDefinition(cage); def != nil {
+		ignore = def.OnConflict == ConflictIgnore
+	}
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		err := db.TransactContext(ctx, func(tx *sql.Tx) error {
+			exec := db.WithContext(ctx, tx)
+			if err := insertRecordsTx(exec, batch, actor); err != nil {
+				return err
+			}
+			if opts.OnBatch != nil {
+				return opts.OnBatch(exec, batch)
+			}
+			return nil
+		})
+		if err != nil {
+			for _, line := range lines {
+				result.fail(line, err)
+			}
+		} else {
+			result.Imported += len(batch)
+		}
+
+		batch = make([]*Record, 0, opts.BatchSize)
+		lines = lines[:0]
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		line, data, err := source.next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil && line == 0 {
+			// The input can no longer be read
+			flush()
+			return result, err
+		} else if err != nil {
+			result.fail(line, err)
+			continue
+		}
+
+		record := NewRecord(cage, data)
+		if err := ValidateRecord(record); err != nil {
+			result.fail(line, err)
+			continue
+		}
+		if err := checkDuplicate(ctx, record, line, seen); errors.Is(err, ErrDuplicate) && ignore {
+			continue
+		} else if err != nil {
+			result.fail(line, err)
+			continue
+		}
+
+		batch = append(batch, record)
+		lines = append(lines, line)
+		if len(batch) == opts.BatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return result, ctx.Err()
+}
+
+// checkDuplicate returns ErrDuplicate if the record read from an input line
+// duplicates an existing record, or that of an earlier line in seen, at the
+// unique paths of its cage. Otherwise, its unique values are added to seen.
+func checkDuplicate(ctx context.Context, record *Record, line int, seen map[string]int) error {
+	def := GetDefinition(record.Cage)
+	if def == nil || len(def.Unique) == 0 {
+		return nil
+	}
+
+	paths, err := uniquePaths(def)
+	if err != nil {
+		return err
+	}
+
+	values, ok := uniqueValues(record, paths)
+	if !ok {
+		return nil
+	}
+
+	key := strings.Join(values, "\x00")
+	if earlier, ok := seen[key]; ok {
+		return fmt.Errorf("%w: matches line %d at %s", ErrDuplicate, earlier, strings.Join(def.Unique, ", "))
+	}
+
+	existing, err := store.FindDuplicate(db.WithContext(ctx, db.SQLDB), record.Cage, paths, values, false)
+	if err == nil {
+		return fmt.Errorf("%w: matches record %s at %s", ErrDuplicate, existing.UUID, strings.Join(def.Unique, ", "))
+	} else if !errors.Is(err, ErrRecordNotFound) {
+		return err
+	}
+
+	seen[key] = line
+	return nil
+}
+
+// ndjsonSource reads one JSON object per line, skipping blank lines.
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONSource(r io.Reader) *ndjsonSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLine)
+	return &ndjsonSource{scanner: scanner}
+}
+
+func (s *ndjsonSource) next() (int, db.JSONB, error) {
+	for s.scanner.Scan() {
+		s.line++
+		text := bytes.TrimSpace(s.scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		data, err := decodeObject(text)
+		return s.line, data, err
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return 0, nil, fmt.Errorf("line %d: %w", s.line+1, err)
+	}
+	return 0, nil, io.EOF
+}
+
+// jsonSource reads the elements of a top-level JSON array.
+type jsonSource struct {
+	decoder *json.Decoder
+	item    int
+	done    bool
+}
+
+func newJSONSource(r io.Reader) (*jsonSource, error) {
+	decoder := json.NewDecoder(r)
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('[') {
+		return nil, fmt.Errorf("%w: expected a JSON array", ErrBadFormat)
+	}
+	return &jsonSource{decoder: decoder}, nil
+}
+
+func (s *jsonSource) next() (int, db.JSONB, error) {
+	if s.done || !s.decoder.More() {
+		s.done = true
+		return 0, nil, io.EOF
+	}
+
+	s.item++
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		// The decoder can't recover from a syntax error
+		s.done = true
+		return 0, nil, fmt.Errorf("element %d: %w", s.item, err)
+	}
+
+	data, err := decodeObject(raw)
+	return s.item, data, err
+}
+
+// decodeObject decodes a JSON object into record data.
+func decodeObject(raw []byte) (db.JSONB, error) {
+	var data db.JSONB
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("invalid JSON object: %w", err)
+	}
+	if data == nil {
+		return nil, errors.New("invalid JSON object: got null")
+	}
+	return data, nil
+}
+
+// csvSource reads CSV rows, storing each column at a path in record data.
+type csvSource struct {
+	reader *csv.Reader
+	paths  [][]string // Path of each column, or nil if skipped
+	coerce bool
+}
+
+func newCSVSource(r io.Reader, mapping map[string]string, coerce bool) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 0 // Every row must match the header
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: missing CSV header", ErrBadFormat)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadFormat, err)
+	}
+
+	paths := make([][]string, len(header))
+	for i, column := range header {
+		column = strings.TrimPrefix(strings.TrimSpace(column), "\ufeff")
+		target := column
+		if mapped, ok := mapping[column]; ok {
+			target = mapped
+		}
+		if target == "-" {
+			continue
+		}
+
+		if paths[i], err = ParsePath(target); err != nil {
+			return nil, fmt.Errorf("%w: column %q: %v", ErrBadFormat, column, err)
+		}
+	}
+
+	return &csvSource{reader: reader, paths: paths, coerce: coerce}, nil
+}
+
+func (s *csvSource) next() (int, db.JSONB, error) {
+	row, err := s.reader.Read()
+	if errors.Is(err, io.EOF) {
+		return 0, nil, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.Line, nil, parseErr.Err
+	} else if err != nil {
+		return 0, nil, err
+	}
+
+	line, _ := s.reader.FieldPos(0)
+	data := make(db.JSONB)
+	for i, value := range row {
+		if s.paths[i] == nil || value == "" {
+			continue // Skipped column or empty cell
+		}
+
+		var v any = value
+		if s.coerce {
+			v = coerceValue(value)
+		}
+		if err := setPath(data, s.paths[i], v); err != nil {
+			return line, nil, err
+		}
+	}
+
+	return line, data, nil
+}
+
+// coerceValue converts a CSV value which looks like a number, boolean or null
+// into that type, otherwise returning the value as a string.
+func coerceValue(value string) any {
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return n
+	}
+	return value
+}
+
+// setPath stores value at path in data, creating intermediate objects.
+func setPath(data map[string]any, path []string, value any) error {
+	for i, key := range path[:len(path)-1] {
+		child, ok := data[key]
+		if !ok {
+			child = make(map[string]any)
+			data[key] = child
+		}
+
+		data, ok = child.(map[string]any)
+		if !ok {
+			return fmt.Errorf("path %s conflicts with another column", strings.Join(path[:i+1], "."))
+		}
+	}
+
+	data[path[len(path)-1]] = value
+	return nil
+}
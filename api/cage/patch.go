@@ -0,0 +1,86 @@
+package cage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Patch formats, identified by their media types.
+const (
+	MergePatch = "application/merge-patch+json" // RFC 7386
+	JSONPatch  = "application/json-patch+json"  // RFC 6902
+)
+
+var (
+	ErrBadPatch      = errors.New("bad patch")
+	ErrPatchConflict = errors.New("patch conflicts with record")
+)
+
+// ApplyPatch returns the result of applying a patch in the given format to
+// record data, leaving data unchanged. Returns ErrBadPatch if the patch is
+// malformed or produces something other than a JSON object, or
+// ErrPatchConflict if a JSON Patch operation cannot be applied to data.
+func ApplyPatch(data db.JSONB, format string, patch []byte) (db.JSONB, error) {
+	doc, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	switch format {
+	case MergePatch:
+		if patched, err = jsonpatch.MergePatch(doc, patch); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadPatch, err)
+		}
+	case JSONPatch:
+		ops, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrBadPatch, err)
+		}
+		if patched, err = ops.Apply(doc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPatchConflict, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrBadPatch, format)
+	}
+
+	var result db.JSONB
+	if err := json.Unmarshal(patched, &result); err != nil || result == nil {
+		return nil, fmt.Errorf("%w: result is not a JSON object", ErrBadPatch)
+	}
+
+	return result, nil
+}
+
+// PatchRecordTx applies a patch in the given format to the data of a record
+// by its UUID using the given executor, recording actor in its history. The
+// record is locked while the patch is applied, so concurrent patches to
+// different fields are never lost, and so should be called within a
+// transaction. If version is not zero, the record is only patched if it is at
+// that version, returning ErrVersionMismatch otherwise. Returns the patched
+// record and its data prior to the patch.
+func PatchRecordTx(exec db.Executor, uuid db.UUID, version int32, format string, patch []byte, actor string) (*Record, db.JSONB, error) {
+	record, err := store.GetRecord(exec, uuid, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if version != 0 && record.Version != version {
+		return nil, nil, ErrVersionMismatch
+	}
+
+	before := record.Data
+	if record.Data, err = ApplyPatch(before, format, patch); err != nil {
+		return nil, nil, err
+	}
+
+	if err := UpdateRecordTx(exec, record, actor); err != nil {
+		return nil, nil, err
+	}
+
+	return record, before, nil
+}
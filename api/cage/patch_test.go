@@ -0,0 +1,60 @@
+package cage
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		patch  string
+		want   string
+		err    error
+	}{
+		{"merge sets and removes", MergePatch, `{"status": "approved", "name": null}`, `{"status": "approved", "address": {"country": "NZ"}}`, nil},
+		{"merge nested", MergePatch, `{"address": {"city": "Wellington"}}`, `{"status": "pending", "name": "Ann", "address": {"country": "NZ", "city": "Wellington"}}`, nil},
+		{"merge array", MergePatch, `["status"]`, ``, ErrBadPatch},
+		{"merge string", MergePatch, `"approved"`, ``, ErrBadPatch},
+		{"merge null", MergePatch, `null`, ``, ErrBadPatch},
+		{"merge malformed", MergePatch, `{"status":`, ``, ErrBadPatch},
+		{"json patch", JSONPatch, `[{"op": "replace", "path": "/status", "value": "approved"}, {"op": "remove", "path": "/name"}]`, `{"status": "approved", "address": {"country": "NZ"}}`, nil},
+		{"json patch test fails", JSONPatch, `[{"op": "test", "path": "/status", "value": "approved"}]`, ``, ErrPatchConflict},
+		{"json patch missing path", JSONPatch, `[{"op": "remove", "path": "/email"}]`, ``, ErrPatchConflict},
+		{"json patch replaces root", JSONPatch, `[{"op": "replace", "path": "", "value": [1]}]`, ``, ErrBadPatch},
+		{"json patch malformed", JSONPatch, `{"op": "remove"}`, ``, ErrBadPatch},
+		{"unsupported format", "application/json", `{}`, ``, ErrBadPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := db.JSONB{"status": "pending", "name": "Ann", "address": map[string]any{"country": "NZ"}}
+			got, err := ApplyPatch(data, tt.format, []byte(tt.patch))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			var want db.JSONB
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Errorf("patched %s, want %s", gotJSON, wantJSON)
+			}
+			if data["status"] != "pending" || data["name"] != "Ann" {
+				t.Errorf("data changed to %v", data)
+			}
+		})
+	}
+}
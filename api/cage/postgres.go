@@ -0,0 +1,642 @@
+package cage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/lib/pq"
+	"github.com/octacian/backroom/api/.gen/backroom/public/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// EventChannel is the Postgres notification channel on which the UUID of each
+// event is sent once the transaction publishing it commits.
+const EventChannel = "record_event"
+
+// listenerPing is how often an idle event listener checks its connection.
+const listenerPing = 90 * time.Second
+
+// PostgresStore is a Store backed by the Postgres database, making use of
+// JSONB operators and its GIN index on record data.
+type PostgresStore struct{}
+
+// InsertRecords implements Store.
+func (s *PostgresStore) InsertRecords(exec db.Executor, records []*Record, actor string) error {
+	uuids := make([]postgres.Expression, len(records))
+	for i, record := range records {
+		uuids[i] = postgres.UUID(record.UUID)
+	}
+
+	insert := table.Record.INSERT(table.Record.AllColumns).MODELS(records)
+	if _, err := insert.Exec(exec); err != nil {
+		return err
+	}
+
+	where := table.Record.UUID.IN(uuids...)
+	if err := s.insertRevisions(exec, where, RevisionCreate, actor); err != nil {
+		return err
+	}
+	return s.publish(exec, EventCreate, actor, records...)
+}
+
+// GetRecord implements Store.
+func (s *PostgresStore) GetRecord(exec db.Executor, uuid db.UUID, lock bool) (*Record, error) {
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(table.Record.UUID.EQ(postgres.UUID(uuid)).
+			AND(table.Record.DeletedAt.IS_NULL()))
+	if lock {
+		stmt = stmt.FOR(postgres.UPDATE())
+	}
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ListRecords implements Store.
+func (s *PostgresStore) ListRecords(exec db.Executor, cage string, query ListQuery) ([]*Record, error) {
+	where, err := s.where(cage, query)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(s.orderBy(query)...)
+
+	if query.Limit > 0 {
+		stmt = stmt.LIMIT(int64(query.Limit))
+	}
+
+	var records []*Record
+	err = stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ScanRecords implements Store, fetching records in batches from a
+// server-side cursor within tx.
+func (s *PostgresStore) ScanRecords(tx *sql.Tx, cage string, query ListQuery, fn func(*Record) error) error {
+	query.Cursor = ""
+	where, err := s.where(cage, query)
+	if err != nil {
+		return err
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.Record.UUID.ASC())
+
+	text, args := stmt.Sql()
+	if _, err := tx.Exec("DECLARE record_scan NO SCROLL CURSOR FOR "+text, args...); err != nil {
+		return err
+	}
+	defer tx.Exec("CLOSE record_scan")
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM record_scan", scanBatch)
+	for {
+		var records []*Record
+		if _, err := qrm.Query(context.Background(), tx, fetch, nil, &records); err != nil {
+			return err
+		}
+
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if len(records) < scanBatch {
+			return nil
+		}
+	}
+}
+
+// ListCages implements Store.
+func (s *PostgresStore) ListCages(exec db.Executor) ([]string, error) {
+	stmt := table.Record.SELECT(table.Record.Cage).DISTINCT().
+		WHERE(table.Record.DeletedAt.IS_NULL())
+
+	var cages []string
+	err := stmt.Query(exec, &cages)
+	if err != nil {
+		return nil, err
+	}
+
+	return cages, nil
+}
+
+// UpdateRecord implements Store.
+func (s *PostgresStore) UpdateRecord(exec db.Executor, record *Record, expected int32, action, actor string) (bool, error) {
+	stmt := table.Record.UPDATE(table.Record.Data, table.Record.UpdatedAt, table.Record.Version).
+		MODEL(record).
+		WHERE(table.Record.UUID.EQ(postgres.UUID(record.UUID)).
+			AND(table.Record.DeletedAt.IS_NULL()).
+			AND(table.Record.Version.EQ(postgres.Int32(expected))))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return false, err
+	}
+
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		return false, err
+	}
+
+	if err := s.createRevision(exec, record, action, actor); err != nil {
+		return false, err
+	}
+	return true, s.publish(exec, EventUpdate, actor, record)
+}
+
+// TrashRecord implements Store.
+func (s *PostgresStore) TrashRecord(exec db.Executor, uuid db.UUID, version int32, actor string) ([]*Record, error) {
+	where := table.Record.UUID.EQ(postgres.UUID(uuid))
+	if version != 0 {
+		where = where.AND(table.Record.Version.EQ(postgres.Int32(version)))
+	}
+	return s.trashRecords(exec, where, actor)
+}
+
+// TrashCage implements Store.
+func (s *PostgresStore) TrashCage(exec db.Executor, cage string, actor string) ([]*Record, error) {
+	return s.trashRecords(exec, table.Record.Cage.EQ(postgres.String(cage)), actor)
+}
+
+// trashRecords moves the records matching where to the trash, recording
+// actor in their history. Returns the trashed records.
+func (s *PostgresStore) trashRecords(exec db.Executor, where postgres.BoolExpression, actor string) ([]*Record, error) {
+	where = where.AND(table.Record.DeletedAt.IS_NULL())
+
+	// Keep the final data of each record in its history
+	if err := s.insertRevisions(exec, where, RevisionDelete, actor); err != nil {
+		return nil, err
+	}
+
+	stmt := table.Record.UPDATE(table.Record.DeletedAt).
+		SET(postgres.NOW()).
+		WHERE(where).
+		RETURNING(table.Record.AllColumns)
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.publish(exec, EventDelete, actor, records...); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetTrashedRecord implements Store.
+func (s *PostgresStore) GetTrashedRecord(exec db.Executor, uuid db.UUID) (*Record, error) {
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(table.Record.UUID.EQ(postgres.UUID(uuid)).
+			AND(table.Record.DeletedAt.IS_NOT_NULL()))
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ListTrash implements Store.
+func (s *PostgresStore) ListTrash(exec db.Executor, cage string) ([]*Record, error) {
+	where := table.Record.DeletedAt.IS_NOT_NULL()
+	if cage != "" {
+		where = where.AND(table.Record.Cage.EQ(postgres.String(cage)))
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.Record.DeletedAt.DESC(), table.Record.UUID.DESC())
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// RestoreRecord implements Store.
+func (s *PostgresStore) RestoreRecord(exec db.Executor, uuid db.UUID, actor string) (*Record, error) {
+	// Bump the version, so that the record doesn't match its ETag from
+	// before it was trashed
+	stmt := table.Record.UPDATE(table.Record.DeletedAt, table.Record.UpdatedAt, table.Record.Version).
+		SET(postgres.NULL, postgres.NOW(), table.Record.Version.ADD(postgres.Int32(1))).
+		WHERE(table.Record.UUID.EQ(postgres.UUID(uuid)).
+			AND(table.Record.DeletedAt.IS_NOT_NULL())).
+		RETURNING(table.Record.AllColumns)
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.createRevision(exec, &record, RevisionRestore, actor); err != nil {
+		return nil, err
+	}
+	if err := s.publish(exec, EventRestore, actor, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// PurgeTrash implements Store.
+func (s *PostgresStore) PurgeTrash(exec db.Executor, cage string, before time.Time, actor string) ([]*Record, error) {
+	where := table.Record.DeletedAt.LT_EQ(postgres.TimestampzT(before))
+	if cage != "" {
+		where = where.AND(table.Record.Cage.EQ(postgres.String(cage)))
+	}
+
+	stmt := table.Record.DELETE().
+		WHERE(where).
+		RETURNING(table.Record.AllColumns)
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	uuids := make([]postgres.Expression, len(records))
+	for i, record := range records {
+		uuids[i] = postgres.UUID(record.UUID)
+	}
+
+	history := table.RecordRevision.DELETE().
+		WHERE(table.RecordRevision.RecordUUID.IN(uuids...))
+
+	if _, err := history.Exec(exec); err != nil {
+		return nil, err
+	}
+
+	if err := s.publish(exec, EventPurge, actor, records...); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ListRevisions implements Store.
+func (s *PostgresStore) ListRevisions(exec db.Executor, uuid db.UUID) ([]*Revision, error) {
+	stmt := table.RecordRevision.SELECT(table.RecordRevision.AllColumns).
+		WHERE(table.RecordRevision.RecordUUID.EQ(postgres.UUID(uuid))).
+		ORDER_BY(table.RecordRevision.Revision.DESC())
+
+	var revisions []*Revision
+	err := stmt.Query(exec, &revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision implements Store.
+func (s *PostgresStore) GetRevision(exec db.Executor, uuid db.UUID, revision int32) (*Revision, error) {
+	stmt := table.RecordRevision.SELECT(table.RecordRevision.AllColumns).
+		WHERE(table.RecordRevision.RecordUUID.EQ(postgres.UUID(uuid)).
+			AND(table.RecordRevision.Revision.EQ(postgres.Int32(revision))))
+
+	var rev Revision
+	err := stmt.Query(exec, &rev)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// createRevision appends the current data of a record to its history.
+func (s *PostgresStore) createRevision(exec db.Executor, record *Record, action, actor string) error {
+	where := table.Record.UUID.EQ(postgres.UUID(record.UUID))
+	if err := s.insertRevisions(exec, where, action, actor); err != nil {
+		return err
+	}
+	return s.pruneRevisions(exec, record.Cage, where)
+}
+
+// insertRevisions appends the current data of each record matching where to
+// its history, numbering the revisions on from the latest revision.
+func (s *PostgresStore) insertRevisions(exec db.Executor, where postgres.BoolExpression, action, actor string) error {
+	stmt := table.RecordRevision.INSERT(
+		table.RecordRevision.RecordUUID,
+		table.RecordRevision.Cage,
+		table.RecordRevision.Revision,
+		table.RecordRevision.Action,
+		table.RecordRevision.Data,
+		table.RecordRevision.Actor,
+		table.RecordRevision.CreatedAt,
+	).QUERY(
+		table.Record.SELECT(
+			table.Record.UUID,
+			table.Record.Cage,
+			postgres.RawInt("COALESCE((SELECT MAX(revision) FROM record_revision WHERE record_uuid = record.uuid), 0) + 1"),
+			postgres.String(action),
+			table.Record.Data,
+			postgres.String(actor),
+			postgres.NOW(),
+		).WHERE(where),
+	)
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// pruneRevisions deletes revisions beyond the retention limit of a cage from
+// the histories of records matching where.
+func (s *PostgresStore) pruneRevisions(exec db.Executor, cage string, where postgres.BoolExpression) error {
+	def := GetDefinition(cage)
+	if def == nil || def.Revisions == 0 {
+		return nil
+	}
+
+	stmt := table.RecordRevision.DELETE().
+		WHERE(table.RecordRevision.RecordUUID.IN(
+			table.Record.SELECT(table.Record.UUID).WHERE(where),
+		).AND(postgres.RawBool(
+			"record_revision.revision <= (SELECT MAX(latest.revision) FROM record_revision AS latest WHERE latest.record_uuid = record_revision.record_uuid) - #limit",
+			postgres.RawArgs{"#limit": def.Revisions},
+		)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// publish records an event for each of records and notifies listeners on
+// EventChannel. Events published together are ordered as records are.
+func (s *PostgresStore) publish(exec db.Executor, action, actor string, records ...*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	events := newEvents(action, actor, records)
+	ids := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = event.UUID.String()
+	}
+
+	stmt := table.RecordEvent.INSERT(table.RecordEvent.AllColumns.Except(table.RecordEvent.Seq)).MODELS(events)
+	if _, err := stmt.Exec(exec); err != nil {
+		return err
+	}
+
+	_, err := exec.Exec("SELECT pg_notify($1, id) FROM unnest($2::text[]) AS id", EventChannel, pq.Array(ids))
+	return err
+}
+
+// GetEvent implements Store.
+func (s *PostgresStore) GetEvent(exec db.Executor, uuid db.UUID) (*Event, error) {
+	stmt := table.RecordEvent.SELECT(table.RecordEvent.AllColumns).
+		WHERE(table.RecordEvent.UUID.EQ(postgres.UUID(uuid)))
+
+	var event Event
+	err := stmt.Query(exec, &event)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrEventNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// ListEvents implements Store.
+func (s *PostgresStore) ListEvents(exec db.Executor, cage string, after db.UUID) ([]*Event, error) {
+	// Resume after the sequence number of the event, or from the oldest
+	// event still kept if it has been pruned
+	seq := postgres.RawInt(
+		"COALESCE((SELECT last.seq FROM record_event AS last WHERE last.uuid = #after), 0)",
+		postgres.RawArgs{"#after": after.String()},
+	)
+
+	stmt := table.RecordEvent.SELECT(table.RecordEvent.AllColumns).
+		WHERE(table.RecordEvent.Cage.EQ(postgres.String(cage)).
+			AND(table.RecordEvent.Seq.GT(seq))).
+		ORDER_BY(table.RecordEvent.Seq.ASC())
+
+	var events []*Event
+	err := stmt.Query(exec, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// PruneEvents implements Store.
+func (s *PostgresStore) PruneEvents(exec db.Executor, before time.Time) (int64, error) {
+	stmt := table.RecordEvent.DELETE().
+		WHERE(table.RecordEvent.CreatedAt.LT(postgres.TimestampzT(before)))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// WatchEvents implements Store, listening for notifications on EventChannel
+// over a dedicated connection.
+func (s *PostgresStore) WatchEvents(ctx context.Context, fn func(*Event)) error {
+	listener := pq.NewListener(db.DSN(), time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("Event listener connection problem", "event", event, "error", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(EventChannel); err != nil {
+		return err
+	}
+	fn(nil)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-listener.Notify:
+			if n == nil {
+				// Notifications may have been lost while reconnecting
+				fn(nil)
+				continue
+			}
+
+			uuid, err := db.ParseUUID(n.Extra)
+			if err != nil {
+				slog.Error("Received invalid event UUID", "uuid", n.Extra, "error", err)
+				continue
+			}
+
+			event, err := s.GetEvent(db.SQLDB, uuid)
+			if err != nil {
+				slog.Error("Failed to retrieve event", "uuid", n.Extra, "error", err)
+				continue
+			}
+			fn(event)
+		case <-time.After(listenerPing):
+			if err := listener.Ping(); err != nil {
+				slog.Error("Failed to ping event listener", "error", err)
+			}
+		}
+	}
+}
+
+// where returns the condition selecting records in a cage matching query.
+func (s *PostgresStore) where(cage string, q ListQuery) (postgres.BoolExpression, error) {
+	cond := table.Record.Cage.EQ(postgres.String(cage)).
+		AND(table.Record.DeletedAt.IS_NULL())
+
+	c, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil && q.Sort == nil {
+		// Records are listed newest first, so resume with older UUIDs
+		cond = cond.AND(table.Record.UUID.LT(postgres.String(c.UUID)))
+	} else if c != nil {
+		cond = cond.AND(s.sortAfter(q.Sort, c.Value, c.UUID))
+	}
+
+	if !q.Since.IsZero() {
+		cond = cond.AND(table.Record.CreatedAt.GT_EQ(postgres.TimestampzT(q.Since)))
+	}
+	if !q.Until.IsZero() {
+		cond = cond.AND(table.Record.CreatedAt.LT_EQ(postgres.TimestampzT(q.Until)))
+	}
+
+	if q.Filter != nil {
+		cond = cond.AND(q.Filter.root.postgres())
+	}
+
+	return cond, nil
+}
+
+// orderBy returns the ordering of records listed by query.
+func (s *PostgresStore) orderBy(q ListQuery) []postgres.OrderByClause {
+	if q.Sort == nil {
+		return []postgres.OrderByClause{table.Record.UUID.DESC()}
+	}
+
+	key := s.sortKey(q.Sort).ASC()
+	if q.Sort.Desc {
+		key = s.sortKey(q.Sort).DESC()
+	}
+	return []postgres.OrderByClause{key, table.Record.UUID.DESC()}
+}
+
+// sortKeySQL is the expression records are sorted by, given a #path argument.
+const sortKeySQL = "COALESCE(record.data #> #path::text[], 'null'::jsonb)"
+
+// sortKey returns the expression records are sorted by.
+func (s *PostgresStore) sortKey(sort *Sort) postgres.Expression {
+	return postgres.Raw(sortKeySQL, postgres.RawArgs{"#path": sqlPath(sort.Path)})
+}
+
+// sortAfter returns the condition selecting records sorted after the given
+// value and UUID.
+func (s *PostgresStore) sortAfter(sort *Sort, value json.RawMessage, uuid string) postgres.BoolExpression {
+	op := ">"
+	if sort.Desc {
+		op = "<"
+	}
+
+	args := postgres.RawArgs{"#path": sqlPath(sort.Path), "#value": string(value)}
+	return postgres.RawBool(sortKeySQL+" "+op+" #value::jsonb", args).
+		OR(postgres.RawBool(sortKeySQL+" = #value::jsonb", args).
+			AND(table.Record.UUID.LT(postgres.String(uuid))))
+}
+
+// uniqueKeySQL returns the expression a unique path is indexed by, with JSON
+// null treated as missing so that, as with missing values, it is never
+// considered a duplicate. col names the record data column.
+func (s *PostgresStore) uniqueKeySQL(col string, path []string) string {
+	return fmt.Sprintf("NULLIF(%s #> %s, 'null')", col, quoteLiteral(sqlPath(path)))
+}
+
+// FindDuplicate implements Store. The condition matches the expressions of
+// the unique index, so that the index serves the lookup.
+func (s *PostgresStore) FindDuplicate(exec db.Executor, cage string, paths [][]string, values []string, lock bool) (*Record, error) {
+	where := postgres.RawBool("record.cage = " + quoteLiteral(cage)).
+		AND(table.Record.DeletedAt.IS_NULL())
+	for i, path := range paths {
+		where = where.AND(postgres.RawBool(s.uniqueKeySQL("record.data", path)+" = #value::jsonb",
+			postgres.RawArgs{"#value": values[i]}))
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).WHERE(where).LIMIT(1)
+	if lock {
+		stmt = stmt.FOR(postgres.UPDATE())
+	}
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// SyncUniqueIndexes implements Store.
+func (s *PostgresStore) SyncUniqueIndexes(exec db.Executor, indexes []UniqueIndex) error {
+	rows, err := exec.Query(`SELECT indexname FROM pg_indexes
+		WHERE schemaname = current_schema() AND tablename = 'record' AND indexname LIKE 'record\_unique\_%'`)
+	if err != nil {
+		return err
+	}
+	existing, err := scanNames(rows)
+	if err != nil {
+		return err
+	}
+
+	return syncUniqueIndexes(exec, existing, indexes, func(index UniqueIndex) string {
+		keys := make([]string, len(index.Paths))
+		for i, path := range index.Paths {
+			keys[i] = "(" + s.uniqueKeySQL("data", path) + ")"
+		}
+		return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON record (%s) WHERE cage = %s AND deleted_at IS NULL",
+			index.Name, strings.Join(keys, ", "), quoteLiteral(index.Cage))
+	})
+}
@@ -0,0 +1,175 @@
+package cage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+var ErrBadCursor = errors.New("bad cursor")
+
+// ListQuery narrows the records returned when listing a cage.
+type ListQuery struct {
+	// Since excludes records created before this time, if set.
+	Since time.Time
+	// Until excludes records created after this time, if set.
+	Until time.Time
+
+	// Filter excludes records whose data doesn't match, if set.
+	Filter *Filter
+	// Sort orders records by a value in their data, if set. Otherwise records
+	// are listed newest first.
+	Sort *Sort
+
+	// Limit is the maximum number of records to return, or zero for no limit.
+	Limit int
+	// Cursor resumes a listing after the last record of a previous page.
+	Cursor string
+}
+
+// Sort orders a listing by the value at a JSON path in the record data.
+// Records missing the value are treated as null, which sorts before any
+// other JSON value. Ties are listed newest first.
+type Sort struct {
+	Path []string
+	Desc bool
+}
+
+// ParseSort parses a sort specification, being a dotted JSON path optionally
+// prefixed with "-" to sort in descending order.
+func ParseSort(s string) (*Sort, error) {
+	sort := &Sort{}
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sort.Desc = true
+		s = rest
+	}
+
+	path, err := ParsePath(s)
+	if err != nil {
+		return nil, err
+	}
+	sort.Path = path
+
+	return sort, nil
+}
+
+// String returns the sort in the form accepted by ParseSort.
+func (s *Sort) String() string {
+	if s.Desc {
+		return "-" + strings.Join(s.Path, ".")
+	}
+	return strings.Join(s.Path, ".")
+}
+
+// valueAt returns the JSON value at path in data, or nil if there is none.
+func valueAt(data any, path []string) any {
+	for _, segment := range path {
+		switch v := data.(type) {
+		case map[string]any:
+			data = v[segment]
+		case db.JSONB:
+			data = v[segment]
+		case []any:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			data = v[i]
+		default:
+			return nil
+		}
+	}
+	return data
+}
+
+// cursor is the decoded form of an opaque pagination cursor, identifying the
+// last record of a page.
+type cursor struct {
+	// UUID is the UUID of the last record.
+	UUID string `json:"u"`
+	// Sort is the sort the cursor was created for, if any.
+	Sort string `json:"s,omitempty"`
+	// Value is the sort value of the last record, if sorted.
+	Value json.RawMessage `json:"v,omitempty"`
+}
+
+// encodeCursor returns the opaque string form of a cursor.
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c) // Marshalling strings and valid JSON can't fail
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses an opaque cursor string.
+// Returns ErrBadCursor if the string is malformed.
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrBadCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.UUID == "" {
+		return c, ErrBadCursor
+	}
+
+	return c, nil
+}
+
+// nextCursor returns the cursor resuming a listing after the given record.
+func (q ListQuery) nextCursor(last *Record) string {
+	c := cursor{UUID: last.UUID.String()}
+	if q.Sort != nil {
+		c.Sort = q.Sort.String()
+		c.Value, _ = json.Marshal(valueAt(last.Data, q.Sort.Path))
+	}
+	return encodeCursor(c)
+}
+
+// after returns the decoded cursor the listing resumes after, or nil if the
+// query has no cursor. Returns ErrBadCursor if the cursor is malformed or
+// was made for a different sort.
+func (q ListQuery) after() (*cursor, error) {
+	if q.Cursor == "" {
+		return nil, nil
+	}
+
+	c, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Sort == nil && c.Sort != "" {
+		return nil, ErrBadCursor
+	} else if q.Sort != nil && (c.Sort != q.Sort.String() || len(c.Value) == 0) {
+		return nil, ErrBadCursor
+	}
+
+	return &c, nil
+}
+
+// ParseTime parses a time used to filter records. Accepts RFC 3339 timestamps,
+// dates in the form 2006-01-02, and durations relative to now, such that "24h"
+// means 24 hours ago. Durations may also be given in whole days, such as "30d".
+func ParseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Now().AddDate(0, 0, -n), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected RFC 3339 timestamp, date or duration", s)
+}
@@ -0,0 +1,75 @@
+package cage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Revision actions, describing the change which produced a revision.
+const (
+	RevisionCreate  = "create"
+	RevisionUpdate  = "update"
+	RevisionDelete  = "delete"
+	RevisionRestore = "restore"
+)
+
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision is a version of a caged record in its history, storing the record
+// data as of a change along with who made it.
+type Revision = model.RecordRevision
+
+// ListRevisions retrieves the history of a record by its UUID, newest first.
+// The history of a trashed record is kept until it is purged.
+func ListRevisions(uuid db.UUID) ([]*Revision, error) {
+	return ListRevisionsContext(context.Background(), uuid)
+}
+
+// ListRevisionsContext is like ListRevisions, but is cancelled once ctx is
+// done.
+func ListRevisionsContext(ctx context.Context, uuid db.UUID) ([]*Revision, error) {
+	return store.ListRevisions(db.WithContext(ctx, db.SQLDB), uuid)
+}
+
+// GetRevision retrieves a single revision of a record by its UUID and
+// revision number. Returns ErrRevisionNotFound if no such revision exists.
+func GetRevision(uuid db.UUID, revision int32) (*Revision, error) {
+	return GetRevisionContext(context.Background(), uuid, revision)
+}
+
+// GetRevisionContext is like GetRevision, but is cancelled once ctx is done.
+func GetRevisionContext(ctx context.Context, uuid db.UUID, revision int32) (*Revision, error) {
+	return store.GetRevision(db.WithContext(ctx, db.SQLDB), uuid, revision)
+}
+
+// RestoreRevision sets the data of a record back to that of one of its
+// revisions, recording the restore as a new revision.
+func RestoreRevision(record *Record, revision int32, actor string) error {
+	return RestoreRevisionContext(context.Background(), record, revision, actor)
+}
+
+// RestoreRevisionContext is like RestoreRevision, but is cancelled once ctx
+// is done.
+func RestoreRevisionContext(ctx context.Context, record *Record, revision int32, actor string) error {
+	return db.TransactContext(ctx, func(tx *sql.Tx) error {
+		return RestoreRevisionTx(db.WithContext(ctx, tx), record, revision, actor)
+	})
+}
+
+// RestoreRevisionTx restores a revision of a record using the given executor,
+// allowing the restore to take part in a wider transaction. Returns
+// ErrRevisionNotFound if the record has no such revision, or a *SchemaError
+// if the revision doesn't match the current cage schema.
+func RestoreRevisionTx(exec db.Executor, record *Record, revision int32, actor string) error {
+	rev, err := store.GetRevision(exec, record.UUID, revision)
+	if err != nil {
+		return err
+	}
+
+	record.Data = rev.Data
+	return updateRecordTx(exec, record, RevisionRestore, actor)
+}
@@ -0,0 +1,16 @@
+package cage
+
+import (
+	"database/sql"
+)
+
+// scanBatch is the number of records fetched from a cursor at a time.
+const scanBatch = 500
+
+// ScanRecordsTx streams the records of a cage matching query to fn, oldest
+// first, fetching them in batches within tx so that the cage is never loaded
+// into memory at once. The query limit, cursor and sort are ignored. Scanning
+// stops at the first error returned by fn.
+func ScanRecordsTx(tx *sql.Tx, cage string, query ListQuery, fn func(*Record) error) error {
+	return store.ScanRecords(tx, cage, query, fn)
+}
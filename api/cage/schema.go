@@ -0,0 +1,92 @@
+package cage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/octacian/backroom/api/config"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// Definition is the optional configuration of a cage.
+type Definition = config.Cage
+
+// printer renders schema violation messages.
+var printer = message.NewPrinter(language.English)
+
+// Violation describes a way in which record data fails to match its cage schema.
+type Violation struct {
+	// Path is a JSON pointer to the offending value in the record data.
+	Path string `json:"path"`
+	// Message describes the problem with the value.
+	Message string `json:"message"`
+}
+
+// SchemaError is returned when record data doesn't match its cage schema.
+type SchemaError struct {
+	Cage       string
+	Violations []Violation
+}
+
+func (e *SchemaError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = fmt.Sprintf("%s: %s", v.Path, v.Message)
+	}
+	return fmt.Sprintf("record does not match %s schema: %s", e.Cage, strings.Join(messages, "; "))
+}
+
+// GetDefinition retrieves the configuration of a cage by its key.
+// Returns nil if the cage has no configuration.
+func GetDefinition(key string) *Definition {
+	for i := range config.RC.Cages {
+		if config.RC.Cages[i].Key == key {
+			return &config.RC.Cages[i]
+		}
+	}
+	return nil
+}
+
+// ValidateRecord checks record data against its cage schema, if any.
+// Returns a *SchemaError listing every violation if the data doesn't match.
+func ValidateRecord(record *Record) error {
+	def := GetDefinition(record.Cage)
+	if def == nil {
+		return nil
+	}
+
+	err := def.Validate(record.Data.ToMap())
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	schemaErr := &SchemaError{Cage: record.Cage}
+	collectViolations(validationErr, schemaErr)
+	return schemaErr
+}
+
+// collectViolations appends the leaves of a validation error tree, which
+// describe the individual violations, to a SchemaError.
+func collectViolations(err *jsonschema.ValidationError, into *SchemaError) {
+	if len(err.Causes) == 0 {
+		path := ""
+		for _, segment := range err.InstanceLocation {
+			segment = strings.ReplaceAll(segment, "~", "~0")
+			path += "/" + strings.ReplaceAll(segment, "/", "~1")
+		}
+
+		into.Violations = append(into.Violations, Violation{
+			Path:    path,
+			Message: err.ErrorKind.LocalizedString(printer),
+		})
+		return
+	}
+
+	for _, cause := range err.Causes {
+		collectViolations(cause, into)
+	}
+}
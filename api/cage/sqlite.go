@@ -0,0 +1,655 @@
+package cage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/go-jet/jet/v2/sqlite"
+	"github.com/octacian/backroom/api/.gen/backroom/sqlite/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// watchInterval is how often the SQLite event table is polled for events.
+const watchInterval = time.Second
+
+// SQLiteStore is a Store backed by an SQLite database, either on disk or in
+// memory, querying record data with the JSON1 functions. SQLite has no
+// notifications, so events are watched for by polling.
+type SQLiteStore struct{}
+
+// InsertRecords implements Store.
+func (s *SQLiteStore) InsertRecords(exec db.Executor, records []*Record, actor string) error {
+	uuids := make([]sqlite.Expression, len(records))
+	for i, record := range records {
+		uuids[i] = sqlite.String(record.UUID.String())
+	}
+
+	insert := table.Record.INSERT(table.Record.AllColumns).MODELS(records)
+	if _, err := insert.Exec(exec); err != nil {
+		return err
+	}
+
+	where := table.Record.UUID.IN(uuids...)
+	if err := s.insertRevisions(exec, where, RevisionCreate, actor); err != nil {
+		return err
+	}
+	return s.publish(exec, EventCreate, actor, records...)
+}
+
+// GetRecord implements Store. Writers always hold the database lock for the
+// whole of their transaction, so records need no further locking.
+func (s *SQLiteStore) GetRecord(exec db.Executor, uuid db.UUID, lock bool) (*Record, error) {
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(table.Record.UUID.EQ(sqlite.String(uuid.String())).
+			AND(table.Record.DeletedAt.IS_NULL()))
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ListRecords implements Store.
+func (s *SQLiteStore) ListRecords(exec db.Executor, cage string, query ListQuery) ([]*Record, error) {
+	where, err := s.where(cage, query)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(s.orderBy(query)...)
+
+	if query.Limit > 0 {
+		stmt = stmt.LIMIT(int64(query.Limit))
+	}
+
+	var records []*Record
+	err = stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ScanRecords implements Store, reading records a row at a time.
+func (s *SQLiteStore) ScanRecords(tx *sql.Tx, cage string, query ListQuery, fn func(*Record) error) error {
+	query.Cursor = ""
+	where, err := s.where(cage, query)
+	if err != nil {
+		return err
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.Record.UUID.ASC())
+
+	rows, err := stmt.Rows(context.Background(), tx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var record Record
+		if err := rows.Scan(&record); err != nil {
+			return err
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ListCages implements Store.
+func (s *SQLiteStore) ListCages(exec db.Executor) ([]string, error) {
+	stmt := table.Record.SELECT(table.Record.Cage).DISTINCT().
+		WHERE(table.Record.DeletedAt.IS_NULL())
+
+	var cages []string
+	err := stmt.Query(exec, &cages)
+	if err != nil {
+		return nil, err
+	}
+
+	return cages, nil
+}
+
+// UpdateRecord implements Store.
+func (s *SQLiteStore) UpdateRecord(exec db.Executor, record *Record, expected int32, action, actor string) (bool, error) {
+	stmt := table.Record.UPDATE(table.Record.Data, table.Record.UpdatedAt, table.Record.Version).
+		MODEL(record).
+		WHERE(table.Record.UUID.EQ(sqlite.String(record.UUID.String())).
+			AND(table.Record.DeletedAt.IS_NULL()).
+			AND(table.Record.Version.EQ(sqlite.Int32(expected))))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return false, err
+	}
+
+	if count, err := res.RowsAffected(); err != nil || count == 0 {
+		return false, err
+	}
+
+	if err := s.createRevision(exec, record, action, actor); err != nil {
+		return false, err
+	}
+	return true, s.publish(exec, EventUpdate, actor, record)
+}
+
+// TrashRecord implements Store.
+func (s *SQLiteStore) TrashRecord(exec db.Executor, uuid db.UUID, version int32, actor string) ([]*Record, error) {
+	where := table.Record.UUID.EQ(sqlite.String(uuid.String()))
+	if version != 0 {
+		where = where.AND(table.Record.Version.EQ(sqlite.Int32(version)))
+	}
+	return s.trashRecords(exec, where, actor)
+}
+
+// TrashCage implements Store.
+func (s *SQLiteStore) TrashCage(exec db.Executor, cage string, actor string) ([]*Record, error) {
+	return s.trashRecords(exec, table.Record.Cage.EQ(sqlite.String(cage)), actor)
+}
+
+// trashRecords moves the records matching where to the trash, recording
+// actor in their history. Returns the trashed records.
+func (s *SQLiteStore) trashRecords(exec db.Executor, where sqlite.BoolExpression, actor string) ([]*Record, error) {
+	where = where.AND(table.Record.DeletedAt.IS_NULL())
+
+	// Keep the final data of each record in its history
+	if err := s.insertRevisions(exec, where, RevisionDelete, actor); err != nil {
+		return nil, err
+	}
+
+	stmt := table.Record.UPDATE(table.Record.DeletedAt).
+		SET(db.SQLiteNow()).
+		WHERE(where).
+		RETURNING(table.Record.AllColumns)
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.publish(exec, EventDelete, actor, records...); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// GetTrashedRecord implements Store.
+func (s *SQLiteStore) GetTrashedRecord(exec db.Executor, uuid db.UUID) (*Record, error) {
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(table.Record.UUID.EQ(sqlite.String(uuid.String())).
+			AND(table.Record.DeletedAt.IS_NOT_NULL()))
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// ListTrash implements Store.
+func (s *SQLiteStore) ListTrash(exec db.Executor, cage string) ([]*Record, error) {
+	where := table.Record.DeletedAt.IS_NOT_NULL()
+	if cage != "" {
+		where = where.AND(table.Record.Cage.EQ(sqlite.String(cage)))
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.Record.DeletedAt.DESC(), table.Record.UUID.DESC())
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// RestoreRecord implements Store.
+func (s *SQLiteStore) RestoreRecord(exec db.Executor, uuid db.UUID, actor string) (*Record, error) {
+	// Bump the version, so that the record doesn't match its ETag from
+	// before it was trashed
+	stmt := table.Record.UPDATE(table.Record.DeletedAt, table.Record.UpdatedAt, table.Record.Version).
+		SET(sqlite.NULL, db.SQLiteNow(), table.Record.Version.ADD(sqlite.Int32(1))).
+		WHERE(table.Record.UUID.EQ(sqlite.String(uuid.String())).
+			AND(table.Record.DeletedAt.IS_NOT_NULL())).
+		RETURNING(table.Record.AllColumns)
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrNotInTrash
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := s.createRevision(exec, &record, RevisionRestore, actor); err != nil {
+		return nil, err
+	}
+	if err := s.publish(exec, EventRestore, actor, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// PurgeTrash implements Store.
+func (s *SQLiteStore) PurgeTrash(exec db.Executor, cage string, before time.Time, actor string) ([]*Record, error) {
+	where := table.Record.DeletedAt.LT_EQ(db.SQLiteTime(before))
+	if cage != "" {
+		where = where.AND(table.Record.Cage.EQ(sqlite.String(cage)))
+	}
+
+	stmt := table.Record.DELETE().
+		WHERE(where).
+		RETURNING(table.Record.AllColumns)
+
+	var records []*Record
+	err := stmt.Query(exec, &records)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return records, nil
+	}
+
+	uuids := make([]sqlite.Expression, len(records))
+	for i, record := range records {
+		uuids[i] = sqlite.String(record.UUID.String())
+	}
+
+	history := table.RecordRevision.DELETE().
+		WHERE(table.RecordRevision.RecordUUID.IN(uuids...))
+
+	if _, err := history.Exec(exec); err != nil {
+		return nil, err
+	}
+
+	if err := s.publish(exec, EventPurge, actor, records...); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// ListRevisions implements Store.
+func (s *SQLiteStore) ListRevisions(exec db.Executor, uuid db.UUID) ([]*Revision, error) {
+	stmt := table.RecordRevision.SELECT(table.RecordRevision.AllColumns).
+		WHERE(table.RecordRevision.RecordUUID.EQ(sqlite.String(uuid.String()))).
+		ORDER_BY(table.RecordRevision.Revision.DESC())
+
+	var revisions []*Revision
+	err := stmt.Query(exec, &revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevision implements Store.
+func (s *SQLiteStore) GetRevision(exec db.Executor, uuid db.UUID, revision int32) (*Revision, error) {
+	stmt := table.RecordRevision.SELECT(table.RecordRevision.AllColumns).
+		WHERE(table.RecordRevision.RecordUUID.EQ(sqlite.String(uuid.String())).
+			AND(table.RecordRevision.Revision.EQ(sqlite.Int32(revision))))
+
+	var rev Revision
+	err := stmt.Query(exec, &rev)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &rev, nil
+}
+
+// createRevision appends the current data of a record to its history.
+func (s *SQLiteStore) createRevision(exec db.Executor, record *Record, action, actor string) error {
+	where := table.Record.UUID.EQ(sqlite.String(record.UUID.String()))
+	if err := s.insertRevisions(exec, where, action, actor); err != nil {
+		return err
+	}
+	return s.pruneRevisions(exec, record.Cage, where)
+}
+
+// insertRevisions appends the current data of each record matching where to
+// its history, numbering the revisions on from the latest revision.
+func (s *SQLiteStore) insertRevisions(exec db.Executor, where sqlite.BoolExpression, action, actor string) error {
+	stmt := table.RecordRevision.INSERT(
+		table.RecordRevision.RecordUUID,
+		table.RecordRevision.Cage,
+		table.RecordRevision.Revision,
+		table.RecordRevision.Action,
+		table.RecordRevision.Data,
+		table.RecordRevision.Actor,
+		table.RecordRevision.CreatedAt,
+	).QUERY(
+		table.Record.SELECT(
+			table.Record.UUID,
+			table.Record.Cage,
+			sqlite.RawInt("COALESCE((SELECT MAX(revision) FROM record_revision WHERE record_uuid = record.uuid), 0) + 1"),
+			sqlite.String(action),
+			table.Record.Data,
+			sqlite.String(actor),
+			db.SQLiteNow(),
+		).WHERE(where),
+	)
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// pruneRevisions deletes revisions beyond the retention limit of a cage from
+// the histories of records matching where.
+func (s *SQLiteStore) pruneRevisions(exec db.Executor, cage string, where sqlite.BoolExpression) error {
+	def := GetDefinition(cage)
+	if def == nil || def.Revisions == 0 {
+		return nil
+	}
+
+	stmt := table.RecordRevision.DELETE().
+		WHERE(table.RecordRevision.RecordUUID.IN(
+			table.Record.SELECT(table.Record.UUID).WHERE(where),
+		).AND(sqlite.RawBool(
+			"record_revision.revision <= (SELECT MAX(latest.revision) FROM record_revision AS latest WHERE latest.record_uuid = record_revision.record_uuid) - #limit",
+			sqlite.RawArgs{"#limit": def.Revisions},
+		)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// publish records an event for each of records. Events published together
+// are ordered as records are.
+func (s *SQLiteStore) publish(exec db.Executor, action, actor string, records ...*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	stmt := table.RecordEvent.INSERT(table.RecordEvent.AllColumns.Except(table.RecordEvent.Seq)).MODELS(newEvents(action, actor, records))
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// GetEvent implements Store.
+func (s *SQLiteStore) GetEvent(exec db.Executor, uuid db.UUID) (*Event, error) {
+	stmt := table.RecordEvent.SELECT(table.RecordEvent.AllColumns).
+		WHERE(table.RecordEvent.UUID.EQ(sqlite.String(uuid.String())))
+
+	var event Event
+	err := stmt.Query(exec, &event)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrEventNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// ListEvents implements Store.
+func (s *SQLiteStore) ListEvents(exec db.Executor, cage string, after db.UUID) ([]*Event, error) {
+	// Resume after the sequence number of the event, or from the oldest
+	// event still kept if it has been pruned
+	seq := sqlite.RawInt(
+		"COALESCE((SELECT last.seq FROM record_event AS last WHERE last.uuid = #after), 0)",
+		sqlite.RawArgs{"#after": after.String()},
+	)
+
+	stmt := table.RecordEvent.SELECT(table.RecordEvent.AllColumns).
+		WHERE(table.RecordEvent.Cage.EQ(sqlite.String(cage)).
+			AND(table.RecordEvent.Seq.GT(seq))).
+		ORDER_BY(table.RecordEvent.Seq.ASC())
+
+	var events []*Event
+	err := stmt.Query(exec, &events)
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// PruneEvents implements Store.
+func (s *SQLiteStore) PruneEvents(exec db.Executor, before time.Time) (int64, error) {
+	stmt := table.RecordEvent.DELETE().
+		WHERE(table.RecordEvent.CreatedAt.LT(db.SQLiteTime(before)))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// WatchEvents implements Store, polling for events recorded since the last
+// poll in the order they were recorded.
+func (s *SQLiteStore) WatchEvents(ctx context.Context, fn func(*Event)) error {
+	var last int64
+	if err := db.SQLDB.QueryRowContext(ctx, "SELECT IFNULL(MAX(seq), 0) FROM record_event").Scan(&last); err != nil {
+		return err
+	}
+	fn(nil)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var latest int64
+		if err := db.SQLDB.QueryRowContext(ctx, "SELECT IFNULL(MAX(seq), 0) FROM record_event").Scan(&latest); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fn(nil)
+			continue
+		}
+		if latest == last {
+			continue
+		}
+
+		events, err := s.eventsAfter(ctx, last, latest)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fn(nil)
+			continue
+		}
+
+		for _, event := range events {
+			fn(event)
+		}
+		last = latest
+	}
+}
+
+// eventsAfter retrieves the events recorded after the sequence number after,
+// up to and including the sequence number until, in the order they were
+// recorded.
+func (s *SQLiteStore) eventsAfter(ctx context.Context, after, until int64) ([]*Event, error) {
+	stmt := table.RecordEvent.SELECT(table.RecordEvent.AllColumns).
+		WHERE(table.RecordEvent.Seq.GT(sqlite.Int(after)).
+			AND(table.RecordEvent.Seq.LT_EQ(sqlite.Int(until)))).
+		ORDER_BY(table.RecordEvent.Seq.ASC())
+
+	var events []*Event
+	if err := stmt.QueryContext(ctx, db.SQLDB, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// where returns the condition selecting records in a cage matching query.
+func (s *SQLiteStore) where(cage string, q ListQuery) (sqlite.BoolExpression, error) {
+	cond := table.Record.Cage.EQ(sqlite.String(cage)).
+		AND(table.Record.DeletedAt.IS_NULL())
+
+	c, err := q.after()
+	if err != nil {
+		return nil, err
+	}
+	if c != nil && q.Sort == nil {
+		// Records are listed newest first, so resume with older UUIDs
+		cond = cond.AND(table.Record.UUID.LT(sqlite.String(c.UUID)))
+	} else if c != nil {
+		cond = cond.AND(s.sortAfter(q.Sort, c.Value, c.UUID))
+	}
+
+	if !q.Since.IsZero() {
+		cond = cond.AND(table.Record.CreatedAt.GT_EQ(db.SQLiteTime(q.Since)))
+	}
+	if !q.Until.IsZero() {
+		cond = cond.AND(table.Record.CreatedAt.LT_EQ(db.SQLiteTime(q.Until)))
+	}
+
+	if q.Filter != nil {
+		cond = cond.AND(q.Filter.root.sqlite())
+	}
+
+	return cond, nil
+}
+
+// orderBy returns the ordering of records listed by query.
+func (s *SQLiteStore) orderBy(q ListQuery) []sqlite.OrderByClause {
+	if q.Sort == nil {
+		return []sqlite.OrderByClause{table.Record.UUID.DESC()}
+	}
+
+	rank, value := s.sortKey(q.Sort)
+	if q.Sort.Desc {
+		return []sqlite.OrderByClause{rank.DESC(), value.DESC(), table.Record.UUID.DESC()}
+	}
+	return []sqlite.OrderByClause{rank.ASC(), value.ASC(), table.Record.UUID.DESC()}
+}
+
+// sortRankSQL ranks the JSON type of the value at #path in #doc in the order
+// Postgres sorts JSONB values: null, strings, numbers, booleans, arrays and
+// then objects. Missing values rank as null.
+const sortRankSQL = `CASE json_type(#doc, #path) WHEN 'text' THEN 1 WHEN 'integer' THEN 2 WHEN 'real' THEN 2
+	WHEN 'false' THEN 3 WHEN 'true' THEN 3 WHEN 'array' THEN 4 WHEN 'object' THEN 5 ELSE 0 END`
+
+// sortValueSQL is the value at #path in #doc, compared between values of the
+// same rank. Booleans are extracted as 0 and 1, so false sorts first.
+const sortValueSQL = "IFNULL(json_extract(#doc, #path), '')"
+
+// sortKey returns the rank and value records are sorted by.
+func (s *SQLiteStore) sortKey(sort *Sort) (sqlite.Expression, sqlite.Expression) {
+	args := sqlite.RawArgs{"#path": jsonPath(sort.Path)}
+	return sqlite.Raw(replaceDoc(sortRankSQL, "record.data"), args), sqlite.Raw(replaceDoc(sortValueSQL, "record.data"), args)
+}
+
+// sortAfter returns the condition selecting records sorted after the given
+// value and UUID.
+func (s *SQLiteStore) sortAfter(sort *Sort, value json.RawMessage, uuid string) sqlite.BoolExpression {
+	op := ">"
+	if sort.Desc {
+		op = "<"
+	}
+
+	// Rank the cursor value with the same functions as the record data
+	args := sqlite.RawArgs{"#path": jsonPath(sort.Path), "#value": string(value)}
+	key := "(" + replaceDoc(sortRankSQL, "record.data") + ", " + replaceDoc(sortValueSQL, "record.data") + ")"
+	last := "(" + replaceDoc(replacePath(sortRankSQL), "#value") + ", " + replaceDoc(replacePath(sortValueSQL), "#value") + ")"
+
+	return sqlite.RawBool(key+" "+op+" "+last, args).
+		OR(sqlite.RawBool(key+" = "+last, args).
+			AND(table.Record.UUID.LT(sqlite.String(uuid))))
+}
+
+// replaceDoc substitutes the document a sort expression reads from.
+func replaceDoc(expr, doc string) string {
+	return strings.ReplaceAll(expr, "#doc", doc)
+}
+
+// replacePath substitutes the root path into a sort expression, for reading
+// a bare JSON value.
+func replacePath(expr string) string {
+	return strings.ReplaceAll(expr, "#path", "'$'")
+}
+
+// uniqueKeySQL returns the expression a unique path is indexed by. col names
+// the record data column.
+func (s *SQLiteStore) uniqueKeySQL(col string, path []string) string {
+	return fmt.Sprintf("json_extract(%s, %s)", col, quoteLiteral(jsonPath(path)))
+}
+
+// FindDuplicate implements Store. The condition matches the expressions of
+// the unique index, so that the index serves the lookup. Writers always hold
+// the database lock, so the record needs no further locking.
+func (s *SQLiteStore) FindDuplicate(exec db.Executor, cage string, paths [][]string, values []string, lock bool) (*Record, error) {
+	where := sqlite.RawBool("record.cage = " + quoteLiteral(cage)).
+		AND(table.Record.DeletedAt.IS_NULL())
+	for i, path := range paths {
+		where = where.AND(sqlite.RawBool(s.uniqueKeySQL("record.data", path)+" = json_extract(#value, '$')",
+			sqlite.RawArgs{"#value": values[i]}))
+	}
+
+	stmt := table.Record.SELECT(table.Record.AllColumns).WHERE(where).LIMIT(1)
+
+	var record Record
+	err := stmt.Query(exec, &record)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// SyncUniqueIndexes implements Store.
+func (s *SQLiteStore) SyncUniqueIndexes(exec db.Executor, indexes []UniqueIndex) error {
+	rows, err := exec.Query(`SELECT name FROM sqlite_master
+		WHERE type = 'index' AND tbl_name = 'record' AND name LIKE 'record\_unique\_%' ESCAPE '\'`)
+	if err != nil {
+		return err
+	}
+	existing, err := scanNames(rows)
+	if err != nil {
+		return err
+	}
+
+	return syncUniqueIndexes(exec, existing, indexes, func(index UniqueIndex) string {
+		keys := make([]string, len(index.Paths))
+		for i, path := range index.Paths {
+			keys[i] = s.uniqueKeySQL("data", path)
+		}
+		return fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON record (%s) WHERE cage = %s AND deleted_at IS NULL",
+			index.Name, strings.Join(keys, ", "), quoteLiteral(index.Cage))
+	})
+}
@@ -0,0 +1,280 @@
+package cage
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+// sqliteStore selects the SQLite store, for dbtest.Open.
+var sqliteStore = dbtest.Store(SetStore, Store(&SQLiteStore{}))
+
+// createRecord creates a record in a cage from JSON data, failing the test on
+// error.
+func createRecord(t *testing.T, cage, data string) *Record {
+	t.Helper()
+	record, err := NewRecordFromString(cage, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateRecord(record, "test"); err != nil {
+		t.Fatal(err)
+	}
+	return record
+}
+
+func TestSQLiteUpdateRecord(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	record := createRecord(t, "contact", `{"email": "a@example.com"}`)
+
+	stale := *record
+	record.Data["email"] = "b@example.com"
+	if err := UpdateRecord(record, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if record.Version != 2 {
+		t.Errorf("version = %d, want 2", record.Version)
+	}
+
+	stale.Data = db.JSONB{"email": "c@example.com"}
+	if err := UpdateRecord(&stale, "test"); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("stale update error = %v, want ErrVersionMismatch", err)
+	}
+
+	got, err := GetRecord(record.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Data["email"] != "b@example.com" || got.Version != 2 {
+		t.Errorf("got %v at version %d, want b@example.com at version 2", got.Data, got.Version)
+	}
+}
+
+func TestSQLiteListRecordsFilter(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	approved := createRecord(t, "contact", `{"status": "approved"}`)
+	pending := createRecord(t, "contact", `{"status": "pending"}`)
+	missing := createRecord(t, "contact", `{"name": "no status"}`)
+	tagged := createRecord(t, "contact", `{"tags": ["x", "y"]}`)
+	keyed := createRecord(t, "contact", `{"tags": {"0": "x"}}`)
+	createRecord(t, "other", `{"status": "pending"}`)
+
+	tests := []struct {
+		filter string
+		want   []*Record
+	}{
+		{`status = approved`, []*Record{approved}},
+		{`status != approved`, []*Record{pending}},
+		{`not status = approved`, []*Record{pending, missing, tagged, keyed}},
+		{`status exists`, []*Record{approved, pending}},
+		{`tags.0 = x`, []*Record{tagged}},
+		{`tags.1 != x`, []*Record{tagged}},
+		{`tags.1 exists`, []*Record{tagged}},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		records, _, err := ListRecordsByCage("contact", ListQuery{Filter: filter})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Records created within the same second list in any order
+		got := make(map[db.UUID]bool)
+		for _, record := range records {
+			got[record.UUID] = true
+		}
+		if len(records) != len(tt.want) {
+			t.Errorf("%s: got %d records, want %d", tt.filter, len(records), len(tt.want))
+		}
+		for _, record := range tt.want {
+			if !got[record.UUID] {
+				t.Errorf("%s: missing %v", tt.filter, record.Data)
+			}
+		}
+	}
+}
+
+func TestSQLiteListRecordsCursor(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	for range 5 {
+		createRecord(t, "contact", `{}`)
+	}
+
+	seen := make(map[db.UUID]bool)
+	query := ListQuery{Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("listing didn't end")
+		}
+
+		records, next, err := ListRecordsByCage("contact", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if seen[record.UUID] {
+				t.Errorf("record %s listed twice", record.UUID)
+			}
+			seen[record.UUID] = true
+		}
+
+		if next == "" {
+			break
+		}
+		query.Cursor = next
+	}
+
+	if len(seen) != 5 {
+		t.Errorf("listed %d records, want 5", len(seen))
+	}
+}
+
+func TestSQLiteDeleteAndRestoreRecord(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	record := createRecord(t, "contact", `{"email": "a@example.com"}`)
+
+	if err := DeleteRecord(record.UUID, 2, "test"); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("delete at wrong version error = %v, want ErrVersionMismatch", err)
+	}
+	if err := DeleteRecord(record.UUID, 1, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetRecord(record.UUID); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("get trashed record error = %v, want ErrRecordNotFound", err)
+	}
+
+	restored, err := RestoreRecord(record.UUID, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Version != 2 {
+		t.Errorf("restored version = %d, want 2", restored.Version)
+	}
+}
+
+func TestSQLiteUniqueConflicts(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+
+	prev := config.RC.Cages
+	config.RC.Cages = []config.Cage{
+		{Key: "reject", Unique: []string{"email"}},
+		{Key: "merge", Unique: []string{"email"}, OnConflict: ConflictMerge},
+		{Key: "ignore", Unique: []string{"email"}, OnConflict: ConflictIgnore},
+	}
+	t.Cleanup(func() { config.RC.Cages = prev })
+	if err := SyncUniqueIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	createRecord(t, "reject", `{"email": "a@example.com"}`)
+	duplicate, _ := NewRecordFromString("reject", `{"email": "a@example.com"}`)
+	if err := CreateRecord(duplicate, "test"); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("reject duplicate error = %v, want ErrDuplicate", err)
+	}
+
+	original := createRecord(t, "merge", `{"email": "a@example.com", "name": "A"}`)
+	merged := createRecord(t, "merge", `{"email": "a@example.com", "phone": "123"}`)
+	if merged.UUID != original.UUID || merged.Data["name"] != "A" || merged.Data["phone"] != "123" {
+		t.Errorf("merged into %s as %v, want %s holding name and phone", merged.UUID, merged.Data, original.UUID)
+	}
+
+	original = createRecord(t, "ignore", `{"email": "a@example.com", "name": "A"}`)
+	ignored := createRecord(t, "ignore", `{"email": "a@example.com", "name": "B"}`)
+	if ignored.UUID != original.UUID || ignored.Data["name"] != "A" {
+		t.Errorf("ignored duplicate became %s as %v, want %s unchanged", ignored.UUID, ignored.Data, original.UUID)
+	}
+}
+
+func TestSQLiteInsertOrFindDuplicate(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+
+	prev := config.RC.Cages
+	config.RC.Cages = []config.Cage{{Key: "merge", Unique: []string{"email"}, OnConflict: ConflictMerge}}
+	t.Cleanup(func() { config.RC.Cages = prev })
+	if err := SyncUniqueIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if committed by a concurrent create after the duplicate was looked for
+	original := createRecord(t, "merge", `{"email": "a@example.com"}`)
+	duplicate, _ := NewRecordFromString("merge", `{"email": "a@example.com"}`)
+
+	err := db.Transact(func(tx *sql.Tx) error {
+		existing, err := insertOrFindDuplicate(tx, duplicate, "test")
+		if err != nil {
+			return err
+		}
+		if existing == nil || existing.UUID != original.UUID {
+			t.Errorf("found %v, want the original record %s", existing, original.UUID)
+		}
+
+		// The failed insert is rolled back, leaving the transaction usable
+		_, err = insertOrFindDuplicate(tx, NewRecord("merge", db.JSONB{"email": "b@example.com"}), "test")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteSyncUniqueIndexesDuplicates(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	createRecord(t, "later", `{"email": "a@example.com"}`)
+	createRecord(t, "later", `{"email": "a@example.com"}`)
+
+	prev := config.RC.Cages
+	config.RC.Cages = []config.Cage{{Key: "later", Unique: []string{"email"}}}
+	t.Cleanup(func() { config.RC.Cages = prev })
+	if err := SyncUniqueIndexes(); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("sync over duplicates error = %v, want ErrDuplicate", err)
+	}
+}
+
+func TestSQLiteListEvents(t *testing.T) {
+	dbtest.Open(t, sqliteStore)
+	first := createRecord(t, "contact", `{}`)
+	createRecord(t, "contact", `{}`)
+	createRecord(t, "other", `{}`)
+	if err := DeleteRecord(first.UUID, 0, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := ListEvents("contact", db.NewUUID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("events after an unknown event = %d, want every event of the cage", len(events))
+	}
+	for i := 1; i < len(events); i++ {
+		if events[i].Seq <= events[i-1].Seq {
+			t.Errorf("event %d recorded at %d after %d", i, events[i].Seq, events[i-1].Seq)
+		}
+	}
+
+	resumed, err := ListEvents("contact", events[0].UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed) != 2 || resumed[0].UUID != events[1].UUID || resumed[1].Action != EventDelete {
+		t.Errorf("resumed with %d events, want the last 2 ending in a delete", len(resumed))
+	}
+
+	count, err := PruneEvents(time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("pruned %d events, want 4", count)
+	}
+}
@@ -0,0 +1,108 @@
+package cage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+// Store persists caged records along with their history and events. Each
+// method runs on the given executor, so that writes may take part in a wider
+// transaction. Records are validated before they reach the store.
+type Store interface {
+	// InsertRecords inserts new records along with their first revisions,
+	// publishing a create event for each.
+	InsertRecords(exec db.Executor, records []*Record, actor string) error
+	// GetRecord retrieves a record outside of the trash by its UUID. If lock
+	// is set, the record is locked against other writers until the
+	// transaction ends. Returns ErrRecordNotFound if there is no such record.
+	GetRecord(exec db.Executor, uuid db.UUID, lock bool) (*Record, error)
+	// ListRecords retrieves the records of a cage matching query, in the
+	// order described by ListQuery, up to the query limit.
+	ListRecords(exec db.Executor, cage string, query ListQuery) ([]*Record, error)
+	// ScanRecords streams the records of a cage matching query to fn, oldest
+	// first, without loading them all into memory. The query limit, cursor
+	// and sort are ignored.
+	ScanRecords(tx *sql.Tx, cage string, query ListQuery, fn func(*Record) error) error
+	// ListCages retrieves all unique cages with records outside of the trash.
+	ListCages(exec db.Executor) ([]string, error)
+	// UpdateRecord stores the data of a record if it is still at version
+	// expected, recording action in its history. Returns false if the record
+	// has changed or is no longer outside of the trash.
+	UpdateRecord(exec db.Executor, record *Record, expected int32, action, actor string) (bool, error)
+	// FindDuplicate retrieves a record of a cage outside of the trash holding
+	// the given JSON encoded values at the given paths. If lock is set, the
+	// record is locked against other writers until the transaction ends.
+	// Returns ErrRecordNotFound if there is no such record.
+	FindDuplicate(exec db.Executor, cage string, paths [][]string, values []string, lock bool) (*Record, error)
+	// SyncUniqueIndexes creates each of the given unique indexes which
+	// doesn't exist, and drops any other unique index created previously.
+	SyncUniqueIndexes(exec db.Executor, indexes []UniqueIndex) error
+
+	// TrashRecord moves a record to the trash by its UUID, only if it is at
+	// version when version is not zero. Returns the trashed records.
+	TrashRecord(exec db.Executor, uuid db.UUID, version int32, actor string) ([]*Record, error)
+	// TrashCage moves all records of a cage to the trash, returning them.
+	TrashCage(exec db.Executor, cage string, actor string) ([]*Record, error)
+	// GetTrashedRecord retrieves a record in the trash by its UUID. Returns
+	// ErrNotInTrash if there is no such record in the trash.
+	GetTrashedRecord(exec db.Executor, uuid db.UUID) (*Record, error)
+	// ListTrash retrieves the records in the trash, most recently trashed
+	// first, only from cage if it is not empty.
+	ListTrash(exec db.Executor, cage string) ([]*Record, error)
+	// RestoreRecord takes a record out of the trash by its UUID. Returns
+	// ErrNotInTrash if there is no such record in the trash.
+	RestoreRecord(exec db.Executor, uuid db.UUID, actor string) (*Record, error)
+	// PurgeTrash permanently deletes records trashed at or before a time
+	// along with their history, only from cage if it is not empty.
+	PurgeTrash(exec db.Executor, cage string, before time.Time, actor string) ([]*Record, error)
+
+	// ListRevisions retrieves the history of a record, newest first.
+	ListRevisions(exec db.Executor, uuid db.UUID) ([]*Revision, error)
+	// GetRevision retrieves a single revision of a record. Returns
+	// ErrRevisionNotFound if there is no such revision.
+	GetRevision(exec db.Executor, uuid db.UUID, revision int32) (*Revision, error)
+
+	// GetEvent retrieves an event by its UUID. Returns ErrEventNotFound if
+	// there is no such event.
+	GetEvent(exec db.Executor, uuid db.UUID) (*Event, error)
+	// ListEvents retrieves the events of a cage recorded after the event
+	// with the given UUID, in the order they were recorded. Every event still
+	// kept is returned if there is no such event.
+	ListEvents(exec db.Executor, cage string, after db.UUID) ([]*Event, error)
+	// PruneEvents deletes events published before a time, returning the
+	// number deleted.
+	PruneEvents(exec db.Executor, before time.Time) (int64, error)
+	// WatchEvents calls fn with each event published by any process sharing
+	// the database until ctx is done. fn is called with nil once watching
+	// has started, and again whenever events may have been missed. Returns
+	// an error if watching could not start.
+	WatchEvents(ctx context.Context, fn func(*Event)) error
+}
+
+// store is the Store used by the package functions.
+var store Store = &PostgresStore{}
+
+// InitStore selects the Store matching the configured database driver.
+func InitStore() {
+	if db.IsSQLite() {
+		store = &SQLiteStore{}
+	} else {
+		store = &PostgresStore{}
+	}
+}
+
+// SetStore replaces the Store used by the package functions, returning the
+// previous one.
+func SetStore(s Store) Store {
+	prev := store
+	store = s
+	return prev
+}
+
+// GetStore returns the Store used by the package functions.
+func GetStore() Store {
+	return store
+}
@@ -0,0 +1,69 @@
+package cage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+var ErrNotInTrash = errors.New("record not in trash")
+
+// GetTrashedRecord retrieves a record in the trash by its UUID. Returns
+// ErrNotInTrash if there is no such record in the trash.
+func GetTrashedRecord(uuid db.UUID) (*Record, error) {
+	return GetTrashedRecordContext(context.Background(), uuid)
+}
+
+// GetTrashedRecordContext is like GetTrashedRecord, but is cancelled once ctx
+// is done.
+func GetTrashedRecordContext(ctx context.Context, uuid db.UUID) (*Record, error) {
+	return store.GetTrashedRecord(db.WithContext(ctx, db.SQLDB), uuid)
+}
+
+// ListTrash retrieves the records in the trash, most recently trashed first.
+// If cage is not empty, only records belonging to that cage are retrieved.
+func ListTrash(cage string) ([]*Record, error) {
+	return ListTrashContext(context.Background(), cage)
+}
+
+// ListTrashContext is like ListTrash, but is cancelled once ctx is done.
+func ListTrashContext(ctx context.Context, cage string) ([]*Record, error) {
+	return store.ListTrash(db.WithContext(ctx, db.SQLDB), cage)
+}
+
+// RestoreRecord takes a record out of the trash by its UUID, recording actor
+// in its history. Returns the restored record, ErrNotInTrash if there is no
+// such record in the trash, or ErrDuplicate if another record has since
+// claimed its values at the unique paths of its cage.
+func RestoreRecord(uuid db.UUID, actor string) (*Record, error) {
+	return RestoreRecordContext(context.Background(), uuid, actor)
+}
+
+// RestoreRecordContext is like RestoreRecord, but is cancelled once ctx is
+// done.
+func RestoreRecordContext(ctx context.Context, uuid db.UUID, actor string) (*Record, error) {
+	var record *Record
+	err := db.TransactContext(ctx, func(tx *sql.Tx) error {
+		var err error
+		record, err = RestoreRecordTx(db.WithContext(ctx, tx), uuid, actor)
+		return err
+	})
+	return record, err
+}
+
+// RestoreRecordTx takes a record out of the trash using the given executor,
+// allowing the restore to take part in a wider transaction.
+func RestoreRecordTx(exec db.Executor, uuid db.UUID, actor string) (*Record, error) {
+	record, err := store.RestoreRecord(exec, uuid, actor)
+	return record, duplicateError(err)
+}
+
+// PurgeTrashTx permanently deletes records trashed at or before a time, along
+// with their history, using the given executor. If cage is not empty, only
+// records belonging to that cage are purged. Returns the purged records.
+func PurgeTrashTx(exec db.Executor, cage string, before time.Time, actor string) ([]*Record, error) {
+	return store.PurgeTrash(exec, cage, before, actor)
+}
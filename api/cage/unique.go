@@ -0,0 +1,217 @@
+package cage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Behaviors on creating a record which duplicates another, see
+// config.Cage.OnConflict.
+const (
+	ConflictReject = "reject"
+	ConflictMerge  = "merge"
+	ConflictIgnore = "ignore"
+)
+
+var ErrDuplicate = errors.New("duplicate record")
+
+// uniqueIndexPrefix begins the name of each index created to enforce the
+// unique paths of a cage.
+const uniqueIndexPrefix = "record_unique_"
+
+// Outcome describes what became of a record passed to CreateRecordTx.
+type Outcome int
+
+const (
+	// Created means the record was inserted.
+	Created Outcome = iota
+	// Merged means the record duplicated another, into which it was merged.
+	Merged
+	// Ignored means the record duplicated another and was discarded.
+	Ignored
+)
+
+// UniqueIndex describes an index enforcing the unique paths of a cage.
+type UniqueIndex struct {
+	// Name is the name of the index, derived from the cage and its paths.
+	Name string
+	// Cage is the key of the cage.
+	Cage string
+	// Paths are the paths whose values together must be unique.
+	Paths [][]string
+}
+
+// uniquePaths parses the unique paths of a cage definition.
+func uniquePaths(def *Definition) ([][]string, error) {
+	paths := make([][]string, len(def.Unique))
+	for i, s := range def.Unique {
+		path, err := ParsePath(s)
+		if err != nil {
+			return nil, fmt.Errorf("cage %s: unique path %q must be dotted segments of letters, digits, _ and -", def.Key, s)
+		}
+		paths[i] = path
+	}
+	return paths, nil
+}
+
+// uniqueIndex returns the index enforcing the unique paths of a cage. Its name
+// changes with the paths, so that changing them replaces the index.
+func uniqueIndex(def *Definition) (UniqueIndex, error) {
+	paths, err := uniquePaths(def)
+	if err != nil {
+		return UniqueIndex{}, err
+	}
+
+	sum := sha256.Sum256([]byte(def.Key + "\x00" + strings.Join(def.Unique, "\x00")))
+	return UniqueIndex{
+		Name:  uniqueIndexPrefix + hex.EncodeToString(sum[:8]),
+		Cage:  def.Key,
+		Paths: paths,
+	}, nil
+}
+
+// quoteLiteral returns s as an SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// uniqueValues returns the JSON encoded values of a record at each path. The
+// record isn't constrained, and false is returned, if any value is missing
+// or null.
+func uniqueValues(record *Record, paths [][]string) ([]string, bool) {
+	values := make([]string, len(paths))
+	for i, path := range paths {
+		value := valueAt(record.Data, path)
+		if value == nil {
+			return nil, false
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, false
+		}
+		values[i] = string(data)
+	}
+	return values, true
+}
+
+// duplicateError reports err as ErrDuplicate if it is a violation of the
+// unique paths of a cage, as when records race to claim the same values.
+func duplicateError(err error) error {
+	if name, ok := db.UniqueViolation(err); ok && strings.HasPrefix(name, uniqueIndexPrefix) {
+		return fmt.Errorf("%w: %v", ErrDuplicate, err)
+	}
+	return err
+}
+
+// findDuplicate retrieves the record which holds the same values as record at
+// the unique paths of its cage, locking it against other writers if lock is
+// set. Returns nil if there is no such record.
+func findDuplicate(exec db.Executor, record *Record, lock bool) (*Record, *Definition, error) {
+	def := GetDefinition(record.Cage)
+	if def == nil || len(def.Unique) == 0 {
+		return nil, def, nil
+	}
+
+	paths, err := uniquePaths(def)
+	if err != nil {
+		return nil, def, err
+	}
+
+	values, ok := uniqueValues(record, paths)
+	if !ok {
+		return nil, def, nil
+	}
+
+	existing, err := store.FindDuplicate(exec, record.Cage, paths, values, lock)
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, def, nil
+	} else if err != nil {
+		return nil, def, err
+	}
+
+	return existing, def, nil
+}
+
+// SyncUniqueIndexes creates the indexes enforcing the unique paths of each
+// configured cage, and drops those left over from paths no longer
+// configured. Fails if existing records already violate a unique path.
+func SyncUniqueIndexes() error {
+	return SyncUniqueIndexesContext(context.Background())
+}
+
+// SyncUniqueIndexesContext is like SyncUniqueIndexes, but is cancelled once
+// ctx is done.
+func SyncUniqueIndexesContext(ctx context.Context) error {
+	indexes := make([]UniqueIndex, 0)
+	for i := range config.RC.Cages {
+		def := &config.RC.Cages[i]
+		if len(def.Unique) == 0 {
+			continue
+		}
+
+		index, err := uniqueIndex(def)
+		if err != nil {
+			return err
+		}
+		indexes = append(indexes, index)
+	}
+
+	return store.SyncUniqueIndexes(db.WithContext(ctx, db.SQLDB), indexes)
+}
+
+// scanNames reads a single column of names from rows, closing them.
+func scanNames(rows *sql.Rows) (map[string]bool, error) {
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+// syncUniqueIndexes drops the existing unique indexes which are no longer
+// wanted, then creates each wanted index using the DDL returned by create.
+func syncUniqueIndexes(exec db.Executor, existing map[string]bool, indexes []UniqueIndex, create func(UniqueIndex) string) error {
+	wanted := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		wanted[index.Name] = true
+	}
+
+	for name := range existing {
+		if wanted[name] {
+			continue
+		}
+		if _, err := exec.Exec("DROP INDEX IF EXISTS " + name); err != nil {
+			return err
+		}
+		slog.Info("Dropped unique index", "index", name)
+	}
+
+	for _, index := range indexes {
+		if existing[index.Name] {
+			continue
+		}
+		if _, err := exec.Exec(create(index)); err != nil {
+			return fmt.Errorf("cage %s: %w", index.Cage, duplicateError(err))
+		}
+		slog.Info("Created unique index", "cage", index.Cage, "index", index.Name)
+	}
+
+	return nil
+}
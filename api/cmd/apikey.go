@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(apikeyCmd)
+	apikeyCmd.AddCommand(apikeyCreateCmd)
+	apikeyCreateCmd.Flags().StringSliceP("scope", "s", nil, "scope granted to the key: admin, cage:read:<glob> or cage:write:<glob> (repeatable)")
+	apikeyCreateCmd.MarkFlagRequired("scope")
+	apikeyCmd.AddCommand(apikeyListCmd)
+	apikeyCmd.AddCommand(apikeyRevokeCmd)
+}
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage API keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create [NAME]",
+	Short: "Create a new API key",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		scopes, err := cmd.Flags().GetStringSlice("scope")
+		if err != nil {
+			cmd.PrintErr("Error getting scope flag:", err)
+			return
+		}
+
+		key, secret, err := auth.NewAPIKey(args[0], scopes)
+		if err != nil {
+			cmd.PrintErr("Error preparing API key:", err)
+			return
+		}
+
+		if err := auth.CreateAPIKey(key); err != nil {
+			cmd.PrintErr("Error creating API key:", err)
+			return
+		}
+
+		cmd.Println("API key created:", key.Name)
+		cmd.Println("Store this key now, it will not be shown again:")
+		fmt.Println(secret)
+	},
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all API keys",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := auth.ListAPIKeys()
+		if err != nil {
+			cmd.PrintErr("Error listing API keys:", err)
+			return
+		}
+
+		if len(keys) == 0 {
+			cmd.Println("No API keys found")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+		for _, key := range keys {
+			lastUsed := "never"
+			if key.LastUsedAt != nil {
+				lastUsed = key.LastUsedAt.Format(time.DateTime)
+			}
+
+			status := color.GreenString("active")
+			if key.RevokedAt != nil {
+				status = color.RedString("revoked")
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", key.Name, key.Scopes, key.CreatedAt.Format(time.DateTime), lastUsed, status)
+		}
+		w.Flush()
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke [NAME]",
+	Short: "Revoke an active API key by name",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := auth.RevokeAPIKey(args[0])
+		if errors.Is(err, auth.ErrKeyNotFound) {
+			cmd.PrintErr("No active API key found with name: ", args[0])
+			return
+		} else if err != nil {
+			cmd.PrintErr("Error revoking API key:", err)
+			return
+		}
+
+		cmd.Println("API key revoked:", args[0])
+	},
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/octacian/backroom/api/backup"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().String("on-conflict", backup.ConflictFail, "how to handle rows which already exist: skip, overwrite or fail")
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup [FILE]",
+	Short: "Write every table to a compressed backup archive, or stdout if FILE is -",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var w io.Writer = cmd.OutOrStdout()
+		if args[0] != "-" {
+			file, err := os.Create(args[0])
+			if err != nil {
+				cmd.PrintErr("Error creating file:", err)
+				return
+			}
+			defer file.Close()
+			w = file
+		}
+
+		manifest, err := backup.Write(w)
+		if err != nil {
+			cmd.PrintErr("Error writing backup:", err)
+			return
+		}
+
+		cmd.Printf("Backed up %d tables at migration %d\n", len(manifest.Tables), manifest.Migration)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [FILE]",
+	Short: "Apply migrations, then restore a backup archive, or stdin if FILE is -",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		strategy, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			cmd.PrintErr("Error getting on-conflict flag:", err)
+			return
+		}
+
+		var r io.Reader = cmd.InOrStdin()
+		if args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				cmd.PrintErr("Error opening file:", err)
+				return
+			}
+			defer file.Close()
+			r = file
+		}
+
+		result, err := backup.Restore(r, strategy)
+		if err != nil {
+			cmd.PrintErr("Error restoring backup:", err)
+			return
+		}
+
+		cmd.Printf("Restored backup from %s at migration %d\n", result.Manifest.CreatedAt.Format(time.DateTime), result.Manifest.Migration)
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TABLE\tRESTORED\tSKIPPED")
+		for _, table := range result.Tables {
+			fmt.Fprintf(w, "%s\t%d\t%d\n", table.Table, table.Restored, table.Skipped)
+		}
+		w.Flush()
+	},
+}
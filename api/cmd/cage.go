@@ -1,31 +1,83 @@
 package cmd
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"os/user"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/octacian/backroom/api/cage"
 	"github.com/octacian/backroom/api/db"
 	"github.com/octacian/backroom/api/hook"
+	"github.com/octacian/backroom/api/idempotency"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(recordCmd)
 	recordCmd.AddCommand(recordCreateCmd)
+	recordCreateCmd.Flags().String("idempotency-key", "", "only create the record once for this key, however often the command is retried")
 	recordCmd.AddCommand(recordGetCmd)
 	recordGetCmd.Flags().BoolP("clean", "c", false, "clean output suitable for machine parsing")
 	recordCmd.AddCommand(recordListByCageCmd)
+	recordListByCageCmd.Flags().String("since", "", "only list records created at or after this time (RFC 3339, date or duration ago)")
+	recordListByCageCmd.Flags().String("until", "", "only list records created at or before this time (RFC 3339, date or duration ago)")
+	recordListByCageCmd.Flags().StringP("where", "w", "", "only list records matching a filter, e.g. 'status = approved and age >= 18'")
+	recordListByCageCmd.Flags().StringP("sort", "s", "", "sort records by a JSON path, prefixed with - for descending order")
+	recordListByCageCmd.Flags().Int("limit", 0, "maximum number of records to list (0 for no limit)")
+	recordListByCageCmd.Flags().String("cursor", "", "resume listing from a cursor returned by a previous limited listing")
 	recordCmd.AddCommand(recordListCagesCmd)
 	recordCmd.AddCommand(recordUpdateCmd)
+	recordUpdateCmd.Flags().Int32("if-version", 0, "only update the record if it is at this version")
 	recordCmd.AddCommand(recordDeleteCmd)
 	recordCmd.AddCommand(recordDeleteCageCmd)
 }
 
+// cliActor returns the name recorded in record history for changes made from
+// the command line, identifying the local user where possible.
+func cliActor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// printSchemaError prints each violation if err is a *cage.SchemaError.
+// Returns false otherwise.
+func printSchemaError(cmd *cobra.Command, err error) bool {
+	var schemaErr *cage.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return false
+	}
+
+	cmd.PrintErrln("Error: record does not match", schemaErr.Cage, "schema:")
+	for _, v := range schemaErr.Violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		cmd.PrintErrf("  %s: %s\n", path, v.Message)
+	}
+	return true
+}
+
+// getTimeFlag parses a time flag with cage.ParseTime, returning the zero time
+// if the flag is unset.
+func getTimeFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return cage.ParseTime(value)
+}
+
 var recordCmd = &cobra.Command{
 	Use:   "record",
 	Short: "Manage backroom records",
@@ -73,17 +125,64 @@ var recordCreateCmd = &cobra.Command{
 		record, err := cage.NewRecordFromString(key, string(jsonData))
 		if err != nil {
 			cmd.PrintErr("Error preparing JSON data:", err)
+			return
+		}
+
+		idempotencyKey, _ := cmd.Flags().GetString("idempotency-key")
+		if len(idempotencyKey) > idempotency.MaxKeyLength {
+			cmd.PrintErrf("Idempotency key longer than %d characters\n", idempotency.MaxKeyLength)
+			return
+		}
+
+		data, err := json.Marshal(record.Data)
+		if err != nil {
+			cmd.PrintErr("Error preparing JSON data:", err)
+			return
 		}
 
-		// Save a new caged record
-		if err := cage.CreateRecord(record); err != nil {
+		// Save a new caged record and run its hooks, unless already saved
+		hash := idempotency.Hash([]byte(key), data)
+		var outcome cage.Outcome
+		res, replayed, err := idempotency.Run(cmd.Context(), cliActor(), idempotencyKey, hash, func(tx *sql.Tx) (*idempotency.Response, error) {
+			var before db.JSONB
+			var err error
+			outcome, before, err = cage.CreateRecordTx(tx, record, cliActor(), true)
+			if err != nil {
+				return nil, err
+			}
+
+			switch outcome {
+			case cage.Merged:
+				if err := hook.RunUpdateHooks(cmd.Context(), tx, record, before); err != nil {
+					return nil, err
+				}
+				return idempotency.NewResponse(http.StatusOK, record.UUID, record)
+			case cage.Ignored:
+				return idempotency.NewResponse(http.StatusOK, record.UUID, record)
+			}
+			if err := hook.RunHooksByAction(cmd.Context(), tx, hook.ActionCreate, record); err != nil {
+				return nil, err
+			}
+			return idempotency.NewResponse(http.StatusCreated, record.UUID, record)
+		})
+		if printSchemaError(cmd, err) {
+			return
+		} else if err != nil {
 			cmd.PrintErr("Error creating caged record:", err)
 			return
 		}
 
-		// Run hooks after creating the record
-		if err := hook.RunHooksByAction(hook.ActionCreate, record); err != nil {
-			cmd.PrintErr("Error running hooks:", err)
+		if replayed {
+			cmd.Println("Caged record already created with idempotency key, UUID:", res.RecordUUID)
+			return
+		}
+
+		switch outcome {
+		case cage.Merged:
+			cmd.Println("Caged record merged into existing record with UUID:", record.UUID)
+			return
+		case cage.Ignored:
+			cmd.Println("Caged record ignored as a duplicate of record with UUID:", record.UUID)
 			return
 		}
 
@@ -142,8 +241,53 @@ var recordListByCageCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		cageKey := args[0]
 
-		// List all caged records by key
-		records, err := cage.ListRecordsByCage(cageKey)
+		var query cage.ListQuery
+		var err error
+
+		if query.Since, err = getTimeFlag(cmd, "since"); err != nil {
+			cmd.PrintErr("Invalid since flag:", err)
+			return
+		}
+		if query.Until, err = getTimeFlag(cmd, "until"); err != nil {
+			cmd.PrintErr("Invalid until flag:", err)
+			return
+		}
+
+		where, err := cmd.Flags().GetString("where")
+		if err != nil {
+			cmd.PrintErr("Error getting where flag:", err)
+			return
+		}
+		if where != "" {
+			if query.Filter, err = cage.ParseFilter(where); err != nil {
+				cmd.PrintErr("Invalid where flag:", err)
+				return
+			}
+		}
+
+		sort, err := cmd.Flags().GetString("sort")
+		if err != nil {
+			cmd.PrintErr("Error getting sort flag:", err)
+			return
+		}
+		if sort != "" {
+			if query.Sort, err = cage.ParseSort(sort); err != nil {
+				cmd.PrintErr("Invalid sort flag:", err)
+				return
+			}
+		}
+
+		if query.Limit, err = cmd.Flags().GetInt("limit"); err != nil {
+			cmd.PrintErr("Error getting limit flag:", err)
+			return
+		}
+		if query.Cursor, err = cmd.Flags().GetString("cursor"); err != nil {
+			cmd.PrintErr("Error getting cursor flag:", err)
+			return
+		}
+
+		// List caged records by key
+		records, next, err := cage.ListRecordsByCage(cageKey, query)
 		if err != nil {
 			cmd.PrintErr("Error listing caged records:", err)
 			return
@@ -163,6 +307,10 @@ var recordListByCageCmd = &cobra.Command{
 
 			fmt.Println(string(data))
 		}
+
+		if next != "" {
+			cmd.Println("More records available, continue with: --cursor", next)
+		}
 	},
 }
 
@@ -205,6 +353,16 @@ var recordUpdateCmd = &cobra.Command{
 			return
 		}
 
+		ifVersion, err := cmd.Flags().GetInt32("if-version")
+		if err != nil {
+			cmd.PrintErr("Error getting if-version flag:", err)
+			return
+		}
+		if ifVersion != 0 && record.Version != ifVersion {
+			cmd.PrintErrf("Record is at version %d, not %d\n", record.Version, ifVersion)
+			return
+		}
+
 		var reader io.Reader
 
 		if len(args) < 2 {
@@ -239,27 +397,33 @@ var recordUpdateCmd = &cobra.Command{
 			cmd.PrintErr("Error unmarshalling JSON data:", err)
 			return
 		}
+		before := record.Data
 		record.Data = data
 
-		// Update the record
-		if err := cage.UpdateRecord(record); err != nil {
-			cmd.PrintErr("Error updating caged record:", err)
+		// Update the record and run its hooks
+		err = db.Transact(func(tx *sql.Tx) error {
+			if err := cage.UpdateRecordTx(tx, record, cliActor()); err != nil {
+				return err
+			}
+			return hook.RunUpdateHooks(cmd.Context(), tx, record, before)
+		})
+		if errors.Is(err, cage.ErrVersionMismatch) {
+			cmd.PrintErr("Record changed during update, try again")
 			return
-		}
-
-		// Run hooks after updating the record
-		if err := hook.RunHooksByAction(hook.ActionUpdate, record); err != nil {
-			cmd.PrintErr("Error running hooks:", err)
+		} else if printSchemaError(cmd, err) {
+			return
+		} else if err != nil {
+			cmd.PrintErr("Error updating caged record:", err)
 			return
 		}
 
-		cmd.Println("Caged record updated with UUID:", record.UUID)
+		cmd.Printf("Caged record updated with UUID: %s (version %d)\n", record.UUID, record.Version)
 	},
 }
 
 var recordDeleteCmd = &cobra.Command{
 	Use:   "delete [UUID]",
-	Short: "Delete a record by UUID",
+	Short: "Move a record to the trash by UUID",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		uuid, err := db.ParseUUID(args[0])
@@ -274,37 +438,48 @@ var recordDeleteCmd = &cobra.Command{
 			return
 		}
 
-		// Run hooks before deleting the record
-		if err := hook.RunHooksByAction(hook.ActionDelete, record); err != nil {
-			cmd.PrintErr("Error running hooks:", err)
-			return
-		}
-
-		// Delete the caged record
-		err = cage.DeleteRecord(uuid)
+		// Delete the caged record and run its hooks
+		err = db.Transact(func(tx *sql.Tx) error {
+			if err := cage.DeleteRecordTx(tx, uuid, 0, cliActor()); err != nil {
+				return err
+			}
+			return hook.RunHooksByAction(cmd.Context(), tx, hook.ActionDelete, record)
+		})
 		if err != nil {
 			cmd.PrintErr("Error deleting record:", err)
 			return
 		}
 
-		cmd.Println("Record deleted with UUID:", uuid)
+		cmd.Println("Record moved to trash with UUID:", uuid)
 	},
 }
 
 var recordDeleteCageCmd = &cobra.Command{
 	Use:   "delete-cage [CAGE]",
-	Short: "Delete all records belonging to a common cage",
+	Short: "Move all records belonging to a common cage to the trash",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		cageKey := args[0]
 
-		// Delete all caged records by cageKey
-		count, err := cage.DeleteCage(cageKey)
+		// Move all caged records by cageKey to the trash and run their hooks
+		var deleted []*cage.Record
+		err := db.Transact(func(tx *sql.Tx) error {
+			var err error
+			if deleted, err = cage.DeleteCageTx(tx, cageKey, cliActor()); err != nil {
+				return err
+			}
+			for _, record := range deleted {
+				if err := hook.RunHooksByAction(cmd.Context(), tx, hook.ActionDelete, record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
 		if err != nil {
 			cmd.PrintErr("Error deleting caged records:", err)
 			return
 		}
 
-		cmd.Printf("%d caged records deleted for cage: %s\n", count, cageKey)
+		cmd.Printf("%d caged records moved to trash for cage: %s\n", len(deleted), cageKey)
 	},
 }
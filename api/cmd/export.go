@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/export"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recordCmd.AddCommand(recordExportCmd)
+	recordExportCmd.Flags().StringP("format", "f", export.FormatNDJSON, "output format: ndjson, csv, xlsx or parquet")
+	recordExportCmd.Flags().StringP("output", "o", "", "write to a file rather than stdout")
+	recordExportCmd.Flags().String("since", "", "only export records created at or after this time (RFC 3339, date or duration ago)")
+	recordExportCmd.Flags().String("until", "", "only export records created at or before this time (RFC 3339, date or duration ago)")
+	recordExportCmd.Flags().StringP("where", "w", "", "only export records matching a filter, e.g. 'status = approved and age >= 18'")
+}
+
+var recordExportCmd = &cobra.Command{
+	Use:   "export [CAGE]",
+	Short: "Export the records of a cage as NDJSON, CSV, an XLSX spreadsheet or Parquet",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cageKey := args[0]
+
+		var query cage.ListQuery
+		var err error
+
+		if query.Since, err = getTimeFlag(cmd, "since"); err != nil {
+			cmd.PrintErr("Invalid since flag:", err)
+			return
+		}
+		if query.Until, err = getTimeFlag(cmd, "until"); err != nil {
+			cmd.PrintErr("Invalid until flag:", err)
+			return
+		}
+
+		where, err := cmd.Flags().GetString("where")
+		if err != nil {
+			cmd.PrintErr("Error getting where flag:", err)
+			return
+		}
+		if where != "" {
+			if query.Filter, err = cage.ParseFilter(where); err != nil {
+				cmd.PrintErr("Invalid where flag:", err)
+				return
+			}
+		}
+
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			cmd.PrintErr("Error getting format flag:", err)
+			return
+		}
+		if _, ok := export.ContentTypes[format]; !ok {
+			cmd.PrintErr("Invalid format flag: ", format)
+			return
+		}
+
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			cmd.PrintErr("Error getting output flag:", err)
+			return
+		}
+
+		var w io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.Create(output)
+			if err != nil {
+				cmd.PrintErr("Error creating file:", err)
+				return
+			}
+			defer file.Close()
+			w = file
+		} else if format == export.FormatXLSX || format == export.FormatParquet {
+			cmd.PrintErr("Refusing to write a binary file to stdout, use --output")
+			return
+		}
+
+		if err := export.Write(w, cageKey, query, format); err != nil {
+			cmd.PrintErr("Error exporting caged records:", err)
+			return
+		}
+
+		if output != "" {
+			cmd.Println("Caged records exported to:", output)
+		}
+	},
+}
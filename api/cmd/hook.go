@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(hookCmd)
+	hookCmd.AddCommand(hookRunsCmd)
+	hookRunsCmd.Flags().String("status", "", "only list runs with this status: succeeded, failed or cancelled")
+	hookRunsCmd.Flags().String("hook", "", "only list runs of the hook with this ID")
+	hookRunsCmd.Flags().String("cage", "", "only list runs against records belonging to this cage")
+	hookRunsCmd.Flags().String("record", "", "only list runs against the record with this UUID")
+	hookRunsCmd.Flags().Int("limit", hook.DefaultRunLimit, "maximum number of runs to list")
+	hookCmd.AddCommand(hookReplayCmd)
+	hookCmd.AddCommand(hookTestCmd)
+	hookTestCmd.Flags().String("cage", "", "key of the cage the record belongs to")
+	hookTestCmd.MarkFlagRequired("cage")
+	hookTestCmd.Flags().String("action", string(hook.ActionCreate), "action performed on the record: create, update, delete, purge or import")
+	hookTestCmd.Flags().String("old", "", "JSON record data prior to an update, available to conditions as old")
+	hookTestCmd.Flags().String("ip", "", "client IP address of the triggering request, available to conditions as request.ip")
+	hookTestCmd.Flags().String("user-agent", "", "User-Agent of the triggering request, available to conditions as request.user_agent")
+	hookTestCmd.Flags().String("api-key", "", "name of the API key used by the triggering request, available to conditions as request.api_key")
+	hookTestCmd.Flags().Bool("run", false, "run the adapters of matching hooks, printing what they would deliver instead of delivering it")
+	hookCmd.AddCommand(hookLintCmd)
+}
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Inspect and manage hooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var hookRunsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "List hook runs, newest first",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		var query hook.RunQuery
+		query.Status, _ = cmd.Flags().GetString("status")
+		query.HookID, _ = cmd.Flags().GetString("hook")
+		query.Cage, _ = cmd.Flags().GetString("cage")
+		query.Limit, _ = cmd.Flags().GetInt("limit")
+		recordUUID, _ := cmd.Flags().GetString("record")
+
+		var runs []*hook.Run
+		var err error
+		if recordUUID != "" {
+			uuid, parseErr := db.ParseUUID(recordUUID)
+			if parseErr != nil {
+				cmd.PrintErr("Invalid UUID format:", parseErr)
+				return
+			}
+			runs, err = hook.ListRunsByRecord(cmd.Context(), uuid)
+		} else {
+			runs, err = hook.ListRuns(cmd.Context(), query)
+		}
+		if err != nil {
+			cmd.PrintErr("Error retrieving hook runs:", err)
+			return
+		}
+
+		if len(runs) == 0 {
+			cmd.Println("No hook runs found")
+			return
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tHOOK\tACTION\tRECORD\tSTATUS\tATTEMPT\tDURATION\tSTARTED\tERROR")
+		for _, run := range runs {
+			runErr := "-"
+			if run.Error != nil {
+				runErr = *run.Error
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", run.ID, run.HookID, run.Action, run.RecordUUID,
+				run.Status, run.Attempt, time.Duration(run.DurationMs)*time.Millisecond,
+				run.StartedAt.Local().Format(time.DateTime), runErr)
+		}
+		w.Flush()
+	},
+}
+
+var hookReplayCmd = &cobra.Command{
+	Use:   "replay [RUN ID]",
+	Short: "Run the hook of a failed hook run again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			cmd.PrintErr("Invalid run ID:", err)
+			return
+		}
+
+		run, err := hook.ReplayRun(cmd.Context(), id)
+		if errors.Is(err, hook.ErrRunNotFound) {
+			cmd.PrintErr("No hook run found with ID: ", id)
+			return
+		} else if errors.Is(err, hook.ErrRunNotReplayed) {
+			cmd.PrintErr("Hook run already succeeded: ", id)
+			return
+		} else if errors.Is(err, hook.ErrRunReplayed) {
+			cmd.PrintErr("Hook run already replayed successfully: ", id)
+			return
+		} else if err != nil {
+			cmd.PrintErr("Error replaying hook run:", err)
+			return
+		}
+
+		if run.Error != nil {
+			cmd.PrintErrf("Hook run %d replayed but %s, recorded as run %d: %s\n", id, run.Status, run.ID, *run.Error)
+			return
+		}
+
+		cmd.Printf("Hook run %d replayed successfully, recorded as run %d\n", id, run.ID)
+	},
+}
+
+var hookTestCmd = &cobra.Command{
+	Use:   "test [JSON]",
+	Short: "Show which hooks a record would trigger, without saving it",
+	Long: `Show which hooks would run for an action on a record, along with the value
+of each hook condition and the adapter that would run. Nothing is saved or
+delivered, though matching hooks may be run with --run, printing what would
+be delivered. The record is read from JSON passed as an argument, a file or
+stdin.`,
+	Args:             cobra.MaximumNArgs(1),
+	PersistentPreRun: skipInit,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := config.Load(); err != nil {
+			cmd.PrintErr("Error loading configuration:", err)
+			return
+		}
+
+		key, _ := cmd.Flags().GetString("cage")
+		act, _ := cmd.Flags().GetString("action")
+		run, _ := cmd.Flags().GetBool("run")
+
+		var reader io.Reader
+		if len(args) < 1 {
+			// args[0] doesn't exist, read from stdin
+			cmd.Println("Reading JSON from stdin...")
+			reader = cmd.InOrStdin()
+		} else if _, err := os.Stat(args[0]); err == nil {
+			// args[0] looks like a file, read from it
+			file, err := os.Open(args[0])
+			if err != nil {
+				cmd.PrintErr("Error opening file:", err)
+				return
+			}
+			defer file.Close()
+			reader = file
+		} else {
+			// args[0] is a JSON string
+			reader = strings.NewReader(args[0])
+		}
+
+		jsonData, err := io.ReadAll(reader)
+		if err != nil {
+			cmd.PrintErr("Error reading JSON data:", err)
+			return
+		}
+
+		record, err := cage.NewRecordFromString(key, string(jsonData))
+		if err != nil {
+			cmd.PrintErr("Error preparing JSON data:", err)
+			return
+		}
+		record.CreatedAt = time.Now()
+		record.UpdatedAt = record.CreatedAt
+
+		var before db.JSONB
+		if old, _ := cmd.Flags().GetString("old"); old != "" {
+			if err := json.Unmarshal([]byte(old), &before); err != nil {
+				cmd.PrintErr("Error preparing old JSON data:", err)
+				return
+			}
+		}
+
+		// Conditions see the request as they would if it came from the API
+		var req config.HookRequest
+		req.IP, _ = cmd.Flags().GetString("ip")
+		req.UserAgent, _ = cmd.Flags().GetString("user-agent")
+		req.APIKey, _ = cmd.Flags().GetString("api-key")
+		ctx := hook.WithRequest(cmd.Context(), req)
+
+		hooks, err := hook.ListHooksByCage(key)
+		if err != nil {
+			cmd.PrintErr("Error retrieving hooks:", err)
+			return
+		}
+		if len(hooks) == 0 {
+			cmd.Println("No hooks configured for cage:", key)
+			return
+		}
+
+		matched := make([]*hook.Hook, 0, len(hooks))
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "HOOK\tADAPTER\tON FAILURE\tCONDITION\tRESULT")
+		for i := range hooks {
+			h := &hooks[i]
+			condition := "-"
+			if h.If != "" {
+				condition = strings.Join(strings.Fields(h.If), " ")
+			}
+
+			var result string
+			if !slices.Contains(h.Action, act) {
+				result = "skipped, action doesn't match"
+			} else if value, err := h.Evaluate(hook.NewEnv(ctx, hook.Action(act), record, before)); err != nil {
+				result = "error: " + err.Error()
+			} else if ok, isBool := value.(bool); !isBool {
+				result = fmt.Sprintf("error: %v: got %#v", config.ErrConditionNotBool, value)
+			} else if !ok {
+				result = "skipped, condition is false"
+			} else {
+				result = "would run"
+				matched = append(matched, h)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", h.ID(), h.Adapter, h.OnFailure, condition, result)
+		}
+		w.Flush()
+
+		if !run || len(matched) == 0 {
+			return
+		}
+
+		adapters := hook.SinkAdapters(cmd.OutOrStdout())
+		for _, h := range matched {
+			cmd.Printf("\nRunning hook %s with the %s adapter:\n\n", h.ID(), h.Adapter)
+			if err := hook.DryRun(ctx, adapters, hook.Action(act), h, record, before); err != nil {
+				cmd.PrintErrf("Hook %s failed: %v\n", h.ID(), err)
+			}
+		}
+	},
+}
+
+var hookLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check the configuration, reporting every problem found",
+	Long: `Load the configuration, validating every setting and compiling the cage
+schemas and the condition and email templates of every hook. Rather than
+stopping at the first problem, each problem is reported, along with the
+offending line of any condition. Exits with a non-zero status if any problem
+is found.`,
+	Args:             cobra.NoArgs,
+	PersistentPreRun: skipInit,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := config.Load()
+		if err == nil {
+			cmd.Printf("Configuration OK, %d hooks checked\n", len(config.RC.Hooks))
+			return
+		}
+
+		errs := []error{err}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs = joined.Unwrap()
+		}
+
+		cmd.PrintErrf("Found %d problems:\n", len(errs))
+		for _, err := range errs {
+			cmd.PrintErrf("\n%v\n", err)
+		}
+		os.Exit(1)
+	},
+}
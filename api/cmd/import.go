@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recordCmd.AddCommand(recordImportCmd)
+	recordImportCmd.Flags().StringP("format", "f", "", "input format: ndjson, json or csv (detected if unset)")
+	recordImportCmd.Flags().StringToStringP("map", "m", nil, "map a CSV column to a dotted JSON path, e.g. 'E-mail=contact.email' (- skips the column)")
+	recordImportCmd.Flags().Bool("coerce", true, "convert CSV values which look like numbers, booleans or null into those types")
+	recordImportCmd.Flags().Int("batch-size", cage.DefaultImportBatch, "number of records inserted per transaction")
+	recordImportCmd.Flags().String("hooks", hook.ImportHooksEach, "hook mode: each (create hooks per record), batch (import hooks per batch) or none")
+	recordImportCmd.Flags().Bool("skip-hooks", false, "don't run any hooks, same as --hooks=none")
+}
+
+var recordImportCmd = &cobra.Command{
+	Use:   "import [CAGE] [FILE|STDIN]",
+	Short: "Import records into a cage from NDJSON, a JSON array or CSV",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cageKey := args[0]
+
+		var opts cage.ImportOptions
+		var err error
+
+		if opts.Format, err = cmd.Flags().GetString("format"); err != nil {
+			cmd.PrintErr("Error getting format flag:", err)
+			return
+		}
+		if opts.Mapping, err = cmd.Flags().GetStringToString("map"); err != nil {
+			cmd.PrintErr("Error getting map flag:", err)
+			return
+		}
+		if opts.Coerce, err = cmd.Flags().GetBool("coerce"); err != nil {
+			cmd.PrintErr("Error getting coerce flag:", err)
+			return
+		}
+		if opts.BatchSize, err = cmd.Flags().GetInt("batch-size"); err != nil {
+			cmd.PrintErr("Error getting batch-size flag:", err)
+			return
+		}
+
+		mode, err := cmd.Flags().GetString("hooks")
+		if err != nil {
+			cmd.PrintErr("Error getting hooks flag:", err)
+			return
+		}
+		if skip, _ := cmd.Flags().GetBool("skip-hooks"); skip {
+			mode = hook.ImportHooksNone
+		}
+		if opts.OnBatch, err = hook.ImportHooks(cmd.Context(), mode); err != nil {
+			cmd.PrintErr("Invalid hooks flag:", err)
+			return
+		}
+
+		var reader io.Reader
+		if len(args) < 2 {
+			cmd.Println("Reading records from stdin...")
+			reader = cmd.InOrStdin()
+		} else {
+			file, err := os.Open(args[1])
+			if err != nil {
+				cmd.PrintErr("Error opening file:", err)
+				return
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		result, err := cage.ImportRecords(reader, cageKey, opts, cliActor())
+		if result != nil {
+			for _, importErr := range result.Errors {
+				cmd.PrintErrf("Line %d: %s\n", importErr.Line, importErr.Error)
+			}
+			cmd.Printf("%d records imported into cage %s, %d failed\n", result.Imported, cageKey, len(result.Errors))
+		}
+		if err != nil {
+			cmd.PrintErr("Error reading records:", err)
+			return
+		}
+	},
+}
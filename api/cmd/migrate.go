@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/octacian/backroom/api/cage"
 	"github.com/octacian/backroom/api/db"
 	"github.com/octacian/backroom/api/migrations"
 	"github.com/pressly/goose/v3"
@@ -41,7 +42,7 @@ var migrateStatusCmd = &cobra.Command{
 		db.InitDB()
 		defer db.CloseDB()
 
-		if err := goose.Status(db.SQLDB, "."); err != nil {
+		if err := goose.Status(db.SQLDB, db.MigrationsDir()); err != nil {
 			slog.Error("Couldn't get migration status", "err", err)
 			os.Exit(1)
 		}
@@ -77,10 +78,12 @@ var migrateUpCmd = &cobra.Command{
 		db.InitDB()
 		defer db.CloseDB()
 
-		if err := goose.Up(db.SQLDB, "."); err != nil {
+		if err := goose.Up(db.SQLDB, db.MigrationsDir()); err != nil {
 			slog.Error("Couldn't apply migrations", "err", err)
 			os.Exit(1)
 		}
+
+		syncUniqueIndexes()
 	},
 }
 
@@ -98,7 +101,7 @@ var migrateUpToCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		if err := goose.UpTo(db.SQLDB, ".", version); err != nil {
+		if err := goose.UpTo(db.SQLDB, db.MigrationsDir(), version); err != nil {
 			slog.Error("Couldn't apply migrations to target version", "target", version, "err", err)
 			os.Exit(1)
 		}
@@ -112,7 +115,7 @@ var migrateDownCmd = &cobra.Command{
 		db.InitDB()
 		defer db.CloseDB()
 
-		if err := goose.Down(db.SQLDB, "."); err != nil {
+		if err := goose.Down(db.SQLDB, db.MigrationsDir()); err != nil {
 			slog.Error("Couldn't rollback migration", "err", err)
 			os.Exit(1)
 		}
@@ -133,7 +136,7 @@ var migrateDownToCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		if err := goose.DownTo(db.SQLDB, ".", version); err != nil {
+		if err := goose.DownTo(db.SQLDB, db.MigrationsDir(), version); err != nil {
 			slog.Error("Couldn't rollback migrations to target version", "target", version, "err", err)
 			os.Exit(1)
 		}
@@ -147,9 +150,20 @@ var migrateRedoCmd = &cobra.Command{
 		db.InitDB()
 		defer db.CloseDB()
 
-		if err := goose.Redo(db.SQLDB, "."); err != nil {
+		if err := goose.Redo(db.SQLDB, db.MigrationsDir()); err != nil {
 			slog.Error("Couldn't redo migration", "err", err)
 			os.Exit(1)
 		}
+
+		syncUniqueIndexes()
 	},
 }
+
+// syncUniqueIndexes enforces the unique paths of cages on the migrated
+// database, exiting if existing records violate them.
+func syncUniqueIndexes() {
+	if err := cage.SyncUniqueIndexes(); err != nil {
+		slog.Error("Failed to sync unique indexes", "error", err)
+		os.Exit(1)
+	}
+}
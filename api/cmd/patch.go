@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recordCmd.AddCommand(recordPatchCmd)
+	recordPatchCmd.Flags().Int32("if-version", 0, "only patch the record if it is at this version")
+	recordPatchCmd.Flags().Bool("json-patch", false, "treat the patch as a JSON Patch (RFC 6902) rather than a JSON Merge Patch (RFC 7386)")
+}
+
+var recordPatchCmd = &cobra.Command{
+	Use:   "patch [UUID] [JSON|JSON FILE|STDIN]",
+	Short: "Partially update an existing caged record with a JSON Merge Patch or JSON Patch",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		uuid, err := db.ParseUUID(args[0])
+		if err != nil {
+			cmd.PrintErr("Invalid UUID format:", err)
+			return
+		}
+
+		jsonPatch, err := cmd.Flags().GetBool("json-patch")
+		if err != nil {
+			cmd.PrintErr("Error getting json-patch flag:", err)
+			return
+		}
+
+		ifVersion, err := cmd.Flags().GetInt32("if-version")
+		if err != nil {
+			cmd.PrintErr("Error getting if-version flag:", err)
+			return
+		}
+
+		format := cage.MergePatch
+		if jsonPatch {
+			format = cage.JSONPatch
+		}
+
+		var reader io.Reader
+
+		if len(args) < 2 {
+			// args[1] doesn't exist, read from stdin
+			cmd.Println("Reading JSON from stdin...")
+			reader = cmd.InOrStdin()
+		} else if _, err := os.Stat(args[1]); err == nil {
+			// args[1] looks like a file, read from it
+			file, err := os.Open(args[1])
+			if err != nil {
+				cmd.PrintErr("Error opening file:", err)
+				return
+			}
+			defer file.Close()
+			reader = file
+			cmd.Println("Reading JSON from file:", args[1])
+		} else {
+			// args[1] is a JSON string
+			reader = strings.NewReader(args[1])
+		}
+
+		// Read JSON from the reader
+		patch, err := io.ReadAll(reader)
+		if err != nil {
+			cmd.PrintErr("Error reading JSON data:", err)
+			return
+		}
+
+		// Patch the record and run its hooks
+		err = db.Transact(func(tx *sql.Tx) error {
+			record, before, err := cage.PatchRecordTx(tx, uuid, ifVersion, format, patch, cliActor())
+			if err != nil {
+				return err
+			}
+			return hook.RunUpdateHooks(cmd.Context(), tx, record, before)
+		})
+		if errors.Is(err, cage.ErrRecordNotFound) {
+			cmd.PrintErr("No record found with UUID: ", uuid)
+			return
+		} else if errors.Is(err, cage.ErrVersionMismatch) {
+			cmd.PrintErrf("Record is not at version %d\n", ifVersion)
+			return
+		} else if printSchemaError(cmd, err) {
+			return
+		} else if err != nil {
+			cmd.PrintErr("Error patching caged record:", err)
+			return
+		}
+
+		cmd.Println("Caged record patched with UUID:", uuid)
+	},
+}
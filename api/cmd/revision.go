@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recordCmd.AddCommand(recordHistoryCmd)
+	recordCmd.AddCommand(recordRestoreCmd)
+}
+
+var recordHistoryCmd = &cobra.Command{
+	Use:   "history [UUID] [REV]",
+	Short: "List the revisions of a record, or print the data of a single revision",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		uuid, err := db.ParseUUID(args[0])
+		if err != nil {
+			cmd.PrintErr("Invalid UUID format:", err)
+			return
+		}
+
+		if len(args) == 2 {
+			revision, err := strconv.ParseInt(args[1], 10, 32)
+			if err != nil {
+				cmd.PrintErr("Invalid revision:", err)
+				return
+			}
+
+			rev, err := cage.GetRevision(uuid, int32(revision))
+			if errors.Is(err, cage.ErrRevisionNotFound) {
+				cmd.PrintErrf("No revision %d found for record: %s\n", revision, uuid)
+				return
+			} else if err != nil {
+				cmd.PrintErr("Error retrieving revision:", err)
+				return
+			}
+
+			data, err := json.MarshalIndent(rev.Data, "", "  ")
+			if err != nil {
+				cmd.PrintErr("Error marshalling revision:", err)
+				return
+			}
+
+			fmt.Println(string(data))
+			return
+		}
+
+		revisions, err := cage.ListRevisions(uuid)
+		if err != nil {
+			cmd.PrintErr("Error retrieving revisions:", err)
+			return
+		}
+
+		if len(revisions) == 0 {
+			cmd.Println("No revisions found for record:", uuid)
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REV\tACTION\tACTOR\tTIME")
+		for _, rev := range revisions {
+			actor := "unknown"
+			if rev.Actor != nil {
+				actor = *rev.Actor
+			}
+
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", rev.Revision, rev.Action, actor, rev.CreatedAt.Local().Format(time.DateTime))
+		}
+		w.Flush()
+	},
+}
+
+var recordRestoreCmd = &cobra.Command{
+	Use:   "restore [UUID] [REV]",
+	Short: "Restore a record from the trash, or to the data of one of its revisions",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		uuid, err := db.ParseUUID(args[0])
+		if err != nil {
+			cmd.PrintErr("Invalid UUID format:", err)
+			return
+		}
+
+		if len(args) == 1 {
+			_, err := cage.RestoreRecord(uuid, cliActor())
+			if errors.Is(err, cage.ErrNotInTrash) {
+				cmd.PrintErr("No record found in trash with UUID: ", uuid)
+				return
+			} else if errors.Is(err, cage.ErrDuplicate) {
+				cmd.PrintErr("Caged record duplicates an existing record:", err)
+				return
+			} else if err != nil {
+				cmd.PrintErr("Error restoring caged record:", err)
+				return
+			}
+
+			cmd.Println("Caged record restored from trash with UUID:", uuid)
+			return
+		}
+
+		revision, err := strconv.ParseInt(args[1], 10, 32)
+		if err != nil {
+			cmd.PrintErr("Invalid revision:", err)
+			return
+		}
+
+		record, err := cage.GetRecord(uuid)
+		if err != nil {
+			cmd.PrintErr("Error retrieving caged record:", err)
+			return
+		}
+
+		// Restore the revision and run update hooks
+		before := record.Data
+		err = db.Transact(func(tx *sql.Tx) error {
+			if err := cage.RestoreRevisionTx(tx, record, int32(revision), cliActor()); err != nil {
+				return err
+			}
+			return hook.RunUpdateHooks(cmd.Context(), tx, record, before)
+		})
+		if errors.Is(err, cage.ErrRevisionNotFound) {
+			cmd.PrintErrf("No revision %d found for record: %s\n", revision, uuid)
+			return
+		} else if printSchemaError(cmd, err) {
+			return
+		} else if err != nil {
+			cmd.PrintErr("Error restoring caged record:", err)
+			return
+		}
+
+		cmd.Printf("Caged record %s restored to revision %d\n", uuid, revision)
+	},
+}
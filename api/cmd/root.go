@@ -1,15 +1,50 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/octacian/backroom/api/idempotency"
+	"github.com/spf13/cobra"
+)
 
 var rootCmd = &cobra.Command{
 	Use:   "backroom",
 	Short: "Backroom data aggregator CLI",
+	// Commands which only need the configuration, or which must run even if
+	// it is invalid, override this
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Initialize configuration
+		config.Init()
+
+		// Initialize database connection
+		db.InitDB()
+
+		// Initialize storage for the configured database driver
+		cage.InitStore()
+		auth.InitStore()
+		hook.InitStore()
+		idempotency.InitStore()
+
+		// Initialize hook adapters
+		hook.InitAdapters()
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		if db.SQLDB != nil {
+			db.CloseDB()
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		cmd.Help()
 	},
 }
 
+// skipInit replaces the initialization done by rootCmd, for commands which
+// load the configuration themselves.
+func skipInit(cmd *cobra.Command, args []string) {}
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
 		panic(err)
@@ -1,15 +1,21 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/hook"
 	"github.com/octacian/backroom/api/httphandle"
+	"github.com/octacian/backroom/api/stream"
 	"github.com/spf13/cobra"
 )
 
@@ -38,31 +44,86 @@ func runServeCmd(cmd *cobra.Command, args []string) {
 	// Basic CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins: []string{"https://*", "http://*"},
-		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders: []string{"Link"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "Idempotency-Key", "If-Match", "If-None-Match", "Last-Event-ID", "X-API-Key", "X-CSRF-Token"},
+		ExposedHeaders: []string{"ETag", "Idempotent-Replayed", "Link"},
 		// Debug:            true,
 		MaxAge: 300, // Maximum value not ignored by any of major browsers
 	}))
 
-	// Set up routes
-	r.Post("/record/create", httphandle.HandleCreateRecord)
-	r.Get("/record/{uuid}", httphandle.HandleGetRecord)
-	r.Post("/record/{uuid}", httphandle.HandleUpdateRecord)
-	r.Get("/cage/{key}", httphandle.HandleListRecordsByCage)
-	r.Get("/cages", httphandle.HandleListCages)
-	r.Delete("/record/{uuid}", httphandle.HandleDeleteRecord)
-	r.Delete("/cage/{key}", httphandle.HandleDeleteRecordsByKey)
+	// Public routes
 	r.Get("/health", handleHealthCheck)
 
+	// Routes authorized per cage by API key
+	r.Group(func(r chi.Router) {
+		r.Use(httphandle.Authenticate)
+		r.Use(httphandle.HookRequest)
+
+		// Each route times out separately, see config.RC.Timeouts
+		route := func(method, pattern string, handler http.HandlerFunc) {
+			r.With(httphandle.Timeout(method, pattern)).MethodFunc(method, pattern, handler)
+		}
+
+		route(http.MethodPost, "/record/create", httphandle.HandleCreateRecord)
+		route(http.MethodGet, "/record/{uuid}", httphandle.HandleGetRecord)
+		route(http.MethodPost, "/record/{uuid}", httphandle.HandleUpdateRecord)
+		route(http.MethodPatch, "/record/{uuid}", httphandle.HandlePatchRecord)
+		route(http.MethodGet, "/record/{uuid}/revisions", httphandle.HandleListRevisions)
+		route(http.MethodGet, "/record/{uuid}/hooks", httphandle.HandleListRecordHooks)
+		route(http.MethodPost, "/record/{uuid}/restore", httphandle.HandleRestoreRecord)
+		route(http.MethodPost, "/record/{uuid}/restore/{rev}", httphandle.HandleRestoreRevision)
+		route(http.MethodGet, "/cage/{key}", httphandle.HandleListRecordsByCage)
+		route(http.MethodGet, "/cages", httphandle.HandleListCages)
+		route(http.MethodDelete, "/record/{uuid}", httphandle.HandleDeleteRecord)
+		route(http.MethodDelete, "/cage/{key}", httphandle.HandleDeleteRecordsByKey)
+		route(http.MethodPost, "/cage/{key}/import", httphandle.HandleImportRecords)
+		route(http.MethodGet, "/cage/{key}/export", httphandle.HandleExportRecords)
+		route(http.MethodGet, "/cage/{key}/stream", httphandle.HandleStreamRecords)
+		route(http.MethodGet, "/cage/{key}/trash", httphandle.HandleListTrash)
+		route(http.MethodDelete, "/cage/{key}/trash", httphandle.HandlePurgeTrash)
+		route(http.MethodGet, "/hooks/runs", httphandle.HandleListHookRuns)
+	})
+
+	// Enforce the unique paths of cages before accepting records
+	syncUniqueIndexes()
+
+	// Stop gracefully on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start hook delivery workers
+	workersDone := make(chan struct{})
+	go func() {
+		hook.RunWorkers(ctx)
+		close(workersDone)
+	}()
+
+	// Start delivering record events to change streams
+	streamDone := make(chan struct{})
+	go func() {
+		stream.Run(ctx)
+		close(streamDone)
+	}()
+
 	// Start the server
+	server := &http.Server{Addr: config.RC.APIListen, Handler: r}
+	go func() {
+		<-ctx.Done()
+		if err := server.Shutdown(context.Background()); err != nil {
+			slog.Error("Failed to shut down server", "error", err)
+		}
+	}()
+
 	slog.Info("Listening", "address", config.RC.APIListen, "url", config.RC.APIURL)
 
-	err := http.ListenAndServe(config.RC.APIListen, r)
-	if err != nil {
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		slog.Error("Failed to start server", "error", err)
 		os.Exit(1)
 	}
+
+	<-workersDone
+	<-streamDone
 }
 
 // handleHealthCheck is a simple health check endpoint.
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	recordCmd.AddCommand(recordTrashCmd)
+	recordTrashCmd.AddCommand(recordTrashListCmd)
+	recordCmd.AddCommand(recordPurgeCmd)
+	recordPurgeCmd.Flags().String("older-than", "", "only purge records trashed at or before this time (RFC 3339, date or duration ago, e.g. 30d)")
+	recordPurgeCmd.Flags().String("cage", "", "only purge records belonging to this cage")
+	recordPurgeCmd.MarkFlagRequired("older-than")
+}
+
+var recordTrashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage records in the trash",
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var recordTrashListCmd = &cobra.Command{
+	Use:   "list [CAGE]",
+	Short: "List records in the trash, optionally only those belonging to a cage",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var cageKey string
+		if len(args) == 1 {
+			cageKey = args[0]
+		}
+
+		records, err := cage.ListTrash(cageKey)
+		if err != nil {
+			cmd.PrintErr("Error retrieving trashed records:", err)
+			return
+		}
+
+		if len(records) == 0 {
+			cmd.Println("No records found in trash")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "UUID\tCAGE\tDELETED")
+		for _, record := range records {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", record.UUID, record.Cage, record.DeletedAt.Local().Format(time.DateTime))
+		}
+		w.Flush()
+	},
+}
+
+var recordPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete records from the trash",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		before, err := getTimeFlag(cmd, "older-than")
+		if err != nil {
+			cmd.PrintErr("Invalid older-than flag:", err)
+			return
+		}
+
+		cageKey, err := cmd.Flags().GetString("cage")
+		if err != nil {
+			cmd.PrintErr("Error getting cage flag:", err)
+			return
+		}
+
+		// Purge the trashed records and run their hooks
+		var purged []*cage.Record
+		err = db.Transact(func(tx *sql.Tx) error {
+			var err error
+			if purged, err = cage.PurgeTrashTx(tx, cageKey, before, cliActor()); err != nil {
+				return err
+			}
+			for _, record := range purged {
+				if err := hook.RunHooksByAction(cmd.Context(), tx, hook.ActionPurge, record); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			cmd.PrintErr("Error purging trashed records:", err)
+			return
+		}
+
+		cmd.Printf("%d trashed records purged\n", len(purged))
+	},
+}
@@ -1,28 +1,115 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log/slog"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 
 	"github.com/expr-lang/expr"
 	"github.com/expr-lang/expr/vm"
 	"github.com/go-playground/validator/v10"
+	"github.com/santhosh-tekuri/jsonschema/v6"
 	"github.com/spf13/viper"
 )
 
+// ErrConditionNotBool is returned when a hook condition evaluates to anything
+// but a boolean.
+var ErrConditionNotBool = errors.New("hook condition is not a boolean")
+
 // HookEnv defines the environment variables used to evaluate hook expressions.
+// The expr builtins, such as now(), are also available.
 type HookEnv struct {
+	// Cage is the record data.
 	Cage map[string]any `expr:"cage"`
+	// Old is the record data prior to an update, or the data of a deleted or
+	// purged record. Nil for other actions.
+	Old map[string]any `expr:"old"`
+
+	// Action is the action which triggered the hook.
+	Action string `expr:"action"`
+	// UUID is the UUID of the record.
+	UUID string `expr:"uuid"`
+	// CageKey is the key of the cage the record belongs to.
+	CageKey string `expr:"cage_key"`
+	// CreatedAt is when the record was created.
+	CreatedAt time.Time `expr:"created_at"`
+
+	// Request describes the API request which triggered the hook. Empty if
+	// the hook was triggered from the command line.
+	Request HookRequest `expr:"request"`
+
+	// Changed returns whether the value at a dotted path differs between
+	// Old and Cage, as in `changed("address.country")`.
+	Changed func(path string) bool `expr:"changed"`
+}
+
+// HookRequest describes the API request which triggered a hook.
+type HookRequest struct {
+	// IP is the address of the client, taken from any X-Real-IP or
+	// X-Forwarded-For header. Clients may set these headers to anything, so
+	// only rely on IP behind a reverse proxy which overwrites them.
+	IP string `expr:"ip"`
+	// UserAgent is the User-Agent header sent by the client.
+	UserAgent string `expr:"user_agent"`
+	// APIKey is the name of the API key used, or empty if anonymous.
+	APIKey string `expr:"api_key"`
+}
+
+// NewHookEnv returns an environment for evaluating hook expressions against
+// the data of a record, with old holding any data prior to the action.
+func NewHookEnv(action string, data, old map[string]any) *HookEnv {
+	env := &HookEnv{
+		Cage:   data,
+		Old:    old,
+		Action: action,
+	}
+	env.Changed = env.changed
+	return env
+}
+
+// changed implements HookEnv.Changed.
+func (e *HookEnv) changed(path string) bool {
+	return !reflect.DeepEqual(lookupPath(e.Old, path), lookupPath(e.Cage, path))
+}
+
+// lookupPath returns the value at a dotted path in data, or nil if there is
+// no such value.
+func lookupPath(data map[string]any, path string) any {
+	var value any = data
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
 }
 
 // Hook defines a hook configuration for a cage.
 type Hook struct {
+	// Name optionally identifies the hook. Queued deliveries reference their
+	// hook by ID, so naming a hook keeps pending deliveries valid when its
+	// other fields are changed.
+	Name string `mapstructure:"name"`
+
 	// Actions are any actions that triggers the hook.
-	// Valid values are "create", "update", "delete".
-	Action []string `mapstructure:"action" validate:"gt=0,dive,oneof=create update delete"`
+	// Valid values are "create", "update", "delete" (moved to the trash),
+	// "purge" (permanently deleted from the trash) and "import" (once per
+	// batch of records imported in batch hook mode).
+	Action []string `mapstructure:"action" validate:"gt=0,dive,oneof=create update delete purge import"`
 
 	// If is an optional condition that must be met for the hook to run.
-	// Cage data is available in the context as `cage.*`. See Expr for syntax.
-	// https://expr-lang.org/docs/language-definition
+	// Cage data is available in the context as `cage.*`, along with the
+	// record metadata and prior data described by HookEnv. See Expr for
+	// syntax. https://expr-lang.org/docs/language-definition
 	If        string `mapstructure:"if"`
 	ifProgram *vm.Program
 
@@ -30,36 +117,147 @@ type Hook struct {
 	Cage string `mapstructure:"cage" validate:"required"`
 
 	// Adapter is the name of the adapter to use for the hook.
-	// Valid values are "log", "smtp".
-	Adapter string `mapstructure:"adapter" validate:"oneof=log smtp"`
+	// Valid values are "log", "smtp", "webhook".
+	Adapter string `mapstructure:"adapter" validate:"oneof=log smtp webhook"`
 
-	// Target is the target log prefix or email for the hook.
+	// Target is the target log prefix, email or webhook URL for the hook.
 	Target string `mapstructure:"target" validate:"required"`
+
+	// Headers are additional HTTP headers sent with webhook requests.
+	Headers map[string]string `mapstructure:"headers"`
+
+	// Secret is the key used to sign webhook request bodies with HMAC-SHA256.
+	// The signature is sent in the X-Backroom-Signature header if set.
+	Secret string `mapstructure:"secret"`
+
+	// Timeout is the deadline for a single run of the hook by any adapter,
+	// after which the run is cancelled and retried like a failure. Capped at
+	// 5s for hooks with the "abort" policy. Defaults to hook_delivery.timeout
+	// if unset.
+	Timeout time.Duration `mapstructure:"timeout" validate:"omitempty,gt=0"`
+
+	// OnFailure is what happens to the write which triggered the hook if the
+	// hook fails. "defer" queues the hook to run once the write commits,
+	// retrying it on failure. "abort" runs the hook before the write commits,
+	// rolling the write back if the hook fails. The write transaction stays
+	// open while the hook runs, holding its locks, which with SQLite blocks
+	// every other write, so abort hooks should be quick. "continue" runs the
+	// hook once the write commits, before the request is answered, only
+	// logging it if the hook fails. Defaults to "defer" if unset.
+	OnFailure string `mapstructure:"on_failure" validate:"omitempty,oneof=abort continue defer"`
+
+	// Email optionally templates the subject and bodies of emails sent by
+	// the smtp adapter.
+	Email HookEmail `mapstructure:"email"`
 }
 
-// Eval returns whether or not the hook condition is met.
-func (h *Hook) Eval(cageData map[string]any) (bool, error) {
-	if h.ifProgram == nil {
-		return true, nil // No condition, always true
+// ID returns a stable identifier for the hook. This is the hook name if set,
+// otherwise a short hash of the fields that define the hook.
+func (h *Hook) ID() string {
+	if h.Name != "" {
+		return h.Name
 	}
 
-	// Populate the environment with the cage data
-	env := &HookEnv{
-		Cage: cageData,
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		h.Cage, strings.Join(h.Action, ","), h.If, h.Adapter, h.Target,
+	}, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Evaluate returns the value of the hook condition, or true if the hook has
+// no condition.
+func (h *Hook) Evaluate(env *HookEnv) (any, error) {
+	if h.ifProgram == nil {
+		return true, nil // No condition, always true
 	}
 
 	// Evaluate the expression
-	output, err := expr.Run(h.ifProgram, env)
+	return expr.Run(h.ifProgram, env)
+}
+
+// Eval returns whether or not the hook condition is met. Returns
+// ErrConditionNotBool if the condition evaluates to anything but a boolean,
+// as when it refers to a field which isn't one.
+func (h *Hook) Eval(env *HookEnv) (bool, error) {
+	output, err := h.Evaluate(env)
 	if err != nil {
 		return false, err
 	}
 
 	// Ensure the output is a boolean
-	ok := output.(bool)
+	ok, isBool := output.(bool)
+	if !isBool {
+		return false, fmt.Errorf("%w: got %T", ErrConditionNotBool, output)
+	}
 
 	return ok, nil
 }
 
+// compile checks the parts of the hook which struct validation can't, then
+// compiles its condition and any email templates.
+func (h *Hook) compile(validate *validator.Validate) error {
+	if h.Adapter == "webhook" {
+		if err := validate.Var(h.Target, "http_url"); err != nil {
+			return fmt.Errorf("target must be an HTTP URL: %w", err)
+		}
+	}
+
+	if h.Email.IsSet() {
+		if h.Adapter != "smtp" {
+			return errors.New("email templates require the smtp adapter")
+		}
+		if err := h.Email.compile(); err != nil {
+			return err
+		}
+	}
+
+	if h.If != "" {
+		program, err := expr.Compile(h.If, expr.AsBool(), expr.Env(&HookEnv{}))
+		if err != nil {
+			return fmt.Errorf("condition: %w", err)
+		}
+		h.ifProgram = program
+	}
+
+	return nil
+}
+
+// Cage defines optional configuration for a cage.
+type Cage struct {
+	// Key is the identifier key of the cage.
+	Key string `mapstructure:"key" validate:"required"`
+
+	// Schema is an optional path to a JSON Schema file which all records in
+	// the cage must conform to. Schemas are read from files rather than
+	// inlined, as configuration keys are not case sensitive.
+	Schema string `mapstructure:"schema" validate:"omitempty,file"`
+	schema *jsonschema.Schema
+
+	// Revisions is the number of revisions kept in the history of each
+	// record in the cage. Zero keeps every revision.
+	Revisions int `mapstructure:"revisions" validate:"gte=0"`
+
+	// Unique are dotted JSON paths whose values, taken together, must be
+	// unique among the records of the cage. Records missing any of the
+	// values, or holding null, aren't constrained. Enforced by indexes
+	// created by "migrate up" and "serve".
+	Unique []string `mapstructure:"unique" validate:"dive,required"`
+
+	// OnConflict is what happens on creating a record which duplicates
+	// another at the unique paths (default: "reject"). Valid values are
+	// "reject", "merge" into the existing record, and "ignore".
+	OnConflict string `mapstructure:"on_conflict" validate:"omitempty,oneof=reject merge ignore"`
+}
+
+// Validate checks data against the cage schema, if any. Returns a
+// *jsonschema.ValidationError if the data doesn't conform.
+func (c *Cage) Validate(data map[string]any) error {
+	if c.schema == nil {
+		return nil // No schema, anything goes
+	}
+	return c.schema.Validate(data)
+}
+
 // Config defines the expected environment variables (see .env.example.yml)
 type Config struct {
 	// Environment is the deployment environment.
@@ -79,17 +277,39 @@ type Config struct {
 	// APIListen is the address and port that the API listens on.
 	APIListen string `mapstructure:"api_listen" validate:"hostname_port,required"`
 
+	Timeouts struct {
+		// Default is the maximum duration of an API request, after which its
+		// context is cancelled, ending its queries and hooks. Change streams,
+		// exports and imports are long-lived, so only time out if set in
+		// Routes. Zero disables the timeout.
+		// Defaults to 30s if unset.
+		Default time.Duration `mapstructure:"default" validate:"gte=0"`
+		// Routes overrides Default for individual routes, keyed by method and
+		// route pattern, e.g. "POST /cage/{key}/import".
+		Routes map[string]time.Duration `mapstructure:"routes" validate:"dive,gte=0"`
+	} `mapstructure:"timeouts"`
+
 	Database struct {
+		// Driver is the storage backend.
+		// Valid values are "postgres", "sqlite" and "temp", which keeps a
+		// throwaway SQLite database in a temporary directory until the
+		// process exits, for trying backroom out and for tests.
+		// Defaults to "postgres" if unset.
+		Driver string `mapstructure:"driver" validate:"oneof=postgres sqlite temp"`
+
 		// User is the username to connect to the database.
-		User string `mapstructure:"user" validate:"required"`
+		User string `mapstructure:"user" validate:"required_if=Driver postgres"`
 		// Password is the password to connect to the database.
-		Password string `mapstructure:"password" validate:"required"`
+		Password string `mapstructure:"password" validate:"required_if=Driver postgres"`
 		// Host is the hostname of the database server.
-		Host string `mapstructure:"host" validate:"hostname_port,required"`
+		Host string `mapstructure:"host" validate:"required_if=Driver postgres,omitempty,hostname_port"`
 		// Name is the name of the database to connect to.
-		Name string `mapstructure:"name" validate:"required"`
+		Name string `mapstructure:"name" validate:"required_if=Driver postgres"`
 		// MaxConns is the maximum number of connections to the database.
 		MaxConns int `mapstructure:"max_conns" validate:"omitempty,min=1,max=1000"`
+
+		// Path is the SQLite database file. Defaults to "backroom.db" if unset.
+		Path string `mapstructure:"path"`
 	} `mapstructure:"database"`
 
 	Mail struct {
@@ -123,13 +343,63 @@ type Config struct {
 	SendGrid struct {
 		// APIKey is the SendGrid API key to use for sending mail.
 		APIKey string `mapstructure:"api_key"`
+
+		// APIURL is the SendGrid v3 mail send endpoint, which may be pointed
+		// elsewhere for testing (default: "https://api.sendgrid.com/v3/mail/send").
+		APIURL string `mapstructure:"api_url" validate:"omitempty,http_url"`
 	} `mapstructure:"sendgrid"`
 
 	// DeliveryMethod is the method used to send mail.
-	// Valid values are "smtp", "sendgrid", "log-only".
-	// Defaults to "smtp" if unset.
+	// Valid values are "smtp", "sendgrid", "log-only", which logs emails
+	// rather than sending them. Defaults to "smtp" if unset.
 	DeliveryMethod string `mapstructure:"delivery_method" validate:"oneof=smtp sendgrid log-only"`
 
+	Auth struct {
+		// PublicCages are glob patterns of cages which accept anonymous record
+		// creation, e.g. for public form submissions. Reading records always
+		// requires an API key.
+		PublicCages []string `mapstructure:"public_cages"`
+	} `mapstructure:"auth"`
+
+	HookDelivery struct {
+		// Workers is the number of concurrent hook delivery workers started
+		// alongside the API server. Defaults to 4 if unset.
+		Workers int `mapstructure:"workers" validate:"min=1,max=100"`
+		// MaxAttempts is the number of times a hook delivery is attempted
+		// before it is moved to the dead-letter state. Defaults to 8 if unset.
+		MaxAttempts int `mapstructure:"max_attempts" validate:"min=1"`
+		// Backoff is the delay before the first retry of a failed delivery,
+		// doubled on each subsequent attempt. Defaults to 30s if unset.
+		Backoff time.Duration `mapstructure:"backoff" validate:"gt=0"`
+		// MaxBackoff caps the delay between retries. Defaults to 1h if unset.
+		MaxBackoff time.Duration `mapstructure:"max_backoff" validate:"gtefield=Backoff"`
+		// PollInterval is how often idle workers check for queued deliveries.
+		// Defaults to 5s if unset.
+		PollInterval time.Duration `mapstructure:"poll_interval" validate:"gt=0"`
+		// Timeout is the deadline for a single hook run, for hooks which
+		// don't set their own. Defaults to 10s if unset.
+		Timeout time.Duration `mapstructure:"timeout" validate:"gt=0"`
+		// RunRetention is how long the history of each hook run is kept.
+		// Defaults to 720h (30 days) if unset.
+		RunRetention time.Duration `mapstructure:"run_retention" validate:"gt=0"`
+	} `mapstructure:"hook_delivery"`
+
+	Idempotency struct {
+		// Window is how long the response to a request made with an
+		// idempotency key is kept, during which retries of the request are
+		// answered with it rather than run again. Defaults to 24h if unset.
+		Window time.Duration `mapstructure:"window" validate:"gt=0"`
+	} `mapstructure:"idempotency"`
+
+	Stream struct {
+		// Retention is how long record events are kept for change streams
+		// resuming from the last event they saw. Defaults to 24h if unset.
+		Retention time.Duration `mapstructure:"retention" validate:"gt=0"`
+	} `mapstructure:"stream"`
+
+	// Cages stores optional configuration for individual cages.
+	Cages []Cage `mapstructure:"cages" validate:"dive"`
+
 	// Hooks stores hook configuration for caged records.
 	Hooks []Hook `mapstructure:"hooks" validate:"dive"`
 }
@@ -137,33 +407,113 @@ type Config struct {
 // RC stores the current runtime configuration.
 var RC *Config = &Config{}
 
-// Init sets up viper and unmarshals the primary configuration file.
+// Init sets up viper and unmarshals the primary configuration file,
+// panicking if it is invalid. See Load.
 func Init() {
+	if err := Load(); err != nil {
+		panic(err)
+	}
+}
+
+// Load sets up viper and unmarshals the primary configuration file, then
+// validates and compiles it. Every problem found is returned together,
+// rather than just the first.
+func Load() error {
 	viper.SetConfigName(".env")
 	viper.SetConfigType("yaml")
 	viper.SetConfigFile(".env.yml")
+
+	viper.SetDefault("timeouts.default", 30*time.Second)
+	viper.SetDefault("database.driver", "postgres")
+	viper.SetDefault("delivery_method", "smtp")
+	viper.SetDefault("sendgrid.api_url", "https://api.sendgrid.com/v3/mail/send")
+	viper.SetDefault("database.path", "backroom.db")
+	viper.SetDefault("hook_delivery.workers", 4)
+	viper.SetDefault("hook_delivery.max_attempts", 8)
+	viper.SetDefault("hook_delivery.backoff", 30*time.Second)
+	viper.SetDefault("hook_delivery.max_backoff", time.Hour)
+	viper.SetDefault("hook_delivery.poll_interval", 5*time.Second)
+	viper.SetDefault("hook_delivery.timeout", 10*time.Second)
+	viper.SetDefault("hook_delivery.run_retention", 30*24*time.Hour)
+	viper.SetDefault("stream.retention", 24*time.Hour)
+	viper.SetDefault("idempotency.window", 24*time.Hour)
+
 	if err := viper.ReadInConfig(); err != nil {
-		panic(err)
+		return err
 	}
 	if err := viper.Unmarshal(RC); err != nil {
-		panic(err)
+		return err
+	}
+
+	// Email template files are relative to the configuration file
+	dir := filepath.Dir(viper.ConfigFileUsed())
+	for i := range RC.Hooks {
+		RC.Hooks[i].Email.resolve(dir)
 	}
 
+	// Collect every problem, rather than stopping at the first.
+	var errs []error
 	validate := validator.New(validator.WithRequiredStructEnabled())
 	if err := validate.Struct(RC); err != nil {
-		panic(err)
+		var invalid validator.ValidationErrors
+		if !errors.As(err, &invalid) {
+			return err
+		}
+		for _, field := range invalid {
+			errs = append(errs, field)
+		}
 	}
 
 	slog.Info("Viper loaded configuration", "file", viper.GetViper().ConfigFileUsed())
 
-	// Compile any conditional hook expressions.
-	for i, hook := range RC.Hooks {
-		if hook.If != "" {
-			program, err := expr.Compile(hook.If, expr.AsBool(), expr.Env(&HookEnv{}))
+	for _, pattern := range RC.Auth.PublicCages {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("invalid public cage pattern %q: %w", pattern, err))
+		}
+	}
+
+	// Compile any cage schemas.
+	compiler := jsonschema.NewCompiler()
+	keys := make(map[string]bool, len(RC.Cages))
+	for i, cage := range RC.Cages {
+		if keys[cage.Key] {
+			errs = append(errs, fmt.Errorf("duplicate cage: %s", cage.Key))
+			continue
+		}
+		keys[cage.Key] = true
+
+		if cage.OnConflict == "" {
+			RC.Cages[i].OnConflict = "reject"
+		}
+
+		if cage.Schema != "" {
+			schema, err := compiler.Compile(cage.Schema)
 			if err != nil {
-				panic(err)
+				errs = append(errs, fmt.Errorf("cage %s: %w", cage.Key, err))
+				continue
 			}
-			RC.Hooks[i].ifProgram = program
+			RC.Cages[i].schema = schema
 		}
 	}
+
+	// Compile any conditional hook expressions and email templates.
+	ids := make(map[string]bool, len(RC.Hooks))
+	for i := range RC.Hooks {
+		hook := &RC.Hooks[i]
+		if ids[hook.ID()] {
+			errs = append(errs, fmt.Errorf("duplicate hook: %s", hook.ID()))
+			continue
+		}
+		ids[hook.ID()] = true
+
+		if hook.OnFailure == "" {
+			hook.OnFailure = "defer"
+		}
+
+		if err := hook.compile(validate); err != nil {
+			errs = append(errs, fmt.Errorf("hook %s: %w", hook.ID(), err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
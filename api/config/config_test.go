@@ -0,0 +1,49 @@
+package config
+
+import "testing"
+
+func TestHookEnvChanged(t *testing.T) {
+	old := map[string]any{
+		"status":  "pending",
+		"tags":    []any{"a"},
+		"address": map[string]any{"country": "NZ", "city": "Wellington"},
+	}
+	data := map[string]any{
+		"status":  "pending",
+		"tags":    []any{"a", "b"},
+		"address": map[string]any{"country": "AU", "city": "Wellington"},
+		"phone":   "123",
+	}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"status", false},
+		{"tags", true},
+		{"address", true},
+		{"address.country", true},
+		{"address.city", false},
+		{"address.postcode", false},
+		{"phone", true},
+		{"missing", false},
+		{"status.nested", false},
+		{"missing.nested", false},
+	}
+
+	env := NewHookEnv("update", data, old)
+	for _, tt := range tests {
+		if got := env.Changed(tt.path); got != tt.want {
+			t.Errorf("changed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Without prior data, as on create, any set path has changed
+	env = NewHookEnv("create", data, nil)
+	if !env.Changed("address.country") {
+		t.Error("changed(\"address.country\") without prior data = false, want true")
+	}
+	if env.Changed("missing") {
+		t.Error("changed(\"missing\") without prior data = true, want false")
+	}
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// HookEmail defines optional Go templates for the emails sent by a hook using
+// the smtp adapter. Bodies may be given inline or read from a file. See
+// https://pkg.go.dev/text/template for syntax.
+type HookEmail struct {
+	// Subject is a text template for the subject line.
+	Subject string `mapstructure:"subject"`
+
+	// Text is an inline text template for the plain text body.
+	Text string `mapstructure:"text" validate:"excluded_with=TextFile"`
+	// TextFile is the path to a text template for the plain text body,
+	// relative to the configuration file unless absolute.
+	TextFile string `mapstructure:"text_file" validate:"omitempty,file"`
+
+	// HTML is an inline HTML template for the HTML body, sent alongside the
+	// plain text body as an alternative.
+	HTML string `mapstructure:"html" validate:"excluded_with=HTMLFile"`
+	// HTMLFile is the path to an HTML template for the HTML body, relative
+	// to the configuration file unless absolute.
+	HTMLFile string `mapstructure:"html_file" validate:"omitempty,file"`
+
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// IsSet returns whether any template is configured.
+func (e *HookEmail) IsSet() bool {
+	return e.Subject != "" || e.Text != "" || e.TextFile != "" || e.HTML != "" || e.HTMLFile != ""
+}
+
+// resolve joins the relative paths of template files to dir, the directory
+// of the configuration file.
+func (e *HookEmail) resolve(dir string) {
+	if e.TextFile != "" && !filepath.IsAbs(e.TextFile) {
+		e.TextFile = filepath.Join(dir, e.TextFile)
+	}
+	if e.HTMLFile != "" && !filepath.IsAbs(e.HTMLFile) {
+		e.HTMLFile = filepath.Join(dir, e.HTMLFile)
+	}
+}
+
+// compile parses the configured templates.
+func (e *HookEmail) compile() error {
+	var err error
+	if e.Subject != "" {
+		if e.subject, err = texttemplate.New("subject").Funcs(TemplateFuncs).Parse(e.Subject); err != nil {
+			return err
+		}
+	}
+
+	text, err := inlineOrFile(e.Text, e.TextFile)
+	if err != nil {
+		return err
+	} else if text != "" {
+		if e.text, err = texttemplate.New("text").Funcs(TemplateFuncs).Parse(text); err != nil {
+			return err
+		}
+	}
+
+	html, err := inlineOrFile(e.HTML, e.HTMLFile)
+	if err != nil {
+		return err
+	} else if html != "" {
+		if e.html, err = htmltemplate.New("html").Funcs(TemplateFuncs).Parse(html); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inlineOrFile returns the inline template if set, otherwise the contents
+// of the file if set.
+func inlineOrFile(inline, file string) (string, error) {
+	if inline != "" || file == "" {
+		return inline, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Render executes each configured template with data. The result is empty
+// for templates which aren't configured.
+func (e *HookEmail) Render(data any) (subject, text, html string, err error) {
+	var buf bytes.Buffer
+	if e.subject != nil {
+		if err := e.subject.Execute(&buf, data); err != nil {
+			return "", "", "", err
+		}
+		subject = buf.String()
+		buf.Reset()
+	}
+
+	if e.text != nil {
+		if err := e.text.Execute(&buf, data); err != nil {
+			return "", "", "", err
+		}
+		text = buf.String()
+		buf.Reset()
+	}
+
+	if e.html != nil {
+		if err := e.html.Execute(&buf, data); err != nil {
+			return "", "", "", err
+		}
+		html = buf.String()
+	}
+
+	return subject, text, html, nil
+}
+
+// TemplateFuncs are the helper functions available to email templates.
+var TemplateFuncs = map[string]any{
+	// date formats a time, or a string holding an RFC 3339 time, using a
+	// Go time layout. Other values are formatted as is.
+	"date": func(layout string, value any) string {
+		switch v := value.(type) {
+		case time.Time:
+			return v.Format(layout)
+		case string:
+			if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return t.Format(layout)
+			}
+			return v
+		case nil:
+			return ""
+		default:
+			return fmt.Sprint(v)
+		}
+	},
+	// default returns value, or fallback if value is missing or empty.
+	"default": func(fallback, value any) any {
+		if value == nil {
+			return fallback
+		}
+		if v := reflect.ValueOf(value); v.IsZero() || (v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0 {
+			return fallback
+		}
+		return value
+	},
+	// json returns value as indented JSON.
+	"json": func(value any) (string, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(value); err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(buf.String(), "\n"), nil
+	},
+}
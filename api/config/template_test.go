@@ -0,0 +1,92 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	email := HookEmail{
+		Subject: `New contact: {{ .name | default "unknown" }}`,
+		Text:    `{{ .name }} wrote on {{ date "Jan 2, 2006" .created_at }}`,
+		HTML:    `<p>{{ .name }}</p>`,
+	}
+	if err := email.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]any{"name": "<Ann>", "created_at": "2026-01-02T03:04:05Z"}
+	subject, text, html, err := email.Render(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "New contact: <Ann>"; subject != want {
+		t.Errorf("subject = %q, want %q", subject, want)
+	}
+	if want := "<Ann> wrote on Jan 2, 2026"; text != want {
+		t.Errorf("text = %q, want %q", text, want)
+	}
+	if want := "<p>&lt;Ann&gt;</p>"; html != want {
+		t.Errorf("html = %q, want %q", html, want)
+	}
+
+	// Templates which aren't configured render empty
+	email = HookEmail{Subject: "Hello"}
+	if err := email.compile(); err != nil {
+		t.Fatal(err)
+	}
+	if subject, text, html, err := email.Render(nil); err != nil || subject != "Hello" || text != "" || html != "" {
+		t.Errorf("Render() = %q, %q, %q, %v, want only the subject", subject, text, html, err)
+	}
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	date := TemplateFuncs["date"].(func(string, any) string)
+	dateTests := []struct {
+		value any
+		want  string
+	}{
+		{time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), "2026-01-02"},
+		{"2026-01-02T03:04:05.123Z", "2026-01-02"},
+		{"yesterday", "yesterday"},
+		{float64(5), "5"},
+		{nil, ""},
+	}
+	for _, tt := range dateTests {
+		if got := date(time.DateOnly, tt.value); got != tt.want {
+			t.Errorf("date(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	def := TemplateFuncs["default"].(func(any, any) any)
+	defaultTests := []struct {
+		value any
+		want  any
+	}{
+		{nil, "fallback"},
+		{"", "fallback"},
+		{float64(0), "fallback"},
+		{false, "fallback"},
+		{map[string]any{}, "fallback"},
+		{[]any{}, "fallback"},
+		{"Ann", "Ann"},
+		{float64(1), float64(1)},
+	}
+	for _, tt := range defaultTests {
+		if got := def("fallback", tt.value); got != tt.want {
+			t.Errorf("default(%v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	toJSON := TemplateFuncs["json"].(func(any) (string, error))
+	got, err := toJSON(map[string]any{"a": "<b>", "c": []any{float64(1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"a\": \"<b>\",\n  \"c\": [\n    1\n  ]\n}"; got != want {
+		t.Errorf("json() = %q, want %q", got, want)
+	}
+	if _, err := toJSON(func() {}); err == nil {
+		t.Error("json() of a function succeeded, want an error")
+	}
+}
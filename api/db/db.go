@@ -3,55 +3,104 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 
 	"github.com/fatih/color"
 	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/sqlite"
 	_ "github.com/lib/pq"
 	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/migrations"
 	"github.com/pressly/goose/v3"
+	_ "modernc.org/sqlite"
 )
 
 // SQLDB stores the current SQL database connection.
 var SQLDB *sql.DB
 
-// InitDB connects to the MySQL DB
+// tempDir holds the database of the temp driver, removed once closed.
+var tempDir string
+
+// Database drivers. See config.RC.Database.Driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverTemp     = "temp" // Throwaway SQLite database, removed once closed
+)
+
+// IsSQLite returns whether the configured driver stores data in SQLite,
+// either kept or thrown away.
+func IsSQLite() bool {
+	return config.RC.Database.Driver == DriverSQLite || config.RC.Database.Driver == DriverTemp
+}
+
+// InitDB connects to the configured database. The throwaway database of the
+// temp driver starts out empty, so is migrated as soon as it is opened.
 func InitDB() {
 	if SQLDB != nil && SQLDB.Ping() == nil {
 		slog.Warn("Database connection already established")
 		return
 	}
 
-	var err error
-	db_path := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
-		config.RC.Database.User, config.RC.Database.Password, config.RC.Database.Host, config.RC.Database.Name)
+	driver := config.RC.Database.Driver
+	name := config.RC.Database.Name
+	if IsSQLite() {
+		name = config.RC.Database.Path
+	}
 
-	SQLDB, err = sql.Open("postgres", db_path)
+	var err error
+	switch driver {
+	case DriverSQLite:
+		SQLDB, err = sql.Open("sqlite", sqliteDSN("file:"+config.RC.Database.Path, "_pragma=journal_mode(WAL)"))
+	case DriverTemp:
+		// An SQLite database held in memory is either private to each
+		// connection or shares a cache which can't wait on locks, so keep it
+		// in a temporary file instead that every connection may use at once
+		name, err = openTemp()
+	default:
+		SQLDB, err = sql.Open("postgres", DSN())
+	}
 	if err != nil {
-		slog.Error("Couldn't open SQL database", "user", config.RC.Database.User, "name", config.RC.Database.Name, "err", err)
+		slog.Error("Couldn't open SQL database", "driver", driver, "user", config.RC.Database.User, "name", name, "err", err)
 		os.Exit(1)
 	}
 
 	if err := SQLDB.Ping(); err != nil {
-		slog.Error("Couldn't ping SQL database", "user", config.RC.Database.User, "name", config.RC.Database.Name, "err", err)
+		slog.Error("Couldn't ping SQL database", "driver", driver, "user", config.RC.Database.User, "name", name, "err", err)
 		os.Exit(1)
 	}
 
-	if err := goose.SetDialect("postgres"); err != nil {
+	dialect := "postgres"
+	if IsSQLite() {
+		dialect = "sqlite3"
+	}
+	goose.SetBaseFS(migrations.Migrations)
+	if err := goose.SetDialect(dialect); err != nil {
 		slog.Error("Couldn't set database dialect for goose", "err", err)
 		os.Exit(1)
 	}
 
 	SQLDB.SetMaxOpenConns(config.RC.Database.MaxConns)
+	if driver == DriverTemp {
+		if err := goose.Up(SQLDB, MigrationsDir()); err != nil {
+			slog.Error("Couldn't migrate throwaway database", "err", err)
+			os.Exit(1)
+		}
+	}
 
 	stats := SQLDB.Stats()
 	slog.Info(
 		"Connected to SQL database",
+		"driver", driver,
 		"user", config.RC.Database.User,
-		"name", config.RC.Database.Name,
+		"name", name,
 		"maxConnections", stats.MaxOpenConnections,
 		"currConnections", stats.OpenConnections,
 	)
@@ -61,18 +110,99 @@ func InitDB() {
 	}
 }
 
-// CloseDB closes the database connection.
+// DSN returns the connection string for the configured Postgres database, for
+// use by connections made outside of SQLDB.
+func DSN() string {
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable",
+		config.RC.Database.User, config.RC.Database.Password, config.RC.Database.Host, config.RC.Database.Name)
+}
+
+// sqliteDSN returns the connection string for an SQLite database, with any
+// additional query parameters. Transactions take the write lock as they begin
+// rather than on their first write, which could otherwise fail if another
+// connection wrote in between, and times are written in a form that sorts.
+func sqliteDSN(uri string, params ...string) string {
+	params = append(params, "_pragma=busy_timeout(5000)", "_txlock=immediate", "_time_format=sqlite")
+	return uri + "?" + strings.Join(params, "&")
+}
+
+// openTemp opens a new SQLite database in a temporary directory as SQLDB,
+// returning its path. Nothing is synced to disk, as the database is thrown
+// away once closed. Directories left by processes which exited without
+// closing their database are removed first.
+func openTemp() (string, error) {
+	removeStaleTemp()
+
+	dir, err := os.MkdirTemp("", fmt.Sprintf("backroom-%d-", os.Getpid()))
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, "backroom.db")
+	SQLDB, err = sql.Open("sqlite", sqliteDSN("file:"+path, "_pragma=journal_mode(WAL)", "_pragma=synchronous(OFF)"))
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	tempDir = dir
+	return path, nil
+}
+
+// removeStaleTemp removes the temporary directories of temp driver databases
+// whose process is no longer running.
+func removeStaleTemp() {
+	dirs, _ := filepath.Glob(filepath.Join(os.TempDir(), "backroom-*-*"))
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(strings.Split(filepath.Base(dir), "-")[1])
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+
+		// Finding a process which has exited fails on Windows, while
+		// signalling one fails elsewhere
+		process, err := os.FindProcess(pid)
+		if err == nil {
+			err = process.Signal(syscall.Signal(0))
+			if !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH) {
+				continue
+			}
+		}
+
+		if err := os.RemoveAll(dir); err != nil {
+			slog.Warn("Couldn't remove stale throwaway database", "path", dir, "err", err)
+		}
+	}
+}
+
+// MigrationsDir returns the directory of the embedded migrations for the
+// configured driver.
+func MigrationsDir() string {
+	if IsSQLite() {
+		return "sqlite"
+	}
+	return "."
+}
+
+// CloseDB closes the database connection, removing the database of the
+// temp driver.
 func CloseDB() {
 	if err := SQLDB.Close(); err != nil {
 		slog.Error("Couldn't close database", "err", err)
 		os.Exit(1)
 	}
+	if tempDir != "" {
+		if err := os.RemoveAll(tempDir); err != nil {
+			slog.Error("Couldn't remove throwaway database", "path", tempDir, "err", err)
+		}
+		tempDir = ""
+	}
 	slog.Info("Closed database connection")
 }
 
 // initDBLogger initializes the database statement logger
 func initDBLogger() {
-	postgres.SetQueryLogger(func(ctx context.Context, queryInfo postgres.QueryInfo) {
+	logger := func(ctx context.Context, queryInfo postgres.QueryInfo) {
 		_, args := queryInfo.Statement.Sql()
 		slog.Debug("Executed SQL query", "args", args, "duration", queryInfo.Duration, "rows", queryInfo.RowsProcessed, "err", queryInfo.Err)
 
@@ -80,5 +210,8 @@ func initDBLogger() {
 		for i, line := range lines {
 			fmt.Fprintf(os.Stderr, "%s\t%s\n", color.CyanString(fmt.Sprintf("%03d", i)), line)
 		}
-	})
+	}
+
+	postgres.SetQueryLogger(logger)
+	sqlite.SetQueryLogger(logger)
 }
@@ -0,0 +1,134 @@
+package db_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+func TestTempQueryDuringTransaction(t *testing.T) {
+	dbtest.Open(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := db.TransactContext(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO api_key (uuid, name, hash, scopes) VALUES ('a', 'a', 'a', '')"); err != nil {
+			return err
+		}
+
+		// Reads outside of the transaction must not wait for it to end
+		var count int
+		if err := db.SQLDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM api_key").Scan(&count); err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Errorf("read outside of the transaction saw %d uncommitted keys", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTempWriteDuringSnapshot(t *testing.T) {
+	dbtest.Open(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := db.SnapshotContext(ctx, func(snapshot *sql.Tx) error {
+		var count int
+		if err := snapshot.QueryRowContext(ctx, "SELECT COUNT(*) FROM api_key").Scan(&count); err != nil {
+			return err
+		}
+
+		// Writes must not wait for the snapshot to end
+		err := db.TransactContext(ctx, func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "INSERT INTO api_key (uuid, name, hash, scopes) VALUES ('a', 'a', 'a', '')")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := snapshot.QueryRowContext(ctx, "SELECT COUNT(*) FROM api_key").Scan(&count); err != nil {
+			return err
+		}
+		if count != 0 {
+			t.Errorf("snapshot saw %d keys written after it began", count)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTempRemovesStale(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	// A process which has exited, leaving its database behind
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	stale := filepath.Join(tmp, fmt.Sprintf("backroom-%d-1", cmd.Process.Pid))
+	if err := os.Mkdir(stale, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	dbtest.Open(t)
+	if _, err := os.Stat(stale); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale database directory not removed: %v", err)
+	}
+	if dirs, _ := filepath.Glob(filepath.Join(tmp, fmt.Sprintf("backroom-%d-*", os.Getpid()))); len(dirs) != 1 {
+		t.Errorf("found %d database directories of this process, want 1", len(dirs))
+	}
+}
+
+func TestAfterTransaction(t *testing.T) {
+	dbtest.Open(t)
+
+	ended := make([]bool, 0)
+	after := func(committed bool) { ended = append(ended, committed) }
+
+	err := db.Transact(func(tx *sql.Tx) error {
+		db.AfterTransaction(db.WithContext(t.Context(), tx), after)
+		if len(ended) != 0 {
+			t.Error("function ran before the transaction ended")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rollback := errors.New("rollback")
+	err = db.Transact(func(tx *sql.Tx) error {
+		db.AfterTransaction(tx, after)
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("error = %v, want the rollback", err)
+	}
+
+	// Statements run outside of a transaction are already committed
+	db.AfterTransaction(db.SQLDB, after)
+
+	if !slices.Equal(ended, []bool{true, false, true}) {
+		t.Errorf("ended with committed %v, want [true false true]", ended)
+	}
+}
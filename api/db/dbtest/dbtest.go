@@ -0,0 +1,45 @@
+// Package dbtest opens throwaway databases for tests, so that packages
+// storing data may be tested without a Postgres server.
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Open connects db.SQLDB to a new, migrated database of the temp driver,
+// which is closed and thrown away once the test ends. Each of stores is
+// selected for the length of the test, as returned by Store for the packages
+// under test.
+func Open(t testing.TB, stores ...StoreSwap) {
+	t.Helper()
+
+	prev := config.RC.Database.Driver
+	config.RC.Database.Driver = db.DriverTemp
+	db.InitDB()
+
+	t.Cleanup(func() {
+		db.CloseDB()
+		db.SQLDB = nil
+		config.RC.Database.Driver = prev
+	})
+
+	for _, swap := range stores {
+		t.Cleanup(swap())
+	}
+}
+
+// StoreSwap selects the store of a package, returning a function which
+// restores the previous store.
+type StoreSwap func() (restore func())
+
+// Store returns a StoreSwap selecting store with the SetStore function of a
+// package, e.g. dbtest.Store(cage.SetStore, cage.Store(&cage.SQLiteStore{})).
+func Store[S any](set func(S) S, store S) StoreSwap {
+	return func() func() {
+		prev := set(store)
+		return func() { set(prev) }
+	}
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// pqUniqueViolation is the Postgres error code of a unique constraint
+// violation.
+const pqUniqueViolation = "23505"
+
+// UniqueViolation reports whether err is a violation of a unique constraint
+// or index, returning its name. SQLite only names indexes on expressions, so
+// the name is empty for violations of other SQLite constraints.
+func UniqueViolation(err error) (string, bool) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Constraint, pqErr.Code == pqUniqueViolation
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		// Reported as "UNIQUE constraint failed: index 'name'"
+		_, name, ok := strings.Cut(sqliteErr.Error(), "index '")
+		if !ok {
+			return "", true
+		}
+		name, _, _ = strings.Cut(name, "'")
+		return name, true
+	}
+
+	return "", false
+}
@@ -43,8 +43,12 @@ func (j JSONB) Value() (driver.Value, error) {
 		return nil, nil
 	}
 
-	// Marshal the JSONB to a byte slice
-	return json.Marshal(j)
+	// Marshal to a string, which SQLite stores as text for its JSON functions
+	data, err := json.Marshal(j)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
 }
 
 func (j JSONB) String() string {
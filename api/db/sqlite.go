@@ -0,0 +1,18 @@
+package db
+
+import (
+	"time"
+
+	"github.com/go-jet/jet/v2/sqlite"
+)
+
+// SQLiteTime returns t as an SQLite timestamp. Times are stored in UTC, as
+// text which only sorts chronologically within a single time zone.
+func SQLiteTime(t time.Time) sqlite.TimestampExpression {
+	return sqlite.RawTimestamp("#time", sqlite.RawArgs{"#time": t.UTC()})
+}
+
+// SQLiteNow returns the current time as an SQLite timestamp.
+func SQLiteNow() sqlite.TimestampExpression {
+	return SQLiteTime(time.Now())
+}
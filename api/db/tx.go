@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/go-jet/jet/v2/qrm"
+)
+
+// Executor is satisfied by both *sql.DB and *sql.Tx, allowing statements to
+// run either directly against the database or as part of a transaction.
+type Executor = qrm.DB
+
+// contextExecutor runs every statement on an Executor with a bound context,
+// in place of whatever context the statement is run with.
+type contextExecutor struct {
+	ctx  context.Context
+	exec Executor
+}
+
+func (e *contextExecutor) Exec(query string, args ...any) (sql.Result, error) {
+	return e.exec.ExecContext(e.ctx, query, args...)
+}
+
+func (e *contextExecutor) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	return e.exec.ExecContext(e.ctx, query, args...)
+}
+
+func (e *contextExecutor) Query(query string, args ...any) (*sql.Rows, error) {
+	return e.exec.QueryContext(e.ctx, query, args...)
+}
+
+func (e *contextExecutor) QueryContext(_ context.Context, query string, args ...any) (*sql.Rows, error) {
+	return e.exec.QueryContext(e.ctx, query, args...)
+}
+
+// WithContext returns an Executor running statements on exec with ctx, such
+// that statements in flight are cancelled once ctx is done. Functions taking
+// an Executor may be made cancellable this way without taking a context.
+func WithContext(ctx context.Context, exec Executor) Executor {
+	if ctx.Done() == nil {
+		return exec // Never cancelled
+	}
+	return &contextExecutor{ctx: ctx, exec: exec}
+}
+
+// afterFuncs holds the functions registered with AfterTransaction for each
+// transaction begun by Transact which has yet to end.
+var (
+	afterMu    sync.Mutex
+	afterFuncs = make(map[*sql.Tx][]func(committed bool))
+)
+
+// AfterTransaction registers fn to run once the transaction behind exec ends,
+// with whether it was committed. Functions run in the order registered, after
+// the transaction has released its locks, so may write to the database
+// themselves. If exec isn't a transaction begun by Transact, its statements
+// are already committed, so fn runs right away.
+func AfterTransaction(exec Executor, fn func(committed bool)) {
+	if ce, ok := exec.(*contextExecutor); ok {
+		exec = ce.exec
+	}
+
+	if tx, ok := exec.(*sql.Tx); ok {
+		afterMu.Lock()
+		funcs, open := afterFuncs[tx]
+		if open {
+			afterFuncs[tx] = append(funcs, fn)
+		}
+		afterMu.Unlock()
+		if open {
+			return
+		}
+	}
+
+	fn(true)
+}
+
+// endTransaction runs the functions registered for tx with AfterTransaction.
+func endTransaction(tx *sql.Tx, committed bool) {
+	afterMu.Lock()
+	funcs := afterFuncs[tx]
+	delete(afterFuncs, tx)
+	afterMu.Unlock()
+
+	for _, fn := range funcs {
+		fn(committed)
+	}
+}
+
+// Transact runs fn within a new database transaction. The transaction is
+// committed if fn returns nil and rolled back otherwise.
+func Transact(fn func(tx *sql.Tx) error) error {
+	return TransactContext(context.Background(), fn)
+}
+
+// TransactContext is like Transact, but the transaction is rolled back if ctx
+// is done before it is committed. Statements made by fn should be run with
+// ctx as well, see WithContext.
+func TransactContext(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := SQLDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	afterMu.Lock()
+	afterFuncs[tx] = nil
+	afterMu.Unlock()
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+		endTransaction(tx, committed)
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	committed = err == nil
+	return err
+}
+
+// Snapshot runs fn within a new read-only transaction, such that every query
+// made by fn sees the database as it was when the transaction began. SQLite
+// transactions are always isolated from other connections, so are only made
+// read-only, which begins them without taking the write lock.
+func Snapshot(fn func(tx *sql.Tx) error) error {
+	return SnapshotContext(context.Background(), fn)
+}
+
+// SnapshotContext is like Snapshot, but the transaction ends if ctx is done
+// before fn returns.
+func SnapshotContext(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	opts := &sql.TxOptions{
+		Isolation: sql.LevelRepeatableRead,
+		ReadOnly:  true,
+	}
+	if IsSQLite() {
+		opts.Isolation = sql.LevelDefault
+	}
+
+	tx, err := SQLDB.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,390 @@
+// Package export streams the records of a cage out of the database in formats
+// suitable for other tools, such as spreadsheets.
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/parquet-go/parquet-go"
+	"github.com/xuri/excelize/v2"
+)
+
+// Export formats.
+const (
+	FormatNDJSON = "ndjson" // One JSON object per record, with nested data
+	FormatCSV    = "csv"    // One row per record, with data flattened into dotted columns
+	FormatXLSX   = "xlsx"   // A spreadsheet laid out like FormatCSV
+	// FormatParquet is a columnar Apache Parquet file laid out like FormatCSV,
+	// with data values stored as text, as their JSON types may vary
+	FormatParquet = "parquet"
+)
+
+var ErrBadFormat = errors.New("bad format")
+
+// ContentTypes maps export formats to their media types.
+var ContentTypes = map[string]string{
+	FormatNDJSON:  "application/x-ndjson",
+	FormatCSV:     "text/csv",
+	FormatXLSX:    "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	FormatParquet: "application/vnd.apache.parquet",
+}
+
+// metaColumns are the record metadata columns leading every tabular export.
+// They are prefixed to keep them apart from data columns of the same name.
+var metaColumns = []string{"_uuid", "_created_at", "_updated_at", "_version"}
+
+// ndjsonRecord is a record as written to an NDJSON export.
+type ndjsonRecord struct {
+	UUID      db.UUID   `json:"uuid"`
+	Cage      string    `json:"cage"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Version   int32     `json:"version"`
+	Data      db.JSONB  `json:"data"`
+}
+
+// Write streams the records of a cage matching query to w in the given
+// format, oldest first. Records are read from a consistent snapshot of the
+// database, without loading the cage into memory. Tabular formats read the
+// records twice, first to discover the columns of their flattened data.
+func Write(w io.Writer, cageKey string, query cage.ListQuery, format string) error {
+	return WriteContext(context.Background(), w, cageKey, query, format)
+}
+
+// WriteContext is like Write, but stops once ctx is done, e.g. because the
+// client went away.
+func WriteContext(ctx context.Context, w io.Writer, cageKey string, query cage.ListQuery, format string) error {
+	if _, ok := ContentTypes[format]; !ok {
+		return fmt.Errorf("%w: %q", ErrBadFormat, format)
+	}
+
+	return db.SnapshotContext(ctx, func(tx *sql.Tx) error {
+		if format == FormatNDJSON {
+			encoder := json.NewEncoder(w)
+			return cage.ScanRecordsTx(tx, cageKey, query, func(record *cage.Record) error {
+				return encoder.Encode(ndjsonRecord{
+					UUID:      record.UUID,
+					Cage:      record.Cage,
+					CreatedAt: record.CreatedAt,
+					UpdatedAt: record.UpdatedAt,
+					Version:   record.Version,
+					Data:      record.Data,
+				})
+			})
+		}
+
+		// Discover every data column before writing the header
+		found := make(map[string]bool)
+		err := cage.ScanRecordsTx(tx, cageKey, query, func(record *cage.Record) error {
+			for column := range Flatten(record.Data) {
+				found[column] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		columns := slices.Sorted(maps.Keys(found))
+
+		var rows rowWriter
+		switch format {
+		case FormatCSV:
+			rows = newCSVWriter(w)
+		case FormatParquet:
+			rows = newParquetWriter(w)
+		default:
+			if rows, err = newXLSXWriter(w); err != nil {
+				return err
+			}
+		}
+
+		if err := rows.header(append(slices.Clone(metaColumns), columns...)); err != nil {
+			return err
+		}
+
+		err = cage.ScanRecordsTx(tx, cageKey, query, func(record *cage.Record) error {
+			flat := Flatten(record.Data)
+			values := make([]any, 0, len(metaColumns)+len(columns))
+			values = append(values, record.UUID.String(), record.CreatedAt.UTC(), record.UpdatedAt.UTC(), record.Version)
+			for _, column := range columns {
+				values = append(values, flat[column])
+			}
+			return rows.row(values)
+		})
+		if err != nil {
+			return err
+		}
+
+		return rows.close()
+	})
+}
+
+// Flatten returns the leaf values of record data keyed by their dotted paths.
+// Arrays are kept whole as leaf values, and empty objects are omitted.
+func Flatten(data db.JSONB) map[string]any {
+	flat := make(map[string]any)
+	flatten("", data.ToMap(), flat)
+	return flat
+}
+
+// flatten stores the leaves of an object in flat, with keys under prefix.
+func flatten(prefix string, object map[string]any, flat map[string]any) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if child, ok := value.(map[string]any); ok {
+			flatten(key, child, flat)
+		} else {
+			flat[key] = value
+		}
+	}
+}
+
+// rowWriter writes rows of a tabular export.
+type rowWriter interface {
+	header(columns []string) error
+	row(values []any) error
+	close() error
+}
+
+// csvWriter writes rows as CSV.
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) header(columns []string) error {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = escapeFormula(column)
+	}
+	return c.writer.Write(cells)
+}
+
+func (c *csvWriter) row(values []any) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		if text, ok := value.(string); ok {
+			cells[i] = escapeFormula(text)
+		} else {
+			cells[i] = formatCell(value)
+		}
+	}
+	return c.writer.Write(cells)
+}
+
+func (c *csvWriter) close() error {
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// escapeFormula prefixes text which a spreadsheet would evaluate as a formula
+// with a quote, so that record data can't inject formulas into the
+// spreadsheets of staff opening an export.
+func escapeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+// formatCell formats a value as CSV cell text. Null and missing values are
+// left empty, and arrays are written as JSON.
+func formatCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+
+	text, _ := json.Marshal(value)
+	return string(text)
+}
+
+// xlsxWriter writes rows to a single sheet spreadsheet.
+type xlsxWriter struct {
+	w         io.Writer
+	file      *excelize.File
+	stream    *excelize.StreamWriter
+	dateStyle int
+	next      int // Next row number
+}
+
+// xlsxSheet is the name of the sheet holding exported records.
+const xlsxSheet = "Records"
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	if err := file.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return nil, err
+	}
+
+	stream, err := file.NewStreamWriter(xlsxSheet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Timestamps are stored as numbers, so must be styled to display as dates
+	dateFormat := "yyyy-mm-dd hh:mm:ss"
+	dateStyle, err := file.NewStyle(&excelize.Style{CustomNumFmt: &dateFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	return &xlsxWriter{w: w, file: file, stream: stream, dateStyle: dateStyle, next: 1}, nil
+}
+
+func (x *xlsxWriter) header(columns []string) error {
+	style, err := x.file.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	cells := make([]any, len(columns))
+	for i, column := range columns {
+		cells[i] = excelize.Cell{StyleID: style, Value: escapeFormula(column)}
+	}
+
+	if err := x.stream.SetPanes(&excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		return err
+	}
+
+	return x.writeRow(cells)
+}
+
+func (x *xlsxWriter) row(values []any) error {
+	cells := make([]any, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case time.Time:
+			cells[i] = excelize.Cell{StyleID: x.dateStyle, Value: v}
+		case string:
+			cells[i] = escapeFormula(v)
+		case nil, bool, float64, int32:
+			cells[i] = v
+		default:
+			cells[i] = formatCell(v)
+		}
+	}
+	return x.writeRow(cells)
+}
+
+// writeRow writes cells to the next row of the sheet.
+func (x *xlsxWriter) writeRow(cells []any) error {
+	cell, err := excelize.CoordinatesToCellName(1, x.next)
+	if err != nil {
+		return err
+	}
+	x.next++
+	return x.stream.SetRow(cell, cells)
+}
+
+func (x *xlsxWriter) close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+// parquetWriter writes rows to a Parquet file. The schema is only known once
+// the header is written, so the underlying writer is created then.
+type parquetWriter struct {
+	w       io.Writer
+	writer  *parquet.Writer
+	columns []int // Index of each export column in the schema
+}
+
+func newParquetWriter(w io.Writer) *parquetWriter {
+	return &parquetWriter{w: w}
+}
+
+func (p *parquetWriter) header(columns []string) error {
+	group := parquet.Group{
+		"_uuid":       parquet.String(),
+		"_created_at": parquet.Timestamp(parquet.Microsecond),
+		"_updated_at": parquet.Timestamp(parquet.Microsecond),
+		"_version":    parquet.Int(32),
+	}
+	for _, column := range columns[len(metaColumns):] {
+		group[column] = parquet.Optional(parquet.String())
+	}
+
+	// Fields of a group are ordered by name, rather than as exported
+	schema := parquet.NewSchema("record", group)
+	index := make(map[string]int, len(columns))
+	for i, path := range schema.Columns() {
+		index[path[0]] = i
+	}
+	p.columns = make([]int, len(columns))
+	for i, column := range columns {
+		p.columns[i] = index[column]
+	}
+
+	p.writer = parquet.NewWriter(p.w, schema)
+	return nil
+}
+
+func (p *parquetWriter) row(values []any) error {
+	row := make(parquet.Row, len(values))
+	for i, value := range values {
+		var v parquet.Value
+		switch value := value.(type) {
+		case nil:
+			v = parquet.NullValue()
+		case time.Time:
+			v = parquet.Int64Value(value.UnixMicro())
+		case int32:
+			v = parquet.Int32Value(value)
+		default:
+			v = parquet.ByteArrayValue([]byte(formatCell(value)))
+		}
+
+		// Metadata columns are required, while data columns are optional,
+		// so present data values are defined at level 1
+		definition := 0
+		if i >= len(metaColumns) && value != nil {
+			definition = 1
+		}
+		row[p.columns[i]] = v.Level(0, definition, p.columns[i])
+	}
+
+	_, err := p.writer.WriteRows([]parquet.Row{row})
+	return err
+}
+
+func (p *parquetWriter) close() error {
+	return p.writer.Close()
+}
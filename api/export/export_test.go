@@ -0,0 +1,79 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+func TestFlatten(t *testing.T) {
+	data := db.JSONB{
+		"name":    "Ann",
+		"age":     float64(30),
+		"tags":    []any{"a", map[string]any{"b": "c"}},
+		"empty":   map[string]any{},
+		"none":    nil,
+		"address": map[string]any{"city": "Wellington", "geo": map[string]any{"lat": -41.3}},
+	}
+	want := map[string]any{
+		"name":            "Ann",
+		"age":             float64(30),
+		"tags":            []any{"a", map[string]any{"b": "c"}},
+		"none":            nil,
+		"address.city":    "Wellington",
+		"address.geo.lat": -41.3,
+	}
+
+	got := Flatten(data)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Flatten() = %v, want %v", got, want)
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"Ann", "Ann"},
+		{"a=b", "a=b"},
+		{"=HYPERLINK(\"http://x\")", "'=HYPERLINK(\"http://x\")"},
+		{"+1", "'+1"},
+		{"-1+2", "'-1+2"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"\r=1", "'\r=1"},
+		{"'quoted", "'quoted"},
+	}
+
+	for _, tt := range tests {
+		if got := escapeFormula(tt.text); got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newCSVWriter(&buf)
+	if err := w.header([]string{"=name", "age", "tags", "created_at", "none"}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := w.row([]any{"=1+1", float64(-5), []any{"a", "b"}, created, nil}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Numbers aren't text, so negative numbers are left unescaped
+	want := "'=name,age,tags,created_at,none\n'=1+1,-5,\"[\"\"a\"\",\"\"b\"\"]\",2026-01-02T03:04:05Z,\n"
+	if buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
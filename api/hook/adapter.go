@@ -1,41 +1,48 @@
 package hook
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"os"
 
 	"github.com/octacian/backroom/api/cage"
 	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
 )
 
 var ErrBadAdapter = errors.New("bad adapter")
 
 // Adapter defines expected execution methods for a hook adapter.
 type Adapter interface {
-	// Run executes the adapter with the given hook and record.
-	Run(action Action, hook *Hook, record *cage.Record) error
+	// Run executes the adapter with the given hook and record. before is the
+	// record data prior to an update, or nil for other actions. Adapters
+	// should give up once ctx is done, which happens when the hook deadline
+	// passes or the workers stop.
+	Run(ctx context.Context, action Action, hook *Hook, record *cage.Record, before db.JSONB) error
 }
 
 // ALLOWED_ADAPTERS is a map of allowed hook adapter names to their respective
 // adapter implementations.
 var ALLOWED_ADAPTERS = map[string]Adapter{
-	"log": &LogAdapter{},
+	"log":     &LogAdapter{},
+	"webhook": NewWebhookAdapter(),
 }
 
 // InitAdapters initializes any adapters requiring dynamic configuration.
+// The smtp adapter sends emails using the configured delivery method.
 func InitAdapters() {
-	if config.RC.Mail.SMTP.Host != "" {
-		smtpAdapter, err := NewSMTPAdapter()
-		if err != nil {
-			slog.Error("Failed to create SMTP adapter", "error", err)
-			os.Exit(1)
-		}
-
-		ALLOWED_ADAPTERS["smtp"] = smtpAdapter
-		slog.Info("SMTP adapter enabled", "host", config.RC.Mail.SMTP.Host)
+	mailer, err := NewMailer()
+	if err != nil {
+		slog.Error("Failed to create mailer", "delivery_method", config.RC.DeliveryMethod, "error", err)
+		os.Exit(1)
+	}
+
+	if mailer != nil {
+		ALLOWED_ADAPTERS["smtp"] = NewEmailAdapter(mailer)
+		slog.Info("Email adapter enabled", "delivery_method", config.RC.DeliveryMethod)
 	} else {
-		slog.Debug("SMTP adapter disabled", "host", config.RC.Mail.SMTP.Host)
+		slog.Debug("Email adapter disabled", "delivery_method", config.RC.DeliveryMethod, "host", config.RC.Mail.SMTP.Host)
 	}
 }
 
@@ -53,7 +60,7 @@ func GetAdapter(name string) (Adapter, error) {
 type LogAdapter struct{}
 
 // Run executes the LogAdapter with the given hook and record.
-func (a *LogAdapter) Run(action Action, hook *Hook, record *cage.Record) error {
+func (a *LogAdapter) Run(ctx context.Context, action Action, hook *Hook, record *cage.Record, before db.JSONB) error {
 	slog.Info("LogAdapter", "action", action, "key", record.Cage, "uuid", record.UUID)
 	return nil
 }
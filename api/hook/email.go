@@ -0,0 +1,169 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Email is a rendered email ready for delivery.
+type Email struct {
+	From    *mail.Address
+	To      *mail.Address
+	Subject string
+	// Text is the plain text body.
+	Text string
+	// HTML is the optional HTML body, an alternative to the plain text body.
+	HTML string
+}
+
+// Mailer delivers emails by some delivery method.
+type Mailer interface {
+	// Send delivers an email, giving up once ctx is done.
+	Send(ctx context.Context, email *Email) error
+}
+
+// NewMailer creates the Mailer for the configured delivery method. Returns
+// nil if the method is smtp, but no SMTP server is configured.
+// See config.RC.DeliveryMethod for configuration.
+func NewMailer() (Mailer, error) {
+	switch config.RC.DeliveryMethod {
+	case "sendgrid":
+		return NewSendGridMailer()
+	case "log-only":
+		if config.RC.Mail.SMTP.Host != "" || config.RC.SendGrid.APIKey != "" {
+			slog.Warn("Emails are only logged, though a mail server is configured", "delivery_method", config.RC.DeliveryMethod)
+		}
+		return &LogMailer{}, nil
+	default:
+		if config.RC.Mail.SMTP.Host == "" {
+			return nil, nil
+		}
+		return NewSMTPMailer()
+	}
+}
+
+// LogMailer is a Mailer that logs emails rather than sending them.
+type LogMailer struct{}
+
+// Send implements Mailer.
+func (m *LogMailer) Send(ctx context.Context, email *Email) error {
+	slog.Info("LogMailer", "from", email.From.String(), "to", email.To.String(),
+		"subject", email.Subject, "text", email.Text, "html", email.HTML)
+	return nil
+}
+
+// EmailAdapter is an adapter that sends an email using a Mailer.
+type EmailAdapter struct {
+	mailer Mailer
+}
+
+// NewEmailAdapter creates a new EmailAdapter sending emails with mailer.
+func NewEmailAdapter(mailer Mailer) *EmailAdapter {
+	return &EmailAdapter{mailer: mailer}
+}
+
+// FromAddress returns the configured from address for emails.
+func FromAddress() *mail.Address {
+	fromName := config.RC.Mail.FromName
+	if fromName == "" {
+		fromName = config.RC.AppName
+	}
+	if fromName == "" {
+		fromName = "backroom"
+	}
+
+	fromAddress := config.RC.Mail.FromAddress
+	if fromAddress == "" {
+		fromAddress = config.RC.AdminEmail
+	}
+	return &mail.Address{Name: fromName, Address: fromAddress}
+}
+
+// EmailData is the data available to the email templates of a hook.
+type EmailData struct {
+	Action    Action
+	Cage      string
+	UUID      string
+	Version   int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Data      map[string]any
+	// Before is the data prior to an update, or nil for other actions.
+	Before map[string]any
+}
+
+// Run executes the EmailAdapter with the given hook and record, sending an
+// email to the hook target. The subject and bodies are rendered from the hook
+// email templates, if any, otherwise the body lists the record data,
+// including the data prior to an update.
+func (a *EmailAdapter) Run(ctx context.Context, action Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	to, err := mail.ParseAddress(hook.Target)
+	if err != nil {
+		return err
+	}
+
+	email := &Email{From: FromAddress(), To: to}
+	email.Subject, email.Text, email.HTML, err = renderEmail(action, hook, record, before)
+	if err != nil {
+		return err
+	}
+
+	if err := a.mailer.Send(ctx, email); err != nil {
+		return err
+	}
+
+	slog.Info("EmailAdapter sent email", "to", hook.Target, "uuid", record.UUID)
+	return nil
+}
+
+// renderEmail returns the subject, plain text body and HTML body of the email
+// sent by a hook, falling back to the defaults for any not templated.
+func renderEmail(action Action, hook *Hook, record *cage.Record, before db.JSONB) (subject, text, html string, err error) {
+	data := &EmailData{
+		Action:    action,
+		Cage:      record.Cage,
+		UUID:      record.UUID.String(),
+		Version:   record.Version,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+		Data:      record.Data.ToMap(),
+	}
+	if before != nil {
+		data.Before = before.ToMap()
+	}
+
+	subject, text, html, err = hook.Email.Render(data)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	if subject == "" {
+		subject = fmt.Sprintf("%s: record %s", record.Cage, action)
+	}
+
+	if text == "" {
+		jsonText, err := json.MarshalIndent(record.Data, "", "  ")
+		if err != nil {
+			return "", "", "", err
+		}
+
+		text = fmt.Sprintf("%s record %s %s\n\n%s", record.Cage, action, record.UUID, jsonText)
+		if before != nil {
+			beforeText, err := json.MarshalIndent(before, "", "  ")
+			if err != nil {
+				return "", "", "", err
+			}
+			text += fmt.Sprintf("\n\nPrevious data:\n\n%s", beforeText)
+		}
+	}
+
+	return subject, text, html, nil
+}
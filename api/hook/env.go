@@ -0,0 +1,34 @@
+package hook
+
+import (
+	"context"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// requestContextKey is the context key for the API request triggering hooks.
+type requestContextKey struct{}
+
+// WithRequest returns a copy of ctx carrying metadata about the API request
+// which may trigger hooks, available to hook conditions as `request.*`.
+func WithRequest(ctx context.Context, req config.HookRequest) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, req)
+}
+
+// NewEnv returns the environment for evaluating hook conditions for an action
+// on a record. before is the record data prior to an update, or nil for other
+// actions. The data of deleted and purged records is passed as the prior data.
+func NewEnv(ctx context.Context, act Action, record *cage.Record, before db.JSONB) *config.HookEnv {
+	if act == ActionDelete || act == ActionPurge {
+		before = record.Data
+	}
+
+	env := config.NewHookEnv(string(act), record.Data.ToMap(), before.ToMap())
+	env.UUID = record.UUID.String()
+	env.CageKey = record.Cage
+	env.CreatedAt = record.CreatedAt
+	env.Request, _ = ctx.Value(requestContextKey{}).(config.HookRequest)
+	return env
+}
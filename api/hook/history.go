@@ -0,0 +1,190 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Hook run statuses.
+const (
+	// RunSucceeded runs completed without error.
+	RunSucceeded = "succeeded"
+	// RunFailed runs returned an error.
+	RunFailed = "failed"
+	// RunCancelled runs were cut short by the hook deadline or the workers
+	// stopping.
+	RunCancelled = "cancelled"
+)
+
+// DefaultRunLimit is the number of runs listed when RunQuery.Limit is unset.
+const DefaultRunLimit = 100
+
+var (
+	ErrRunNotFound    = errors.New("hook run not found")
+	ErrRunNotReplayed = errors.New("hook run succeeded, not replaying")
+	ErrRunReplayed    = errors.New("hook run already replayed successfully")
+)
+
+// Run is a recorded run of a hook against a record, kept in the hook run
+// history whatever its outcome.
+// Wraps generated model.HookRun type.
+type Run = model.HookRun
+
+// RunQuery narrows a listing of hook runs. Empty fields match any run.
+type RunQuery struct {
+	Status string
+	HookID string
+	Cage   string
+	// Cages restricts runs to those against records in the given cages,
+	// unless nil.
+	Cages []string
+	// ReplayOf restricts runs to replays of the run with the ID, unless zero.
+	ReplayOf int64
+	// Limit is the maximum number of runs listed, DefaultRunLimit if unset.
+	Limit int
+}
+
+// newRun returns a run of a hook against a record, yet to be recorded. hook
+// is nil if the hook no longer exists.
+func newRun(hookID string, hook *Hook, act Action, record *cage.Record, before db.JSONB, attempt int32) *Run {
+	run := &Run{
+		HookID:     hookID,
+		Action:     string(act),
+		RecordUUID: record.UUID,
+		Cage:       record.Cage,
+		Data:       record.Data,
+		OldData:    before,
+		Attempt:    attempt,
+	}
+	if hook != nil {
+		run.Adapter = hook.Adapter
+	}
+	return run
+}
+
+// recordRun records the outcome of a run which began at started in the hook
+// run history, setting its ID.
+func recordRun(exec db.Executor, run *Run, started time.Time, err error) error {
+	finishRun(run, started, err)
+	return store.InsertRun(exec, run)
+}
+
+// finishRun sets the outcome of a run which began at started, ready to be
+// recorded.
+func finishRun(run *Run, started time.Time, err error) {
+	run.StartedAt = started.UTC()
+	run.DurationMs = time.Since(started).Milliseconds()
+	run.Error = nil
+	switch {
+	case err == nil:
+		run.Status = RunSucceeded
+	case errors.Is(err, ErrHookCancelled):
+		run.Status = RunCancelled
+	default:
+		run.Status = RunFailed
+	}
+	if err != nil {
+		msg := err.Error()
+		run.Error = &msg
+	}
+}
+
+// GetRun retrieves a hook run by its ID. Returns ErrRunNotFound if there is
+// no such run.
+func GetRun(ctx context.Context, id int64) (*Run, error) {
+	return store.GetRun(db.WithContext(ctx, db.SQLDB), id)
+}
+
+// ListRunsByRecord retrieves the hook runs against a record, newest first.
+func ListRunsByRecord(ctx context.Context, uuid db.UUID) ([]*Run, error) {
+	return store.ListRunsByRecord(db.WithContext(ctx, db.SQLDB), uuid)
+}
+
+// ListRuns retrieves the hook runs matching query, newest first.
+func ListRuns(ctx context.Context, query RunQuery) ([]*Run, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultRunLimit
+	}
+
+	return store.ListRuns(db.WithContext(ctx, db.SQLDB), query)
+}
+
+// ListRunCages retrieves the distinct cages of every hook run.
+func ListRunCages(ctx context.Context) ([]string, error) {
+	return store.ListRunCages(db.WithContext(ctx, db.SQLDB))
+}
+
+// ReplayRun runs the hook of a failed or cancelled run again, with the same
+// record data, recording the outcome as a new run. Returns the new run,
+// ErrRunNotReplayed if the run succeeded, or ErrRunReplayed if a replay of it
+// already succeeded.
+func ReplayRun(ctx context.Context, id int64) (*Run, error) {
+	original, err := GetRun(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status == RunSucceeded {
+		return nil, ErrRunNotReplayed
+	}
+
+	replays, err := ListRuns(ctx, RunQuery{Status: RunSucceeded, ReplayOf: id, Limit: 1})
+	if err != nil {
+		return nil, err
+	} else if len(replays) > 0 {
+		return nil, ErrRunReplayed
+	}
+
+	hook, err := GetHookByID(original.HookID)
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := replayRecord(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	run := newRun(original.HookID, hook, Action(original.Action), record, original.OldData, 1)
+	run.ReplayOf = &original.ID
+
+	started := time.Now()
+	err = runHook(ctx, Action(original.Action), hook, record, original.OldData)
+	if err := recordRun(db.SQLDB, run, started, err); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// replayRecord returns the record a run was against holding the data of the
+// run, with the version and timestamps of the record as it is now, whether
+// live or in the trash. Records since purged only hold what the run does.
+func replayRecord(ctx context.Context, run *Run) (*cage.Record, error) {
+	record, err := cage.GetRecordContext(ctx, run.RecordUUID)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		record, err = cage.GetTrashedRecordContext(ctx, run.RecordUUID)
+	}
+	if errors.Is(err, cage.ErrRecordNotFound) || errors.Is(err, cage.ErrNotInTrash) {
+		record, err = &cage.Record{UUID: run.RecordUUID, Cage: run.Cage}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	record.Data = run.Data
+	return record, nil
+}
+
+// pruneRuns deletes runs which started before the configured retention,
+// returning the number deleted.
+// See config.RC.HookDelivery.RunRetention for configuration.
+func pruneRuns() (int64, error) {
+	before := time.Now().Add(-config.RC.HookDelivery.RunRetention)
+	return store.PruneRuns(db.SQLDB, before)
+}
@@ -1,9 +1,13 @@
 package hook
 
 import (
+	"errors"
+
 	"github.com/octacian/backroom/api/config"
 )
 
+var ErrHookNotFound = errors.New("hook not found")
+
 // Hook defines a hook configuration for a cage.
 type Hook = config.Hook
 
@@ -17,3 +21,14 @@ func ListHooksByCage(cageKey string) ([]Hook, error) {
 	}
 	return hooks, nil
 }
+
+// GetHookByID retrieves a hook by its ID from the configuration.
+// Returns ErrHookNotFound if no configured hook has the ID.
+func GetHookByID(id string) (*Hook, error) {
+	for i := range config.RC.Hooks {
+		if config.RC.Hooks[i].ID() == id {
+			return &config.RC.Hooks[i], nil
+		}
+	}
+	return nil, ErrHookNotFound
+}
@@ -0,0 +1,71 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Modes for running hooks on imported records.
+const (
+	// ImportHooksEach queues create hooks for every imported record.
+	ImportHooksEach = "each"
+	// ImportHooksBatch queues import hooks once per batch of records.
+	ImportHooksBatch = "batch"
+	// ImportHooksNone skips hooks entirely.
+	ImportHooksNone = "none"
+)
+
+var ErrBadHookMode = errors.New("bad hook mode")
+
+// ImportHooks returns a callback for cage.ImportOptions.OnBatch running hooks
+// for imported records according to mode, as with RunHooksByAction. In batch
+// mode, hooks with the import action run once per batch, receiving the count
+// and the UUID and data of each record in the batch. A hook with the abort
+// policy failing rolls back the whole batch.
+func ImportHooks(ctx context.Context, mode string) (func(exec db.Executor, records []*cage.Record) error, error) {
+	switch mode {
+	case ImportHooksEach, "":
+		return func(exec db.Executor, records []*cage.Record) error {
+			for _, record := range records {
+				if err := RunHooksByAction(ctx, exec, ActionCreate, record); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case ImportHooksBatch:
+		return func(exec db.Executor, records []*cage.Record) error {
+			return runImportHooks(ctx, exec, records)
+		}, nil
+	case ImportHooksNone:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrBadHookMode, mode)
+}
+
+// runImportHooks runs import hooks for a batch of imported records.
+func runImportHooks(ctx context.Context, exec db.Executor, records []*cage.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	items := make([]any, len(records))
+	for i, record := range records {
+		items[i] = map[string]any{
+			"uuid": record.UUID.String(),
+			"data": record.Data.ToMap(),
+		}
+	}
+
+	// The batch is identified by a UUID of its own
+	batch := cage.NewRecord(records[0].Cage, db.JSONB{
+		"count":   len(records),
+		"records": items,
+	})
+	return RunHooksByAction(ctx, exec, ActionImport, batch)
+}
@@ -0,0 +1,77 @@
+package hook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// Outbox delivery statuses.
+const (
+	// StatusPending deliveries are waiting to be run, or retried.
+	StatusPending = "pending"
+	// StatusDone deliveries ran successfully.
+	StatusDone = "done"
+	// StatusDead deliveries failed permanently and will not be retried.
+	StatusDead = "dead"
+)
+
+// outboxLease is how long a claimed delivery is hidden from other workers.
+// Deliveries left unfinished after the lease expires, e.g. because a worker
+// crashed, are claimed again.
+const outboxLease = 5 * time.Minute
+
+// OutboxJob is a queued hook delivery.
+// Wraps generated model.HookOutbox type.
+type OutboxJob = model.HookOutbox
+
+// enqueueJob inserts a delivery into the hook outbox.
+func enqueueJob(exec db.Executor, job *OutboxJob) error {
+	return store.InsertJob(exec, job)
+}
+
+// claimJobs claims up to limit pending deliveries which are due to run,
+// incrementing their attempt counts and leasing them to the caller.
+func claimJobs(limit int) ([]*OutboxJob, error) {
+	return store.ClaimJobs(db.SQLDB, limit, outboxLease)
+}
+
+// completeJob marks a delivery as done.
+func completeJob(job *OutboxJob) error {
+	return store.CompleteJob(db.SQLDB, job)
+}
+
+// releaseJob puts a claimed delivery back in the outbox to run again right
+// away, without counting the attempt, e.g. when its run was interrupted by
+// the workers stopping.
+func releaseJob(job *OutboxJob) error {
+	return store.ReleaseJob(db.SQLDB, job)
+}
+
+// failJob records a failed delivery attempt. The delivery is scheduled for
+// retry with exponential backoff, or dead-lettered if it has used all of its
+// attempts or can never succeed.
+func failJob(job *OutboxJob, cause error) error {
+	permanent := errors.Is(cause, ErrHookNotFound) || errors.Is(cause, ErrBadAdapter)
+	if permanent || int(job.Attempts) >= config.RC.HookDelivery.MaxAttempts {
+		return store.FailJob(db.SQLDB, job, StatusDead, cause, time.Now())
+	}
+
+	return store.FailJob(db.SQLDB, job, StatusPending, cause, time.Now().Add(retryDelay(job.Attempts)))
+}
+
+// retryDelay returns how long to wait before retrying a delivery which has
+// been attempted the given number of times.
+func retryDelay(attempts int32) time.Duration {
+	delay := config.RC.HookDelivery.Backoff
+	for i := int32(1); i < attempts; i++ {
+		delay *= 2
+		if delay >= config.RC.HookDelivery.MaxBackoff {
+			return config.RC.HookDelivery.MaxBackoff
+		}
+	}
+	return delay
+}
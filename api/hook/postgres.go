@@ -0,0 +1,211 @@
+package hook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/octacian/backroom/api/.gen/backroom/public/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// PostgresStore is a Store backed by the Postgres database.
+type PostgresStore struct{}
+
+// InsertJob implements Store.
+func (s *PostgresStore) InsertJob(exec db.Executor, job *OutboxJob) error {
+	insert := table.HookOutbox.INSERT(
+		table.HookOutbox.HookID,
+		table.HookOutbox.Action,
+		table.HookOutbox.RecordUUID,
+		table.HookOutbox.Cage,
+		table.HookOutbox.Data,
+		table.HookOutbox.OldData,
+		table.HookOutbox.Status,
+		table.HookOutbox.LastError,
+	).MODEL(job)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// ClaimJobs implements Store, skipping deliveries locked by other workers.
+func (s *PostgresStore) ClaimJobs(exec db.Executor, limit int, lease time.Duration) ([]*OutboxJob, error) {
+	due := table.HookOutbox.SELECT(table.HookOutbox.ID).
+		WHERE(
+			table.HookOutbox.Status.EQ(postgres.String(StatusPending)).
+				AND(table.HookOutbox.RunAt.LT_EQ(postgres.NOW())),
+		).
+		ORDER_BY(table.HookOutbox.RunAt.ASC()).
+		LIMIT(int64(limit)).
+		FOR(postgres.UPDATE().SKIP_LOCKED())
+
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Attempts.SET(table.HookOutbox.Attempts.ADD(postgres.Int(1))),
+			table.HookOutbox.RunAt.SET(postgres.NOW().ADD(postgres.INTERVALd(lease))),
+			table.HookOutbox.UpdatedAt.SET(postgres.NOW()),
+		).
+		WHERE(table.HookOutbox.ID.IN(due)).
+		RETURNING(table.HookOutbox.AllColumns)
+
+	var jobs []*OutboxJob
+	if err := stmt.Query(exec, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// CompleteJob implements Store.
+func (s *PostgresStore) CompleteJob(exec db.Executor, job *OutboxJob) error {
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Status.SET(postgres.String(StatusDone)),
+			table.HookOutbox.LastError.SET(postgres.StringExp(postgres.NULL)),
+			table.HookOutbox.UpdatedAt.SET(postgres.NOW()),
+		).
+		WHERE(table.HookOutbox.ID.EQ(postgres.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// ReleaseJob implements Store.
+func (s *PostgresStore) ReleaseJob(exec db.Executor, job *OutboxJob) error {
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Attempts.SET(table.HookOutbox.Attempts.SUB(postgres.Int(1))),
+			table.HookOutbox.RunAt.SET(postgres.NOW()),
+			table.HookOutbox.UpdatedAt.SET(postgres.NOW()),
+		).
+		WHERE(table.HookOutbox.ID.EQ(postgres.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// FailJob implements Store.
+func (s *PostgresStore) FailJob(exec db.Executor, job *OutboxJob, status string, cause error, runAt time.Time) error {
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Status.SET(postgres.String(status)),
+			table.HookOutbox.LastError.SET(postgres.String(cause.Error())),
+			table.HookOutbox.RunAt.SET(postgres.TimestampzT(runAt)),
+			table.HookOutbox.UpdatedAt.SET(postgres.NOW()),
+		).
+		WHERE(table.HookOutbox.ID.EQ(postgres.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// InsertRun implements Store.
+func (s *PostgresStore) InsertRun(exec db.Executor, run *Run) error {
+	stmt := table.HookRun.INSERT(table.HookRun.MutableColumns).
+		MODEL(run).
+		RETURNING(table.HookRun.ID)
+
+	// Scanning into run would clear the fields not returned
+	var inserted Run
+	if err := stmt.Query(exec, &inserted); err != nil {
+		return err
+	}
+	run.ID = inserted.ID
+	return nil
+}
+
+// GetRun implements Store.
+func (s *PostgresStore) GetRun(exec db.Executor, id int64) (*Run, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(table.HookRun.ID.EQ(postgres.Int(id)))
+
+	var run Run
+	err := stmt.Query(exec, &run)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRunNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListRunsByRecord implements Store.
+func (s *PostgresStore) ListRunsByRecord(exec db.Executor, uuid db.UUID) ([]*Run, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(table.HookRun.RecordUUID.EQ(postgres.String(uuid.String()))).
+		ORDER_BY(table.HookRun.ID.DESC())
+
+	runs := make([]*Run, 0)
+	if err := stmt.Query(exec, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ListRuns implements Store.
+func (s *PostgresStore) ListRuns(exec db.Executor, query RunQuery) ([]*Run, error) {
+	where := postgres.Bool(true)
+	if query.Status != "" {
+		where = where.AND(table.HookRun.Status.EQ(postgres.String(query.Status)))
+	}
+	if query.HookID != "" {
+		where = where.AND(table.HookRun.HookID.EQ(postgres.String(query.HookID)))
+	}
+	if query.Cage != "" {
+		where = where.AND(table.HookRun.Cage.EQ(postgres.String(query.Cage)))
+	}
+	if query.Cages != nil {
+		cages := make([]postgres.Expression, len(query.Cages))
+		for i, cage := range query.Cages {
+			cages[i] = postgres.String(cage)
+		}
+		if len(cages) == 0 {
+			cages = append(cages, postgres.NULL) // Matches nothing
+		}
+		where = where.AND(table.HookRun.Cage.IN(cages...))
+	}
+	if query.ReplayOf != 0 {
+		where = where.AND(table.HookRun.ReplayOf.EQ(postgres.Int(query.ReplayOf)))
+	}
+
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.HookRun.ID.DESC()).
+		LIMIT(int64(query.Limit))
+
+	runs := make([]*Run, 0)
+	if err := stmt.Query(exec, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ListRunCages implements Store.
+func (s *PostgresStore) ListRunCages(exec db.Executor) ([]string, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.Cage).DISTINCT().
+		ORDER_BY(table.HookRun.Cage)
+
+	cages := make([]string, 0)
+	if err := stmt.Query(exec, &cages); err != nil {
+		return nil, err
+	}
+
+	return cages, nil
+}
+
+// PruneRuns implements Store.
+func (s *PostgresStore) PruneRuns(exec db.Executor, before time.Time) (int64, error) {
+	stmt := table.HookRun.DELETE().
+		WHERE(table.HookRun.StartedAt.LT(postgres.TimestampzT(before)))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -1,22 +1,89 @@
 package hook
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"slices"
+	"time"
 
 	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
 )
 
+// ErrHookCancelled wraps the cause of a hook run which was cut short, either
+// because the hook deadline passed or the workers stopped, rather than failed
+// by its adapter.
+var ErrHookCancelled = errors.New("hook cancelled")
+
 type Action string
 
 const (
 	ActionCreate Action = "create"
 	ActionUpdate Action = "update"
-	ActionDelete Action = "delete"
+	ActionDelete Action = "delete" // Record moved to the trash
+	ActionPurge  Action = "purge"  // Record permanently deleted from the trash
+	ActionImport Action = "import" // Batch of records imported, see ImportHooks
+)
+
+// Hook failure policies. See config.Hook.OnFailure.
+const (
+	// OnFailureDefer queues the hook for the workers, which run it once the
+	// write commits and retry it on failure.
+	OnFailureDefer = "defer"
+	// OnFailureAbort runs the hook before the write commits, rolling the
+	// write back if the hook fails. The write transaction, along with the
+	// locks it holds, stays open while the hook runs, blocking other writes
+	// to the same rows, or to the whole database with SQLite, so these hooks
+	// should be quick. Their deadline is capped at maxAbortTimeout.
+	OnFailureAbort = "abort"
+	// OnFailureContinue runs the hook once the write commits, before the
+	// request is answered, logging it if the hook fails.
+	OnFailureContinue = "continue"
 )
 
-// RunHooksByAction runs all hooks for a particular action.
-func RunHooksByAction(act Action, record *cage.Record) error {
+// maxAbortTimeout caps the deadline of hooks with the abort policy, as the
+// write transaction stays open until they finish.
+const maxAbortTimeout = 5 * time.Second
+
+// AbortError is returned when a hook with the abort failure policy fails, so
+// the write which triggered it must be rolled back.
+type AbortError struct {
+	HookID string
+	Err    error
+}
+
+func (e *AbortError) Error() string {
+	return fmt.Sprintf("aborted by hook %s: %v", e.HookID, e.Err)
+}
+
+func (e *AbortError) Unwrap() error {
+	return e.Err
+}
+
+// RunHooksByAction runs all hooks for a particular action on a record. It
+// must be called with the transaction that wrote the record, after the write
+// and before the transaction commits. Deferred hooks are queued for delivery
+// by the hook workers, so that they run if and only if the write is
+// committed. Hooks with the abort policy run right away, inside the
+// transaction, returning an *AbortError if one fails, upon which the
+// transaction must be rolled back. Hooks with the continue policy run once
+// the transaction commits, see db.AfterTransaction.
+func RunHooksByAction(ctx context.Context, exec db.Executor, act Action, record *cage.Record) error {
+	return runHooks(ctx, exec, act, record, nil)
+}
+
+// RunUpdateHooks runs all update hooks like RunHooksByAction, passing the
+// hooks the record data prior to the update along with the updated record.
+func RunUpdateHooks(ctx context.Context, exec db.Executor, record *cage.Record, before db.JSONB) error {
+	return runHooks(ctx, exec, ActionUpdate, record, before)
+}
+
+// runHooks runs all hooks for a particular action according to their failure
+// policies, along with any record data prior to the action.
+func runHooks(ctx context.Context, exec db.Executor, act Action, record *cage.Record, before db.JSONB) error {
 	// Get all hooks for the record's cage
 	hooks, err := ListHooksByCage(record.Cage)
 	if err != nil {
@@ -30,33 +97,148 @@ func RunHooksByAction(act Action, record *cage.Record) error {
 			continue // Skip this hook if the action does not match
 		}
 
-		// Check if the hook condition is met
-		data := record.Data.ToMap()
-		ok, err := hook.Eval(data)
-		if err != nil {
-			slog.Error("Failed to evaluate hook condition", "hook", hook, "error", err)
-			return err
+		switch hook.OnFailure {
+		case OnFailureDefer, "":
+			if err := enqueueHook(ctx, exec, &hook, act, record, before); err != nil {
+				return err
+			}
+		case OnFailureContinue:
+			db.AfterTransaction(exec, func(committed bool) {
+				if committed {
+					runContinueHook(ctx, act, &hook, record, before)
+				}
+			})
+		default:
+			if err := runAbortHook(ctx, exec, act, &hook, record, before); err != nil {
+				return err
+			}
 		}
+	}
 
-		if !ok {
-			slog.Debug("Hook condition not met, skipping", "hook", hook)
-			continue // Skip this hook if the condition is not met
-		}
+	return nil
+}
 
-		// Get the adapter for the hook
-		adapter, err := GetAdapter(hook.Adapter)
-		if err != nil {
-			return err
-		}
+// runAbortHook runs a hook with the abort policy inside the write
+// transaction, returning an *AbortError if it fails.
+func runAbortHook(ctx context.Context, exec db.Executor, act Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	// Check if the hook condition is met
+	started := time.Now()
+	ok, err := hook.Eval(NewEnv(ctx, act, record, before))
+	if err == nil && !ok {
+		slog.Debug("Hook condition not met, skipping", "hook", hook.ID())
+		return nil
+	} else if err == nil {
+		err = runHook(ctx, act, hook, record, before)
+	}
 
-		// Run the adapter with the hook and record
-		if err := adapter.Run(act, &hook, record); err != nil {
-			slog.Error("Failed to run create hook", "hook", hook, "error", err)
-			return err
+	// The run is recorded once the transaction ends, so that runs which roll
+	// the write back are kept. Writing outside of the transaction while it
+	// is open would wait on its lock with SQLite.
+	run := newRun(hook.ID(), hook, act, record, before, 1)
+	finishRun(run, started, err)
+	db.AfterTransaction(exec, func(bool) {
+		if err := store.InsertRun(db.SQLDB, run); err != nil {
+			slog.Error("Failed to record hook run", "hook", run.HookID, "error", err)
 		}
+	})
 
-		slog.Info("Hook executed successfully", "hook", hook, "cage", record.Cage, "uuid", record.UUID)
+	if err != nil {
+		slog.Error("Hook failed, aborting write", "hook", hook.ID(), "action", act, "uuid", record.UUID, "error", err)
+		return &AbortError{HookID: hook.ID(), Err: err}
 	}
-
+	slog.Info("Hook executed successfully", "hook", hook.ID(), "action", act, "cage", record.Cage, "uuid", record.UUID)
 	return nil
 }
+
+// runContinueHook runs a hook with the continue policy once the write has
+// committed, logging it if it fails.
+func runContinueHook(ctx context.Context, act Action, hook *Hook, record *cage.Record, before db.JSONB) {
+	// Check if the hook condition is met
+	started := time.Now()
+	ok, err := hook.Eval(NewEnv(ctx, act, record, before))
+	if err == nil && !ok {
+		slog.Debug("Hook condition not met, skipping", "hook", hook.ID())
+		return
+	} else if err == nil {
+		err = runHook(ctx, act, hook, record, before)
+	}
+
+	run := newRun(hook.ID(), hook, act, record, before, 1)
+	if err := recordRun(db.SQLDB, run, started, err); err != nil {
+		slog.Error("Failed to record hook run", "hook", hook.ID(), "error", err)
+	}
+
+	if err != nil {
+		slog.Error("Hook failed, continuing", "hook", hook.ID(), "action", act, "uuid", record.UUID, "error", err)
+		return
+	}
+	slog.Info("Hook executed successfully", "hook", hook.ID(), "action", act, "cage", record.Cage, "uuid", record.UUID)
+}
+
+// enqueueHook queues a deferred hook for delivery by the hook workers, if its
+// condition is met.
+func enqueueHook(ctx context.Context, exec db.Executor, hook *Hook, act Action, record *cage.Record, before db.JSONB) error {
+	job := &OutboxJob{
+		HookID:     hook.ID(),
+		Action:     string(act),
+		RecordUUID: record.UUID,
+		Cage:       record.Cage,
+		Data:       record.Data,
+		OldData:    before,
+		Status:     StatusPending,
+	}
+
+	// Check if the hook condition is met
+	ok, err := hook.Eval(NewEnv(ctx, act, record, before))
+	if err != nil {
+		// A broken condition mustn't prevent the record from being saved,
+		// so the delivery is dead-lettered for inspection instead.
+		slog.Error("Failed to evaluate hook condition", "hook", hook.ID(), "error", err)
+		msg := err.Error()
+		job.Status = StatusDead
+		job.LastError = &msg
+	} else if !ok {
+		slog.Debug("Hook condition not met, skipping", "hook", hook.ID())
+		return nil
+	}
+
+	return enqueueJob(exec, job)
+}
+
+// runHook executes a single hook against a record using the hook's adapter.
+// before is the record data prior to an update, or nil for other actions.
+// Returns ErrHookCancelled if ctx is done or the hook deadline passes before
+// the adapter finishes.
+func runHook(ctx context.Context, act Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	// Get the adapter for the hook
+	adapter, err := GetAdapter(hook.Adapter)
+	if err != nil {
+		return err
+	}
+
+	timeout := hookTimeout(hook)
+	ctx, cancel := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("deadline of %s exceeded", timeout))
+	defer cancel()
+
+	// Run the adapter with the hook and record
+	err = adapter.Run(ctx, act, hook, record, before)
+	if err != nil && ctx.Err() != nil {
+		// Whatever the adapter made of it, the run was cut short
+		return fmt.Errorf("%w: %w", ErrHookCancelled, context.Cause(ctx))
+	}
+	return err
+}
+
+// hookTimeout returns the deadline for a single run of a hook, capped at
+// maxAbortTimeout for hooks with the abort policy.
+// See config.RC.HookDelivery.Timeout for the default.
+func hookTimeout(hook *Hook) time.Duration {
+	timeout := config.RC.HookDelivery.Timeout
+	if hook.Timeout > 0 {
+		timeout = hook.Timeout
+	}
+	if hook.OnFailure == OnFailureAbort {
+		timeout = min(timeout, maxAbortTimeout)
+	}
+	return timeout
+}
@@ -0,0 +1,160 @@
+package hook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+// countAdapter counts its runs, keeping the last record, failing each with err.
+type countAdapter struct {
+	runs   int
+	record *cage.Record
+	err    error
+}
+
+func (a *countAdapter) Run(ctx context.Context, act Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	a.runs++
+	a.record = record
+	return a.err
+}
+
+// useHooks configures hooks run by a countAdapter for the length of a test.
+func useHooks(t *testing.T, hooks ...config.Hook) *countAdapter {
+	t.Helper()
+	adapter := &countAdapter{}
+	prev := config.RC.Hooks
+	config.RC.Hooks = hooks
+	ALLOWED_ADAPTERS["count"] = adapter
+	t.Cleanup(func() {
+		config.RC.Hooks = prev
+		delete(ALLOWED_ADAPTERS, "count")
+	})
+	return adapter
+}
+
+func TestContinueHooksRunAfterCommit(t *testing.T) {
+	openSQLite(t)
+	adapter := useHooks(t, Hook{Name: "after", Cage: "contact", Action: []string{"create"}, Adapter: "count", OnFailure: OnFailureContinue})
+	adapter.err = errors.New("unreachable")
+
+	record := cage.NewRecord("contact", db.JSONB{"email": "a@example.com"})
+	err := db.Transact(func(tx *sql.Tx) error {
+		if err := RunHooksByAction(context.Background(), tx, ActionCreate, record); err != nil {
+			return err
+		}
+		if adapter.runs != 0 {
+			t.Error("continue hook ran before the write committed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed continue hook error = %v, want the write committed", err)
+	}
+	if adapter.runs != 1 {
+		t.Errorf("continue hook ran %d times after commit, want 1", adapter.runs)
+	}
+
+	runs, err := ListRunsByRecord(context.Background(), record.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != RunFailed {
+		t.Errorf("recorded %d runs, want the failed run", len(runs))
+	}
+
+	rollback := errors.New("rollback")
+	err = db.Transact(func(tx *sql.Tx) error {
+		if err := RunHooksByAction(context.Background(), tx, ActionCreate, record); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) || adapter.runs != 1 {
+		t.Errorf("continue hook ran %d times with rolled back write, want 1", adapter.runs)
+	}
+}
+
+func TestAbortHooksRunInTransaction(t *testing.T) {
+	openSQLite(t)
+	adapter := useHooks(t, Hook{Name: "guard", Cage: "contact", Action: []string{"create"}, Adapter: "count", OnFailure: OnFailureAbort})
+	adapter.err = errors.New("refused")
+
+	record := cage.NewRecord("contact", db.JSONB{"email": "a@example.com"})
+	err := db.Transact(func(tx *sql.Tx) error {
+		return RunHooksByAction(context.Background(), tx, ActionCreate, record)
+	})
+	var abort *AbortError
+	if !errors.As(err, &abort) || abort.HookID != "guard" {
+		t.Errorf("error = %v, want an abort by guard", err)
+	}
+	if adapter.runs != 1 {
+		t.Errorf("abort hook ran %d times, want 1", adapter.runs)
+	}
+
+	runs, err := ListRunsByRecord(context.Background(), record.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Status != RunFailed {
+		t.Errorf("recorded %d runs after the write rolled back, want the failed run", len(runs))
+	}
+}
+
+func TestReplayRun(t *testing.T) {
+	openSQLite(t)
+	t.Cleanup(dbtest.Store(cage.SetStore, cage.Store(&cage.SQLiteStore{}))())
+	adapter := useHooks(t, Hook{Name: "notify", Cage: "contact", Action: []string{"update"}, Adapter: "count"})
+	ctx := context.Background()
+
+	record := cage.NewRecord("contact", db.JSONB{"email": "a@example.com"})
+	if err := cage.CreateRecord(record, "test"); err != nil {
+		t.Fatal(err)
+	}
+	failed := newRun("notify", &config.RC.Hooks[0], ActionUpdate, record, db.JSONB{}, 1)
+	if err := recordRun(db.SQLDB, failed, time.Now(), errors.New("unreachable")); err != nil {
+		t.Fatal(err)
+	}
+
+	record.Data = db.JSONB{"email": "b@example.com"}
+	if err := cage.UpdateRecord(record, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := ReplayRun(ctx, failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != RunSucceeded || run.ReplayOf == nil || *run.ReplayOf != failed.ID {
+		t.Errorf("replay status = %s, want a succeeded replay of the run", run.Status)
+	}
+	if adapter.record.Version != record.Version || adapter.record.Data["email"] != "a@example.com" {
+		t.Errorf("replayed version %d with %v, want version %d with the run data", adapter.record.Version, adapter.record.Data, record.Version)
+	}
+
+	if _, err := ReplayRun(ctx, failed.ID); !errors.Is(err, ErrRunReplayed) {
+		t.Errorf("second replay error = %v, want ErrRunReplayed", err)
+	}
+	if _, err := ReplayRun(ctx, run.ID); !errors.Is(err, ErrRunNotReplayed) {
+		t.Errorf("succeeded run replay error = %v, want ErrRunNotReplayed", err)
+	}
+}
+
+func TestAbortHookTimeoutCapped(t *testing.T) {
+	hook := &Hook{Timeout: time.Minute, OnFailure: OnFailureAbort}
+	if timeout := hookTimeout(hook); timeout != maxAbortTimeout {
+		t.Errorf("abort hook timeout = %v, want %v", timeout, maxAbortTimeout)
+	}
+
+	hook.OnFailure = OnFailureContinue
+	if timeout := hookTimeout(hook); timeout != time.Minute {
+		t.Errorf("continue hook timeout = %v, want a minute", timeout)
+	}
+}
@@ -0,0 +1,105 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/octacian/backroom/api/config"
+)
+
+// maxSendGridError is the most of an error response from SendGrid included
+// in the returned error.
+const maxSendGridError = 1024
+
+// sendGridAddress is an email address in a SendGrid request.
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// sendGridContent is a body of an email in a SendGrid request.
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// sendGridPersonalization names the recipients of an email in a SendGrid
+// request.
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+// sendGridRequest is the JSON body of a SendGrid v3 mail send request.
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+// SendGridMailer is a Mailer that sends emails with the SendGrid v3 HTTP API.
+type SendGridMailer struct {
+	client *http.Client
+	apiKey string
+	url    string
+}
+
+// NewSendGridMailer creates a new SendGridMailer with its own HTTP client.
+// See config.RC.SendGrid for configuration.
+func NewSendGridMailer() (*SendGridMailer, error) {
+	if config.RC.SendGrid.APIKey == "" {
+		return nil, errors.New("sendgrid: missing API key")
+	}
+
+	return &SendGridMailer{
+		client: &http.Client{},
+		apiKey: config.RC.SendGrid.APIKey,
+		url:    config.RC.SendGrid.APIURL,
+	}, nil
+}
+
+// Send implements Mailer. Any response other than 2xx is treated as a failure.
+func (m *SendGridMailer) Send(ctx context.Context, email *Email) error {
+	body := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{
+			To: []sendGridAddress{{Email: email.To.Address, Name: email.To.Name}},
+		}},
+		From:    sendGridAddress{Email: email.From.Address, Name: email.From.Name},
+		Subject: email.Subject,
+		Content: []sendGridContent{{Type: "text/plain", Value: email.Text}},
+	}
+	if email.HTML != "" {
+		body.Content = append(body.Content, sendGridContent{Type: "text/html", Value: email.HTML})
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, maxSendGridError))
+		return fmt.Errorf("sendgrid responded with status %d: %s", res.StatusCode, bytes.TrimSpace(message))
+	}
+	io.Copy(io.Discard, res.Body) // Drain so the connection can be reused
+
+	return nil
+}
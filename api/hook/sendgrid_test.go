@@ -0,0 +1,99 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+
+	"github.com/octacian/backroom/api/config"
+)
+
+// newTestSendGridMailer returns a SendGridMailer sending to a test server
+// which answers with status and records each request it receives.
+func newTestSendGridMailer(t *testing.T, status int, requests *[]*http.Request, bodies *[]sendGridRequest) *SendGridMailer {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body sendGridRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		*requests = append(*requests, r)
+		*bodies = append(*bodies, body)
+
+		w.WriteHeader(status)
+		if status >= 300 {
+			w.Write([]byte(`{"errors":[{"message":"bad sender"}]}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	prev := config.RC.SendGrid
+	config.RC.SendGrid.APIKey = "sg-test"
+	config.RC.SendGrid.APIURL = server.URL
+	t.Cleanup(func() { config.RC.SendGrid = prev })
+
+	mailer, err := NewSendGridMailer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mailer
+}
+
+func TestSendGridMailerSend(t *testing.T) {
+	var requests []*http.Request
+	var bodies []sendGridRequest
+	mailer := newTestSendGridMailer(t, http.StatusAccepted, &requests, &bodies)
+
+	email := &Email{
+		From:    &mail.Address{Name: "backroom", Address: "no-reply@example.com"},
+		To:      &mail.Address{Address: "sales@example.com"},
+		Subject: "New contact",
+		Text:    "Hello",
+		HTML:    "<p>Hello</p>",
+	}
+	if err := mailer.Send(context.Background(), email); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(requests))
+	}
+	req, body := requests[0], bodies[0]
+	if req.Method != http.MethodPost || req.Header.Get("Authorization") != "Bearer sg-test" || req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("sent %s with authorization %q and content type %q", req.Method, req.Header.Get("Authorization"), req.Header.Get("Content-Type"))
+	}
+
+	if len(body.Personalizations) != 1 || len(body.Personalizations[0].To) != 1 || body.Personalizations[0].To[0].Email != "sales@example.com" {
+		t.Errorf("sent to %+v, want sales@example.com", body.Personalizations)
+	}
+	if body.From != (sendGridAddress{Email: "no-reply@example.com", Name: "backroom"}) || body.Subject != "New contact" {
+		t.Errorf("sent from %+v with subject %q", body.From, body.Subject)
+	}
+	want := []sendGridContent{{Type: "text/plain", Value: "Hello"}, {Type: "text/html", Value: "<p>Hello</p>"}}
+	if len(body.Content) != len(want) || body.Content[0] != want[0] || body.Content[1] != want[1] {
+		t.Errorf("sent content %+v, want %+v", body.Content, want)
+	}
+}
+
+func TestSendGridMailerSendError(t *testing.T) {
+	var requests []*http.Request
+	var bodies []sendGridRequest
+	mailer := newTestSendGridMailer(t, http.StatusBadRequest, &requests, &bodies)
+
+	email := &Email{
+		From: &mail.Address{Address: "no-reply@example.com"},
+		To:   &mail.Address{Address: "sales@example.com"},
+		Text: "Hello",
+	}
+	err := mailer.Send(context.Background(), email)
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "bad sender") {
+		t.Errorf("error = %v, want the status and message of the response", err)
+	}
+	if len(bodies) == 1 && len(bodies[0].Content) != 1 {
+		t.Errorf("sent %d bodies without HTML, want only the text body", len(bodies[0].Content))
+	}
+}
@@ -0,0 +1,79 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+)
+
+// SinkAdapters returns adapters which run hooks like ALLOWED_ADAPTERS, but
+// write what they would deliver to w rather than delivering it, for trying
+// hooks out. Webhook requests are answered with 200 OK.
+func SinkAdapters(w io.Writer) map[string]Adapter {
+	return map[string]Adapter{
+		"log":     &LogAdapter{},
+		"webhook": &WebhookAdapter{client: &http.Client{Transport: &sinkTransport{w: w}}},
+		"smtp":    NewEmailAdapter(&sinkMailer{w: w}),
+	}
+}
+
+// DryRun runs a single hook against a record using the adapter from
+// adapters, as returned by SinkAdapters, within the hook deadline. The
+// condition of the hook isn't checked.
+func DryRun(ctx context.Context, adapters map[string]Adapter, act Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	adapter, ok := adapters[hook.Adapter]
+	if !ok {
+		return ErrBadAdapter
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout(hook))
+	defer cancel()
+
+	return adapter.Run(ctx, act, hook, record, before)
+}
+
+// sinkTransport is an http.RoundTripper which writes requests to w and
+// answers them with 200 OK.
+type sinkTransport struct {
+	w io.Writer
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *sinkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump, err := httputil.DumpRequestOut(req, true)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(t.w, "%s\n", bytes.TrimSpace(dump))
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// sinkMailer is a Mailer which writes emails to w.
+type sinkMailer struct {
+	w io.Writer
+}
+
+// Send implements Mailer.
+func (m *sinkMailer) Send(ctx context.Context, email *Email) error {
+	fmt.Fprintf(m.w, "From: %s\nTo: %s\nSubject: %s\n\n%s\n", email.From, email.To, email.Subject, email.Text)
+	if email.HTML != "" {
+		fmt.Fprintf(m.w, "\n%s\n", email.HTML)
+	}
+	return nil
+}
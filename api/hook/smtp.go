@@ -1,23 +1,20 @@
 package hook
 
 import (
-	"encoding/json"
-	"fmt"
-	"log/slog"
+	"context"
 
-	"github.com/octacian/backroom/api/cage"
 	"github.com/octacian/backroom/api/config"
 	"github.com/wneessen/go-mail"
 )
 
-// SMTPAdapter is an adapter that sends an email.
-type SMTPAdapter struct {
+// SMTPMailer is a Mailer that sends emails through an SMTP server.
+type SMTPMailer struct {
 	client *mail.Client
 }
 
-// NewSMTPAdapter creates a new SMTPAdapter with the configured SMTP client.
+// NewSMTPMailer creates a new SMTPMailer with the configured SMTP client.
 // See config.RC.Mail for configuration.
-func NewSMTPAdapter() (*SMTPAdapter, error) {
+func NewSMTPMailer() (*SMTPMailer, error) {
 	opts := make([]mail.Option, 0)
 	if config.RC.Mail.SMTP.TLS {
 		opts = append(opts, mail.WithSSL())
@@ -34,45 +31,27 @@ func NewSMTPAdapter() (*SMTPAdapter, error) {
 		return nil, err
 	}
 
-	return &SMTPAdapter{client: client}, nil
+	return &SMTPMailer{client: client}, nil
 }
 
-// FromAddress returns the configured from address for the SMTP client.
-func (a *SMTPAdapter) FromAddress() string {
-	fromName := config.RC.Mail.FromName
-	if fromName == "" {
-		fromName = config.RC.Mail.FromAddress
-	}
-	if fromName == "" {
-		fromName = "backroom"
-	}
-	return fmt.Sprintf("%s <%s>", fromName, config.RC.Mail.FromAddress)
-}
-
-// Run executes the SMTPAdapter with the given hook and record.
-func (a *SMTPAdapter) Run(action Action, hook *Hook, record *cage.Record) error {
+// Send implements Mailer. Emails with an HTML body are sent as
+// multipart/alternative.
+func (m *SMTPMailer) Send(ctx context.Context, email *Email) error {
 	message := mail.NewMsg()
 
-	if err := message.From(a.FromAddress()); err != nil {
-		return err
-	}
-
-	if err := message.To(hook.Target); err != nil {
+	if err := message.From(email.From.String()); err != nil {
 		return err
 	}
 
-	jsonText, err := json.MarshalIndent(record.Data, "", "  ")
-	if err != nil {
+	if err := message.To(email.To.String()); err != nil {
 		return err
 	}
 
-	message.Subject(fmt.Sprintf("%s: record %s", record.Cage, action))
-	message.SetBodyString(mail.TypeTextPlain, fmt.Sprintf("%s record %s %s\n\n%s", record.Cage, action, record.UUID, jsonText))
-
-	if err := a.client.DialAndSend(message); err != nil {
-		return err
+	message.Subject(email.Subject)
+	message.SetBodyString(mail.TypeTextPlain, email.Text)
+	if email.HTML != "" {
+		message.AddAlternativeString(mail.TypeTextHTML, email.HTML)
 	}
 
-	slog.Info("SMTPAdapter sent email", "to", hook.Target, "uuid", record.UUID)
-	return nil
+	return m.client.DialAndSendWithContext(ctx, message)
 }
@@ -0,0 +1,214 @@
+package hook
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/go-jet/jet/v2/sqlite"
+	"github.com/octacian/backroom/api/.gen/backroom/sqlite/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// SQLiteStore is a Store backed by an SQLite database.
+type SQLiteStore struct{}
+
+// InsertJob implements Store.
+func (s *SQLiteStore) InsertJob(exec db.Executor, job *OutboxJob) error {
+	insert := table.HookOutbox.INSERT(
+		table.HookOutbox.HookID,
+		table.HookOutbox.Action,
+		table.HookOutbox.RecordUUID,
+		table.HookOutbox.Cage,
+		table.HookOutbox.Data,
+		table.HookOutbox.OldData,
+		table.HookOutbox.Status,
+		table.HookOutbox.LastError,
+	).MODEL(job)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// ClaimJobs implements Store. Writers hold the database lock for the whole of
+// their transaction, so deliveries can't be claimed twice.
+func (s *SQLiteStore) ClaimJobs(exec db.Executor, limit int, lease time.Duration) ([]*OutboxJob, error) {
+	now := time.Now().UTC()
+
+	due := table.HookOutbox.SELECT(table.HookOutbox.ID).
+		WHERE(
+			table.HookOutbox.Status.EQ(sqlite.String(StatusPending)).
+				AND(table.HookOutbox.RunAt.LT_EQ(db.SQLiteTime(now))),
+		).
+		ORDER_BY(table.HookOutbox.RunAt.ASC()).
+		LIMIT(int64(limit))
+
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Attempts.SET(table.HookOutbox.Attempts.ADD(sqlite.Int(1))),
+			table.HookOutbox.RunAt.SET(db.SQLiteTime(now.Add(lease))),
+			table.HookOutbox.UpdatedAt.SET(db.SQLiteTime(now)),
+		).
+		WHERE(table.HookOutbox.ID.IN(due)).
+		RETURNING(table.HookOutbox.AllColumns)
+
+	var jobs []*OutboxJob
+	if err := stmt.Query(exec, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// CompleteJob implements Store.
+func (s *SQLiteStore) CompleteJob(exec db.Executor, job *OutboxJob) error {
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Status.SET(sqlite.String(StatusDone)),
+			table.HookOutbox.LastError.SET(sqlite.StringExp(sqlite.NULL)),
+			table.HookOutbox.UpdatedAt.SET(db.SQLiteNow()),
+		).
+		WHERE(table.HookOutbox.ID.EQ(sqlite.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// ReleaseJob implements Store.
+func (s *SQLiteStore) ReleaseJob(exec db.Executor, job *OutboxJob) error {
+	now := time.Now()
+
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Attempts.SET(table.HookOutbox.Attempts.SUB(sqlite.Int(1))),
+			table.HookOutbox.RunAt.SET(db.SQLiteTime(now)),
+			table.HookOutbox.UpdatedAt.SET(db.SQLiteTime(now)),
+		).
+		WHERE(table.HookOutbox.ID.EQ(sqlite.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// FailJob implements Store.
+func (s *SQLiteStore) FailJob(exec db.Executor, job *OutboxJob, status string, cause error, runAt time.Time) error {
+	stmt := table.HookOutbox.UPDATE().
+		SET(
+			table.HookOutbox.Status.SET(sqlite.String(status)),
+			table.HookOutbox.LastError.SET(sqlite.String(cause.Error())),
+			table.HookOutbox.RunAt.SET(db.SQLiteTime(runAt)),
+			table.HookOutbox.UpdatedAt.SET(db.SQLiteNow()),
+		).
+		WHERE(table.HookOutbox.ID.EQ(sqlite.Int(job.ID)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
+
+// InsertRun implements Store.
+func (s *SQLiteStore) InsertRun(exec db.Executor, run *Run) error {
+	stmt := table.HookRun.INSERT(table.HookRun.MutableColumns).
+		MODEL(run).
+		RETURNING(table.HookRun.ID)
+
+	var inserted Run
+	if err := stmt.Query(exec, &inserted); err != nil {
+		return err
+	}
+	run.ID = inserted.ID
+	return nil
+}
+
+// GetRun implements Store.
+func (s *SQLiteStore) GetRun(exec db.Executor, id int64) (*Run, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(table.HookRun.ID.EQ(sqlite.Int(id)))
+
+	var run Run
+	err := stmt.Query(exec, &run)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, ErrRunNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListRunsByRecord implements Store.
+func (s *SQLiteStore) ListRunsByRecord(exec db.Executor, uuid db.UUID) ([]*Run, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(table.HookRun.RecordUUID.EQ(sqlite.String(uuid.String()))).
+		ORDER_BY(table.HookRun.ID.DESC())
+
+	runs := make([]*Run, 0)
+	if err := stmt.Query(exec, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ListRuns implements Store.
+func (s *SQLiteStore) ListRuns(exec db.Executor, query RunQuery) ([]*Run, error) {
+	where := sqlite.Bool(true)
+	if query.Status != "" {
+		where = where.AND(table.HookRun.Status.EQ(sqlite.String(query.Status)))
+	}
+	if query.HookID != "" {
+		where = where.AND(table.HookRun.HookID.EQ(sqlite.String(query.HookID)))
+	}
+	if query.Cage != "" {
+		where = where.AND(table.HookRun.Cage.EQ(sqlite.String(query.Cage)))
+	}
+	if query.Cages != nil {
+		cages := make([]sqlite.Expression, len(query.Cages))
+		for i, cage := range query.Cages {
+			cages[i] = sqlite.String(cage)
+		}
+		if len(cages) == 0 {
+			cages = append(cages, sqlite.NULL) // Matches nothing
+		}
+		where = where.AND(table.HookRun.Cage.IN(cages...))
+	}
+	if query.ReplayOf != 0 {
+		where = where.AND(table.HookRun.ReplayOf.EQ(sqlite.Int(query.ReplayOf)))
+	}
+
+	stmt := table.HookRun.SELECT(table.HookRun.AllColumns).
+		WHERE(where).
+		ORDER_BY(table.HookRun.ID.DESC()).
+		LIMIT(int64(query.Limit))
+
+	runs := make([]*Run, 0)
+	if err := stmt.Query(exec, &runs); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// ListRunCages implements Store.
+func (s *SQLiteStore) ListRunCages(exec db.Executor) ([]string, error) {
+	stmt := table.HookRun.SELECT(table.HookRun.Cage).DISTINCT().
+		ORDER_BY(table.HookRun.Cage)
+
+	cages := make([]string, 0)
+	if err := stmt.Query(exec, &cages); err != nil {
+		return nil, err
+	}
+
+	return cages, nil
+}
+
+// PruneRuns implements Store.
+func (s *SQLiteStore) PruneRuns(exec db.Executor, before time.Time) (int64, error) {
+	stmt := table.HookRun.DELETE().
+		WHERE(table.HookRun.StartedAt.LT(db.SQLiteTime(before)))
+
+	res, err := stmt.Exec(exec)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
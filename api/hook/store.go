@@ -0,0 +1,62 @@
+package hook
+
+import (
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+// Store persists the hook outbox and hook run history. Each method runs on
+// the given executor, so that writes may take part in a wider transaction.
+type Store interface {
+	// InsertJob inserts a delivery into the hook outbox.
+	InsertJob(exec db.Executor, job *OutboxJob) error
+	// ClaimJobs claims up to limit pending deliveries which are due to run,
+	// incrementing their attempt counts and hiding them from other workers
+	// for lease. Deliveries are never claimed twice at once.
+	ClaimJobs(exec db.Executor, limit int, lease time.Duration) ([]*OutboxJob, error)
+	// CompleteJob marks a delivery as done.
+	CompleteJob(exec db.Executor, job *OutboxJob) error
+	// ReleaseJob makes a claimed delivery due right away, without counting
+	// the attempt.
+	ReleaseJob(exec db.Executor, job *OutboxJob) error
+	// FailJob records a failed delivery attempt, setting its status and when
+	// it next runs.
+	FailJob(exec db.Executor, job *OutboxJob, status string, cause error, runAt time.Time) error
+
+	// InsertRun inserts a run into the hook run history, setting its ID.
+	InsertRun(exec db.Executor, run *Run) error
+	// GetRun retrieves a run by its ID. Returns ErrRunNotFound if there is
+	// no such run.
+	GetRun(exec db.Executor, id int64) (*Run, error)
+	// ListRunsByRecord retrieves the runs against a record, newest first.
+	ListRunsByRecord(exec db.Executor, uuid db.UUID) ([]*Run, error)
+	// ListRuns retrieves the runs matching query, newest first, up to the
+	// query limit.
+	ListRuns(exec db.Executor, query RunQuery) ([]*Run, error)
+	// ListRunCages retrieves the distinct cages of every run.
+	ListRunCages(exec db.Executor) ([]string, error)
+	// PruneRuns deletes runs which started before a time, returning the
+	// number deleted.
+	PruneRuns(exec db.Executor, before time.Time) (int64, error)
+}
+
+// store is the Store used by the package functions.
+var store Store = &PostgresStore{}
+
+// InitStore selects the Store matching the configured database driver.
+func InitStore() {
+	if db.IsSQLite() {
+		store = &SQLiteStore{}
+	} else {
+		store = &PostgresStore{}
+	}
+}
+
+// SetStore replaces the Store used by the package functions, returning the
+// previous one.
+func SetStore(s Store) Store {
+	prev := store
+	store = s
+	return prev
+}
@@ -0,0 +1,178 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+// openSQLite opens a throwaway database using the SQLite store, with
+// deliveries retried once after an hour and timing out after a second.
+func openSQLite(t *testing.T) {
+	t.Helper()
+	dbtest.Open(t, dbtest.Store(SetStore, Store(&SQLiteStore{})))
+	delivery := config.RC.HookDelivery
+	config.RC.HookDelivery.MaxAttempts = 2
+	config.RC.HookDelivery.Backoff = time.Hour
+	config.RC.HookDelivery.MaxBackoff = time.Hour
+	config.RC.HookDelivery.Timeout = time.Second
+	t.Cleanup(func() { config.RC.HookDelivery = delivery })
+}
+
+func TestSQLiteOutbox(t *testing.T) {
+	openSQLite(t)
+
+	job := &OutboxJob{
+		HookID:     "notify",
+		Action:     string(ActionCreate),
+		RecordUUID: db.NewUUID(),
+		Cage:       "contact",
+		Data:       db.JSONB{"email": "a@example.com"},
+		Status:     StatusPending,
+	}
+	if err := enqueueJob(db.SQLDB, job); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := claimJobs(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Attempts != 1 {
+		t.Fatalf("claimed %d jobs, want 1 at its first attempt", len(jobs))
+	}
+
+	// Claimed jobs are leased, so aren't claimed again
+	if again, err := claimJobs(10); err != nil || len(again) != 0 {
+		t.Fatalf("claimed %d leased jobs, error = %v", len(again), err)
+	}
+
+	// Failed jobs are retried after a backoff
+	if err := failJob(jobs[0], errors.New("unreachable")); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := claimJobs(10); err != nil || len(again) != 0 {
+		t.Fatalf("claimed %d jobs during backoff, error = %v", len(again), err)
+	}
+
+	// Released jobs are due right away, without counting the attempt
+	if err := releaseJob(jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err = claimJobs(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Attempts != 1 || jobs[0].LastError == nil {
+		t.Fatalf("claimed %d released jobs, want 1 at its first attempt with its last error", len(jobs))
+	}
+}
+
+func TestSQLiteOutboxDeadLetters(t *testing.T) {
+	openSQLite(t)
+
+	job := &OutboxJob{
+		HookID:     "notify",
+		Action:     string(ActionCreate),
+		RecordUUID: db.NewUUID(),
+		Cage:       "contact",
+		Data:       db.JSONB{},
+		Status:     StatusPending,
+	}
+	if err := enqueueJob(db.SQLDB, job); err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := claimJobs(10)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("claimed %d jobs, error = %v", len(jobs), err)
+	}
+
+	// Jobs which can never succeed aren't retried
+	if err := failJob(jobs[0], ErrHookNotFound); err != nil {
+		t.Fatal(err)
+	}
+	if err := releaseJob(jobs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := claimJobs(10); err != nil || len(again) != 0 {
+		t.Errorf("claimed %d dead jobs, error = %v", len(again), err)
+	}
+}
+
+func TestSQLiteRunHistory(t *testing.T) {
+	openSQLite(t)
+	ctx := context.Background()
+
+	record := cage.NewRecord("contact", db.JSONB{"email": "a@example.com"})
+	hook := &Hook{Name: "notify", Adapter: "log"}
+
+	failed := newRun(hook.ID(), hook, ActionCreate, record, nil, 1)
+	if err := recordRun(db.SQLDB, failed, time.Now(), errors.New("unreachable")); err != nil {
+		t.Fatal(err)
+	}
+	succeeded := newRun(hook.ID(), hook, ActionUpdate, record, db.JSONB{}, 1)
+	if err := recordRun(db.SQLDB, succeeded, time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+	other := newRun("other", nil, ActionCreate, cage.NewRecord("other", db.JSONB{}), nil, 1)
+	if err := recordRun(db.SQLDB, other, time.Now(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetRun(ctx, failed.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != RunFailed || got.Error == nil || *got.Error != "unreachable" {
+		t.Errorf("run status = %s, want failed with its error", got.Status)
+	}
+	if _, err := GetRun(ctx, other.ID+1); !errors.Is(err, ErrRunNotFound) {
+		t.Errorf("missing run error = %v, want ErrRunNotFound", err)
+	}
+
+	runs, err := ListRunsByRecord(ctx, record.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != succeeded.ID {
+		t.Errorf("listed %d runs against the record, want 2 newest first", len(runs))
+	}
+
+	runs, err = ListRuns(ctx, RunQuery{Status: RunSucceeded, Cage: "contact"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != succeeded.ID {
+		t.Errorf("listed %d succeeded runs in the cage, want 1", len(runs))
+	}
+
+	runs, err = ListRuns(ctx, RunQuery{Cages: []string{"other"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].ID != other.ID {
+		t.Errorf("listed %d runs in the allowed cages, want the other run", len(runs))
+	}
+	runs, err = ListRuns(ctx, RunQuery{Cages: []string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("listed %d runs with no allowed cages, want none", len(runs))
+	}
+
+	cages, err := ListRunCages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cages) != 2 || cages[0] != "contact" || cages[1] != "other" {
+		t.Errorf("run cages = %v, want [contact other]", cages)
+	}
+}
@@ -0,0 +1,93 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+)
+
+// SignatureHeader is the HTTP header carrying the HMAC-SHA256 signature of a
+// webhook request body, formatted as "sha256=<hex digest>".
+const SignatureHeader = "X-Backroom-Signature"
+
+// webhookEnvelope is the JSON body POSTed by the WebhookAdapter.
+type webhookEnvelope struct {
+	Action    Action    `json:"action"`
+	Cage      string    `json:"cage"`
+	UUID      db.UUID   `json:"uuid"`
+	Data      db.JSONB  `json:"data"`
+	OldData   db.JSONB  `json:"old_data,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookAdapter is an adapter that POSTs the record to an HTTP endpoint.
+type WebhookAdapter struct {
+	client *http.Client
+}
+
+// NewWebhookAdapter creates a new WebhookAdapter with its own HTTP client.
+func NewWebhookAdapter() *WebhookAdapter {
+	return &WebhookAdapter{client: &http.Client{}}
+}
+
+// Sign returns the signature of body using secret, as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run executes the WebhookAdapter with the given hook and record, including
+// the data prior to an update as old_data. Any response other than 2xx is
+// treated as a failure.
+func (a *WebhookAdapter) Run(ctx context.Context, action Action, hook *Hook, record *cage.Record, before db.JSONB) error {
+	body, err := json.Marshal(webhookEnvelope{
+		Action:    action,
+		Cage:      record.Cage,
+		UUID:      record.UUID,
+		Data:      record.Data,
+		OldData:   before,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, value := range hook.Headers {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body) // Drain so the connection can be reused
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	slog.Info("WebhookAdapter delivered record", "target", hook.Target, "uuid", record.UUID, "status", res.StatusCode)
+	return nil
+}
@@ -0,0 +1,68 @@
+package hook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret string
+		body   string
+		want   string
+	}{
+		{"", "", "sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad"},
+		{"key", "The quick brown fox jumps over the lazy dog", "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+	for _, test := range tests {
+		if got := Sign(test.secret, []byte(test.body)); got != test.want {
+			t.Errorf("Sign(%q, %q) = %s, want %s", test.secret, test.body, got, test.want)
+		}
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		status int
+		fails  bool
+	}{
+		{"signed", "s3cret", http.StatusOK, false},
+		{"unsigned", "", http.StatusNoContent, false},
+		{"rejected", "s3cret", http.StatusBadGateway, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body []byte
+			var signature string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ = io.ReadAll(r.Body)
+				signature = r.Header.Get(SignatureHeader)
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			hook := &Hook{Adapter: "webhook", Target: server.URL, Secret: test.secret}
+			record := cage.NewRecord("contact", db.JSONB{"email": "a@example.com"})
+			err := NewWebhookAdapter().Run(context.Background(), ActionCreate, hook, record, nil)
+			if (err != nil) != test.fails {
+				t.Fatalf("error = %v, want failure %v", err, test.fails)
+			}
+
+			want := ""
+			if test.secret != "" {
+				want = Sign(test.secret, body)
+			}
+			if signature != want {
+				t.Errorf("signature = %q, want %q", signature, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+// pruneInterval is how often hook runs beyond their retention are deleted.
+const pruneInterval = 10 * time.Minute
+
+// RunWorkers drains the hook outbox with a pool of workers until ctx is
+// cancelled, pruning the hook run history as it goes, then cancels any
+// in-flight deliveries and waits for them to be released back to the outbox.
+// See config.RC.HookDelivery for configuration.
+func RunWorkers(ctx context.Context) {
+	workers := config.RC.HookDelivery.Workers
+	jobs := make(chan *OutboxJob)
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				deliver(ctx, job)
+			}
+		}()
+	}
+	slog.Info("Hook workers started", "workers", workers)
+
+	ticker := time.NewTicker(config.RC.HookDelivery.PollInterval)
+	defer ticker.Stop()
+
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	pruneHistory()
+
+	for {
+		claimed, err := claimJobs(workers)
+		if err != nil {
+			slog.Error("Failed to claim hook deliveries", "error", err)
+		}
+
+		for _, job := range claimed {
+			jobs <- job
+		}
+
+		// Keep claiming without waiting while there is a backlog
+		if len(claimed) == workers && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			close(jobs)
+			wg.Wait()
+			slog.Info("Hook workers stopped")
+			return
+		case <-prune.C:
+			pruneHistory()
+		case <-ticker.C:
+		}
+	}
+}
+
+// pruneHistory deletes hook runs beyond their retention.
+func pruneHistory() {
+	count, err := pruneRuns()
+	if err != nil {
+		slog.Error("Failed to prune hook runs", "error", err)
+	} else if count > 0 {
+		slog.Info("Pruned hook runs", "count", count)
+	}
+}
+
+// deliver runs a single claimed delivery and records its outcome. Runs
+// cancelled because ctx is done are released without counting as an attempt,
+// while runs cancelled by the hook deadline are retried like failures.
+func deliver(ctx context.Context, job *OutboxJob) {
+	record := &cage.Record{
+		UUID: job.RecordUUID,
+		Cage: job.Cage,
+		Data: job.Data,
+	}
+
+	started := time.Now()
+	hook, err := GetHookByID(job.HookID)
+	if err == nil {
+		err = runHook(ctx, Action(job.Action), hook, record, job.OldData)
+	}
+
+	run := newRun(job.HookID, hook, Action(job.Action), record, job.OldData, job.Attempts)
+	if err := recordRun(db.SQLDB, run, started, err); err != nil {
+		slog.Error("Failed to record hook run", "hook", job.HookID, "uuid", job.RecordUUID, "error", err)
+	}
+
+	switch {
+	case errors.Is(err, ErrHookCancelled) && ctx.Err() != nil:
+		slog.Warn("Hook run cancelled, releasing delivery", "hook", job.HookID, "action", job.Action, "uuid", job.RecordUUID, "attempt", job.Attempts)
+		if err := releaseJob(job); err != nil {
+			slog.Error("Failed to release hook delivery", "id", job.ID, "error", err)
+		}
+		return
+	case errors.Is(err, ErrHookCancelled):
+		slog.Warn("Hook run cancelled", "hook", job.HookID, "action", job.Action, "uuid", job.RecordUUID, "attempt", job.Attempts, "error", err)
+		if err := failJob(job, err); err != nil {
+			slog.Error("Failed to record hook cancellation", "id", job.ID, "error", err)
+		}
+		return
+	case err != nil:
+		slog.Error("Failed to run hook", "hook", job.HookID, "action", job.Action, "uuid", job.RecordUUID, "attempt", job.Attempts, "error", err)
+		if err := failJob(job, err); err != nil {
+			slog.Error("Failed to record hook failure", "id", job.ID, "error", err)
+		}
+		return
+	}
+
+	if err := completeJob(job); err != nil {
+		slog.Error("Failed to record hook completion", "id", job.ID, "error", err)
+		return
+	}
+
+	slog.Info("Hook executed successfully", "hook", job.HookID, "action", job.Action, "cage", job.Cage, "uuid", job.RecordUUID)
+}
@@ -0,0 +1,88 @@
+package httphandle
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/octacian/backroom/api/auth"
+)
+
+// requestKey returns the secret API key sent with a request, either as a
+// bearer token or in the X-API-Key header. Returns an empty string if unset.
+func requestKey(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.Header.Get("X-API-Key")
+}
+
+// requestActor returns the name recorded in record history for changes made
+// by a request: the name of its API key, or "anonymous".
+func requestActor(r *http.Request) string {
+	if key := auth.FromContext(r.Context()); key != nil {
+		return key.Name
+	}
+	return "anonymous"
+}
+
+// idempotencyActor returns the actor idempotency keys sent with a request are
+// stored under, given the hash of the request. Anonymous callers share an
+// actor, so their keys are also scoped by the request, replaying only
+// identical requests rather than answering others with the response or
+// rejection meant for another caller.
+func idempotencyActor(r *http.Request, hash string) string {
+	if auth.FromContext(r.Context()) == nil {
+		return requestActor(r) + ":" + hash
+	}
+	return requestActor(r)
+}
+
+// Authenticate is middleware resolving the API key sent with a request and
+// storing it in the request context. Requests without a key continue
+// anonymously, while requests with an invalid key are rejected.
+func Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := requestKey(r)
+		if secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := auth.Authenticate(secret)
+		if errors.Is(err, auth.ErrInvalidKey) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			slog.Error("Failed to authenticate API key", "error", err)
+			http.Error(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+	})
+}
+
+// authorize checks that the request may perform an operation on a cage,
+// writing an error response if not. Returns false if the request was rejected.
+func authorize(w http.ResponseWriter, r *http.Request, perm auth.Permission, cage string) bool {
+	key := auth.FromContext(r.Context())
+	if auth.Allowed(key, perm, cage) {
+		return true
+	}
+
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Missing API key", http.StatusUnauthorized)
+		return false
+	}
+
+	slog.Warn("API key lacks permission", "key", key.Name, "permission", perm, "cage", cage)
+	http.Error(w, "Forbidden", http.StatusForbidden)
+	return false
+}
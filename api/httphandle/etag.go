@@ -0,0 +1,67 @@
+package httphandle
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/octacian/backroom/api/cage"
+)
+
+// recordETag returns the entity tag of a record, derived from its version.
+func recordETag(record *cage.Record) string {
+	return fmt.Sprintf(`"%d"`, record.Version)
+}
+
+// etagMatches reports whether any entity tag in a comma-separated If-Match or
+// If-None-Match header matches etag. Weak tags only match when weak is true.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks the If-Match header of a request against the current
+// version of a record, responding with 412 Precondition Failed if it doesn't
+// match. Returns the version a write must apply to, which is zero if the
+// header is unset, and false if the request was rejected.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, record *cage.Record) (int32, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, true
+	}
+
+	if !etagMatches(header, recordETag(record), false) {
+		w.Header().Set("ETag", recordETag(record))
+		http.Error(w, "Record version mismatch", http.StatusPreconditionFailed)
+		return 0, false
+	}
+
+	return record.Version, true
+}
+
+// writeVersionMismatch responds to a write which lost a race with another
+// write to the same record. Requests with an If-Match header receive 412
+// Precondition Failed, while others receive 409 Conflict and may be retried.
+func writeVersionMismatch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("If-Match") != "" {
+		http.Error(w, "Record version mismatch", http.StatusPreconditionFailed)
+		return
+	}
+	http.Error(w, "Record changed during write, try again", http.StatusConflict)
+}
@@ -0,0 +1,65 @@
+package httphandle
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/octacian/backroom/api/cage"
+)
+
+func TestETagMatches(t *testing.T) {
+	tests := []struct {
+		header string
+		weak   bool
+		want   bool
+	}{
+		{`"3"`, false, true},
+		{`"4"`, false, false},
+		{`3`, false, false},
+		{`*`, false, true},
+		{`W/"3"`, false, false},
+		{`W/"3"`, true, true},
+		{`"1", "2" , "3"`, false, true},
+		{`"1",W/"3"`, false, false},
+		{`"1",W/"3"`, true, true},
+		{``, true, false},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatches(tt.header, `"3"`, tt.weak); got != tt.want {
+			t.Errorf("etagMatches(%s, weak %v) = %v, want %v", tt.header, tt.weak, got, tt.want)
+		}
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		header  string
+		version int32
+		ok      bool
+	}{
+		{"", 0, true},
+		{`"3"`, 3, true},
+		{`*`, 3, true},
+		{`"2"`, 0, false},
+		{`W/"3"`, 0, false},
+	}
+
+	record := &cage.Record{Version: 3}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tt.header != "" {
+			r.Header.Set("If-Match", tt.header)
+		}
+		w := httptest.NewRecorder()
+
+		version, ok := checkIfMatch(w, r, record)
+		if version != tt.version || ok != tt.ok {
+			t.Errorf("If-Match %s: got version %d and %v, want %d and %v", tt.header, version, ok, tt.version, tt.ok)
+		}
+		if !ok && (w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != `"3"`) {
+			t.Errorf("If-Match %s: responded %d with ETag %s, want 412 with the current ETag", tt.header, w.Code, w.Header().Get("ETag"))
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package httphandle
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/export"
+)
+
+// HandleExportRecords handles streaming the records of a cage as a download.
+// Expects the key as a URL parameter and accepts a format query parameter of
+// ndjson (default), csv, xlsx or parquet, along with the since, until and filter
+// parameters of HandleListRecordsByCage.
+func HandleExportRecords(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		http.Error(w, "Missing cage key", http.StatusBadRequest)
+		return
+	}
+
+	if !authorize(w, r, auth.PermRead, key) {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = export.FormatNDJSON
+	}
+
+	contentType, ok := export.ContentTypes[format]
+	if !ok {
+		http.Error(w, "Invalid format, expected ndjson, csv, xlsx or parquet", http.StatusBadRequest)
+		return
+	}
+
+	query, err := parseListQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", key+"."+format))
+
+	if err := export.WriteContext(r.Context(), w, key, query, format); err != nil {
+		// The response may be partly written, so the error can only be logged
+		slog.Error("Failed to export records", "cage", key, "format", format, "error", err)
+	}
+}
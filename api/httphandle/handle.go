@@ -1,15 +1,20 @@
 package httphandle
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
 	"github.com/octacian/backroom/api/cage"
 	"github.com/octacian/backroom/api/db"
 	"github.com/octacian/backroom/api/hook"
+	"github.com/octacian/backroom/api/idempotency"
 )
 
 // requestCreateRecord is the request body for creating a new caged record.
@@ -18,24 +23,79 @@ type requestCreateRecord struct {
 	Data db.JSONB `json:"data"`
 }
 
+// responseListRecords is the response body for listing caged records.
+type responseListRecords struct {
+	Records    []*cage.Record `json:"records"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
 // responseDelete is the response body for deleting caged record(s).
 type responseDelete struct {
 	Success bool `json:"success"`
 	Deleted int  `json:"deleted"`
 }
 
-// wrapHookRunner just vibes for me.
-func wrapHookRunner(w http.ResponseWriter, action hook.Action, record *cage.Record) bool {
-	if err := hook.RunHooksByAction(action, record); err != nil {
-		slog.Error("Failed to run hooks", "action", action, "error", err)
-		http.Error(w, fmt.Sprintf("Failed to run %s hooks", action), http.StatusInternalServerError)
+// responseSchemaError is the response body for records rejected by their
+// cage schema.
+type responseSchemaError struct {
+	Error      string           `json:"error"`
+	Violations []cage.Violation `json:"violations"`
+}
+
+// writeSchemaError responds with 422 Unprocessable Entity and the list of
+// violations if err is a *cage.SchemaError. Returns false otherwise.
+func writeSchemaError(w http.ResponseWriter, err error) bool {
+	var schemaErr *cage.SchemaError
+	if !errors.As(err, &schemaErr) {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(responseSchemaError{
+		Error:      "Record does not match cage schema",
+		Violations: schemaErr.Violations,
+	})
+	return true
+}
+
+// writeHookError responds with 424 Failed Dependency if err is a
+// *hook.AbortError, meaning a hook failed and the write was rolled back.
+// Returns false otherwise.
+func writeHookError(w http.ResponseWriter, err error) bool {
+	var abortErr *hook.AbortError
+	if !errors.As(err, &abortErr) {
+		return false
+	}
+
+	http.Error(w, fmt.Sprintf("Aborted by failing hook %s", abortErr.HookID), http.StatusFailedDependency)
+	return true
+}
+
+// writeDuplicateError responds with 409 Conflict if err is cage.ErrDuplicate,
+// meaning the record duplicates another at the unique paths of its cage.
+// Returns false otherwise.
+func writeDuplicateError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, cage.ErrDuplicate) {
 		return false
 	}
+
+	http.Error(w, "Record duplicates an existing record", http.StatusConflict)
 	return true
 }
 
 // HandleCreateRecord handles the creation of a new caged record. Expects
-// a JSON payload with the record data. Returns the created record as JSON.
+// a JSON payload with the record data. Returns the created record as JSON, or
+// 422 Unprocessable Entity listing violations of the cage schema. Records
+// duplicating another at the unique paths of the cage are rejected with 409
+// Conflict, or the existing record is returned with 200 OK if the record was
+// merged into it or ignored, as configured by the cage. Records are only
+// merged for keys with write permission on the cage, and ignored otherwise,
+// while keys without read permission receive 202 Accepted without a body
+// rather than the existing record. Requests with an Idempotency-Key header
+// which are retried are answered with the original response, without creating
+// the record again. Anonymous keys only match identical requests, so should
+// still be unguessable.
 func HandleCreateRecord(w http.ResponseWriter, r *http.Request) {
 	var req requestCreateRecord
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -48,23 +108,81 @@ func HandleCreateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	record := cage.NewRecord(req.Cage, req.Data)
-	if err := cage.CreateRecord(record); err != nil {
-		http.Error(w, "Failed to create record", http.StatusInternalServerError)
+	if !authorize(w, r, auth.PermCreate, req.Cage) {
 		return
 	}
 
-	// Run hooks after creating the record
-	if ok := wrapHookRunner(w, hook.ActionCreate, record); !ok {
+	key := r.Header.Get("Idempotency-Key")
+	if len(key) > idempotency.MaxKeyLength {
+		http.Error(w, "Idempotency key too long", http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(record)
+	data, err := json.Marshal(req.Data)
+	if err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+
+	record := cage.NewRecord(req.Cage, req.Data)
+
+	// Public cages accept records from anyone, who mustn't change or read
+	// the records of others through duplicates
+	apiKey := auth.FromContext(r.Context())
+	merge := auth.Allowed(apiKey, auth.PermWrite, req.Cage)
+	existing := func() (*idempotency.Response, error) {
+		if !auth.Allowed(apiKey, auth.PermRead, req.Cage) {
+			return idempotency.NewResponse(http.StatusAccepted, record.UUID, struct{}{})
+		}
+		return idempotency.NewResponse(http.StatusOK, record.UUID, record)
+	}
+
+	hash := idempotency.Hash([]byte(req.Cage), data)
+	res, replayed, err := idempotency.Run(r.Context(), idempotencyActor(r, hash), key, hash, func(tx *sql.Tx) (*idempotency.Response, error) {
+		exec := db.WithContext(r.Context(), tx)
+		outcome, before, err := cage.CreateRecordTx(exec, record, requestActor(r), merge)
+		if err != nil {
+			return nil, err
+		}
+
+		// Run hooks in the same transaction as the record
+		switch outcome {
+		case cage.Merged:
+			if err := hook.RunUpdateHooks(r.Context(), exec, record, before); err != nil {
+				return nil, err
+			}
+			return existing()
+		case cage.Ignored:
+			return existing()
+		}
+		if err := hook.RunHooksByAction(r.Context(), exec, hook.ActionCreate, record); err != nil {
+			return nil, err
+		}
+		return idempotency.NewResponse(http.StatusCreated, record.UUID, record)
+	})
+	if errors.Is(err, idempotency.ErrKeyReused) {
+		http.Error(w, "Idempotency key reused with a different request", http.StatusUnprocessableEntity)
+		return
+	} else if writeSchemaError(w, err) || writeHookError(w, err) || writeDuplicateError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to create record", "cage", req.Cage, "error", err)
+		http.Error(w, "Failed to create record", http.StatusInternalServerError)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.WriteHeader(int(res.Status))
+	if res.Status != http.StatusAccepted {
+		json.NewEncoder(w).Encode(res.Response)
+	}
 }
 
 // HandleGetRecord handles the retrieval of a caged record by its UUID.
-// Expects the UUID as a URL parameter. Returns the record as JSON.
+// Expects the UUID as a URL parameter. Returns the record as JSON with its
+// version as an ETag, or 304 Not Modified if it matches If-None-Match.
 func HandleGetRecord(w http.ResponseWriter, r *http.Request) {
 	uuidStr := chi.URLParam(r, "uuid")
 	if uuidStr == "" {
@@ -78,17 +196,81 @@ func HandleGetRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	record, err := cage.GetRecord(uuid)
-	if err != nil {
+	record, err := cage.GetRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
 		return
 	}
 
+	if !authorize(w, r, auth.PermRead, record.Cage) {
+		return
+	}
+
+	w.Header().Set("ETag", recordETag(record))
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, recordETag(record), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	json.NewEncoder(w).Encode(record)
 }
 
-// HandleListRecordsByCage handles the retrieval of all caged records by their key.
-// Expects the key as a URL parameter. Returns the records as JSON.
+// Limits on the number of records returned by a single listing request.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// parseListQuery reads the optional since, until, filter, sort, limit and
+// cursor query parameters, restricting a listing to matching records created
+// within a time range, ordering and paginating the results.
+func parseListQuery(r *http.Request) (cage.ListQuery, error) {
+	query := cage.ListQuery{
+		Limit:  defaultPageLimit,
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+	var err error
+
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		if query.Filter, err = cage.ParseFilter(filter); err != nil {
+			return query, err
+		}
+	}
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		if query.Sort, err = cage.ParseSort(sort); err != nil {
+			return query, err
+		}
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxPageLimit {
+			return query, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+
+	if since := r.URL.Query().Get("since"); since != "" {
+		if query.Since, err = cage.ParseTime(since); err != nil {
+			return query, err
+		}
+	}
+	if until := r.URL.Query().Get("until"); until != "" {
+		if query.Until, err = cage.ParseTime(until); err != nil {
+			return query, err
+		}
+	}
+
+	return query, nil
+}
+
+// HandleListRecordsByCage handles the retrieval of a page of caged records by
+// their key. Expects the key as a URL parameter, optionally filtered by the
+// since, until and filter query parameters, ordered by the sort query parameter
+// and paginated by the limit and cursor query parameters (see parseListQuery).
+// Returns the records and the cursor of the next page as JSON.
 func HandleListRecordsByCage(w http.ResponseWriter, r *http.Request) {
 	key := chi.URLParam(r, "key")
 	if key == "" {
@@ -96,30 +278,69 @@ func HandleListRecordsByCage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	records, err := cage.ListRecordsByCage(key)
+	if !authorize(w, r, auth.PermRead, key) {
+		return
+	}
+
+	query, err := parseListQuery(r)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	records, next, err := cage.ListRecordsByCageContext(r.Context(), key, query)
+	if errors.Is(err, cage.ErrBadCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to retrieve records", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(records)
+	if records == nil {
+		records = []*cage.Record{}
+	}
+
+	response := responseListRecords{
+		Records:    records,
+		NextCursor: next,
+	}
+	json.NewEncoder(w).Encode(response)
 }
 
-// HandleListCages handles the retrieval of all unique cage keys.
-// Returns the keys as JSON.
+// HandleListCages handles the retrieval of all unique cage keys readable by
+// the request's API key. Returns the keys as JSON.
 func HandleListCages(w http.ResponseWriter, r *http.Request) {
-	keys, err := cage.ListCages()
+	apiKey := auth.FromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	keys, err := cage.ListCagesContext(r.Context())
 	if err != nil {
 		http.Error(w, "Failed to retrieve cages", http.StatusInternalServerError)
 		return
 	}
 
-	json.NewEncoder(w).Encode(keys)
+	readable := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if auth.Allowed(apiKey, auth.PermRead, key) {
+			readable = append(readable, key)
+		}
+	}
+
+	json.NewEncoder(w).Encode(readable)
 }
 
 // HandleUpdateRecord handles the update of an existing caged record.
-// Expects the UUID as a URL parameter and a JSON payload matching requestCreateRecord.
-// Returns the updated record as JSON.
+// Expects the UUID as a URL parameter and a JSON payload matching
+// requestCreateRecord, where the cage key is optional but must match the
+// record if given. Honors If-Match against the record version, responding
+// with 412 Precondition Failed on mismatch. Returns the updated record as JSON,
+// or 422 Unprocessable Entity listing
+// violations of the cage schema.
 func HandleUpdateRecord(w http.ResponseWriter, r *http.Request) {
 	uuidStr := chi.URLParam(r, "uuid")
 	if uuidStr == "" {
@@ -139,33 +360,56 @@ func HandleUpdateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Cage == "" {
-		http.Error(w, "Missing cage key", http.StatusBadRequest)
+	record, err := cage.GetRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
 		return
 	}
 
-	record, err := cage.GetRecord(uuid)
-	if err != nil {
-		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
+	if !authorize(w, r, auth.PermWrite, record.Cage) {
 		return
 	}
-	record.Data = req.Data
 
-	if err := cage.UpdateRecord(record); err != nil {
-		http.Error(w, "Failed to update record", http.StatusInternalServerError)
+	if req.Cage != "" && req.Cage != record.Cage {
+		http.Error(w, "Cage key does not match record", http.StatusBadRequest)
 		return
 	}
 
-	// Run update hooks after updating the record
-	if ok := wrapHookRunner(w, hook.ActionUpdate, record); !ok {
+	if _, ok := checkIfMatch(w, r, record); !ok {
 		return
 	}
+	before := record.Data
+	record.Data = req.Data
 
+	err = db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		if err := cage.UpdateRecordTx(exec, record, requestActor(r)); err != nil {
+			return err
+		}
+		// Run hooks in the same transaction as the update
+		return hook.RunUpdateHooks(r.Context(), exec, record, before)
+	})
+	if errors.Is(err, cage.ErrVersionMismatch) {
+		writeVersionMismatch(w, r)
+		return
+	} else if writeSchemaError(w, err) || writeHookError(w, err) || writeDuplicateError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to update record", "uuid", uuid, "error", err)
+		http.Error(w, "Failed to update record", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", recordETag(record))
 	json.NewEncoder(w).Encode(record)
 }
 
-// HandleDeleteRecord handles the deletion of a caged record by its UUID.
-// Expects the UUID as a URL parameter.
+// HandleDeleteRecord handles moving a caged record to the trash by its UUID.
+// Expects the UUID as a URL parameter and honors If-Match like
+// HandleUpdateRecord.
 // Returns a success message as JSON.
 func HandleDeleteRecord(w http.ResponseWriter, r *http.Request) {
 	uuidStr := chi.URLParam(r, "uuid")
@@ -180,19 +424,40 @@ func HandleDeleteRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	record, err := cage.GetRecord(uuid)
-	if err != nil {
+	record, err := cage.GetRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
 		return
 	}
 
-	if err := cage.DeleteRecord(uuid); err != nil {
-		http.Error(w, "Failed to delete record", http.StatusInternalServerError)
+	if !authorize(w, r, auth.PermWrite, record.Cage) {
 		return
 	}
 
-	// Run delete hooks after deleting the record
-	if ok := wrapHookRunner(w, hook.ActionDelete, record); !ok {
+	version, ok := checkIfMatch(w, r, record)
+	if !ok {
+		return
+	}
+
+	err = db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		if err := cage.DeleteRecordTx(exec, uuid, version, requestActor(r)); err != nil {
+			return err
+		}
+		// Run hooks in the same transaction as the delete
+		return hook.RunHooksByAction(r.Context(), exec, hook.ActionDelete, record)
+	})
+	if errors.Is(err, cage.ErrVersionMismatch) {
+		writeVersionMismatch(w, r)
+		return
+	} else if writeHookError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to delete record", "uuid", uuid, "error", err)
+		http.Error(w, "Failed to delete record", http.StatusInternalServerError)
 		return
 	}
 
@@ -203,8 +468,8 @@ func HandleDeleteRecord(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// HandleDeleteRecordsByKey handles the deletion of all caged records by their key.
-// Expects the key as a URL parameter.
+// HandleDeleteRecordsByKey handles moving all caged records to the trash by
+// their key. Expects the key as a URL parameter.
 // Returns a success message as JSON.
 func HandleDeleteRecordsByKey(w http.ResponseWriter, r *http.Request) {
 	key := chi.URLParam(r, "key")
@@ -213,15 +478,36 @@ func HandleDeleteRecordsByKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleted, err := cage.DeleteCage(key)
-	if err != nil {
+	if !authorize(w, r, auth.PermWrite, key) {
+		return
+	}
+
+	var deleted []*cage.Record
+	err := db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		var err error
+		if deleted, err = cage.DeleteCageTx(exec, key, requestActor(r)); err != nil {
+			return err
+		}
+		// Run hooks for each record in the same transaction as the delete
+		for _, record := range deleted {
+			if err := hook.RunHooksByAction(r.Context(), exec, hook.ActionDelete, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if writeHookError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to delete records", "cage", key, "error", err)
 		http.Error(w, "Failed to delete records", http.StatusInternalServerError)
 		return
 	}
 
 	response := responseDelete{
 		Success: true,
-		Deleted: int(deleted),
+		Deleted: len(deleted),
 	}
 	json.NewEncoder(w).Encode(response)
 }
@@ -0,0 +1,176 @@
+package httphandle
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db/dbtest"
+	"github.com/octacian/backroom/api/hook"
+	"github.com/octacian/backroom/api/idempotency"
+)
+
+// openRouter opens a throwaway database using the SQLite stores, returning a
+// router serving the record routes as the holder of key, or anonymously if
+// key is nil.
+func openRouter(t *testing.T, key *auth.APIKey) http.Handler {
+	t.Helper()
+	dbtest.Open(t,
+		dbtest.Store(cage.SetStore, cage.Store(&cage.SQLiteStore{})),
+		dbtest.Store(hook.SetStore, hook.Store(&hook.SQLiteStore{})),
+		dbtest.Store(idempotency.SetStore, idempotency.Store(&idempotency.SQLiteStore{})),
+	)
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(auth.WithKey(r.Context(), key)))
+		})
+	})
+	r.Post("/record/create", HandleCreateRecord)
+	r.Get("/record/{uuid}", HandleGetRecord)
+	r.Post("/record/{uuid}", HandleUpdateRecord)
+	r.Delete("/record/{uuid}", HandleDeleteRecord)
+	return r
+}
+
+// serve makes a request to router, returning the response.
+func serve(router http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+func TestRecordLifecycle(t *testing.T) {
+	router := openRouter(t, &auth.APIKey{Name: "admin", Scopes: auth.ScopeAdmin})
+
+	w := serve(router, http.MethodPost, "/record/create", `{"cage": "contact", "data": {"email": "a@example.com"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create responded %d: %s", w.Code, w.Body)
+	}
+	var created cage.Record
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	target := "/record/" + created.UUID.String()
+	if w := serve(router, http.MethodGet, target, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "a@example.com") {
+		t.Errorf("get responded %d: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodPost, target, `{"cage": "contact", "data": {"email": "b@example.com"}}`); w.Code != http.StatusOK {
+		t.Errorf("update responded %d: %s", w.Code, w.Body)
+	}
+	if w := serve(router, http.MethodDelete, target, ""); w.Code != http.StatusOK {
+		t.Errorf("delete responded %d: %s", w.Code, w.Body)
+	}
+
+	// Trashed records are missing, like those never created
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		if w := serve(router, method, target, `{"cage": "contact", "data": {}}`); w.Code != http.StatusNotFound {
+			t.Errorf("%s of trashed record responded %d, want 404", method, w.Code)
+		}
+	}
+}
+
+func TestAnonymousIdempotencyKeys(t *testing.T) {
+	router := openRouter(t, nil)
+	prev := *config.RC
+	config.RC.Auth.PublicCages = []string{"newsletter"}
+	config.RC.Idempotency.Window = time.Hour
+	t.Cleanup(func() { *config.RC = prev })
+
+	create := func(email string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/record/create", strings.NewReader(`{"cage": "newsletter", "data": {"email": "`+email+`"}}`))
+		r.Header.Set("Idempotency-Key", "signup")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	w := create("a@example.com")
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create responded %d: %s", w.Code, w.Body)
+	}
+	var first cage.Record
+	if err := json.NewDecoder(w.Body).Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another caller reusing the key with their own data creates their record
+	if w := create("b@example.com"); w.Code != http.StatusCreated || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("create with reused key responded %d: %s", w.Code, w.Body)
+	}
+	if w := create("a@example.com"); w.Header().Get("Idempotent-Replayed") != "true" || !strings.Contains(w.Body.String(), first.UUID.String()) {
+		t.Errorf("retried create responded %d: %s, want the original response", w.Code, w.Body)
+	}
+}
+
+func TestAnonymousDuplicates(t *testing.T) {
+	router := openRouter(t, nil)
+	prev := *config.RC
+	config.RC.Auth.PublicCages = []string{"merge", "ignore"}
+	config.RC.Cages = []config.Cage{
+		{Key: "merge", Unique: []string{"email"}, OnConflict: cage.ConflictMerge},
+		{Key: "ignore", Unique: []string{"email"}, OnConflict: cage.ConflictIgnore},
+	}
+	t.Cleanup(func() { *config.RC = prev })
+	if err := cage.SyncUniqueIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"merge", "ignore"} {
+		w := serve(router, http.MethodPost, "/record/create", `{"cage": "`+key+`", "data": {"email": "a@example.com", "name": "A"}}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s create responded %d: %s", key, w.Code, w.Body)
+		}
+		var original cage.Record
+		if err := json.NewDecoder(w.Body).Decode(&original); err != nil {
+			t.Fatal(err)
+		}
+
+		// Duplicates neither reveal nor change the existing record
+		w = serve(router, http.MethodPost, "/record/create", `{"cage": "`+key+`", "data": {"email": "a@example.com", "name": "B"}}`)
+		if w.Code != http.StatusAccepted || w.Body.Len() != 0 {
+			t.Errorf("%s duplicate responded %d: %s, want 202 without a body", key, w.Code, w.Body)
+		}
+		record, err := cage.GetRecord(original.UUID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Data["name"] != "A" || record.Version != original.Version {
+			t.Errorf("%s duplicate changed the existing record to %v", key, record.Data)
+		}
+	}
+}
+
+func TestAdminDuplicates(t *testing.T) {
+	router := openRouter(t, &auth.APIKey{Name: "admin", Scopes: auth.ScopeAdmin})
+	prev := *config.RC
+	config.RC.Cages = []config.Cage{{Key: "merge", Unique: []string{"email"}, OnConflict: cage.ConflictMerge}}
+	t.Cleanup(func() { *config.RC = prev })
+	if err := cage.SyncUniqueIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := serve(router, http.MethodPost, "/record/create", `{"cage": "merge", "data": {"email": "a@example.com", "name": "A"}}`); w.Code != http.StatusCreated {
+		t.Fatalf("create responded %d: %s", w.Code, w.Body)
+	}
+	w := serve(router, http.MethodPost, "/record/create", `{"cage": "merge", "data": {"email": "a@example.com", "phone": "123"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("duplicate responded %d: %s, want 200", w.Code, w.Body)
+	}
+	var merged cage.Record
+	if err := json.NewDecoder(w.Body).Decode(&merged); err != nil {
+		t.Fatal(err)
+	}
+	if merged.Data["name"] != "A" || merged.Data["phone"] != "123" {
+		t.Errorf("merged record holds %v, want name and phone", merged.Data)
+	}
+}
@@ -0,0 +1,136 @@
+package httphandle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+)
+
+// HookRequest is middleware storing metadata about a request in its context,
+// for the conditions of any hooks it triggers. Must follow Authenticate, so
+// that the API key is known.
+func HookRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := config.HookRequest{
+			IP:        r.RemoteAddr,
+			UserAgent: r.UserAgent(),
+		}
+		// RemoteAddr only holds a port if not set from a proxy header by
+		// middleware.RealIP, which trusts those headers from any client
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			req.IP = host
+		}
+		if key := auth.FromContext(r.Context()); key != nil {
+			req.APIKey = key.Name
+		}
+
+		next.ServeHTTP(w, r.WithContext(hook.WithRequest(r.Context(), req)))
+	})
+}
+
+// responseListRuns is the response body listing hook runs.
+type responseListRuns struct {
+	Runs []*hook.Run `json:"runs"`
+}
+
+// HandleListRecordHooks handles listing the hook runs against a record,
+// newest first. Expects the UUID as a URL parameter.
+func HandleListRecordHooks(w http.ResponseWriter, r *http.Request) {
+	uuid, err := db.ParseUUID(chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		return
+	}
+
+	runs, err := hook.ListRunsByRecord(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to retrieve hook runs", http.StatusInternalServerError)
+		return
+	}
+
+	// Runs share the cage of their record, which must exist if there are none
+	cageKey := ""
+	if len(runs) > 0 {
+		cageKey = runs[0].Cage
+	} else {
+		record, err := cage.GetRecordContext(r.Context(), uuid)
+		if errors.Is(err, cage.ErrRecordNotFound) {
+			http.Error(w, "Record not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
+			return
+		}
+		cageKey = record.Cage
+	}
+
+	if !authorize(w, r, auth.PermRead, cageKey) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(responseListRuns{Runs: runs})
+}
+
+// HandleListHookRuns handles listing hook runs, newest first. Accepts the
+// optional status, hook, cage and limit query parameters. Only runs against
+// records in cages readable by the API key are listed.
+func HandleListHookRuns(w http.ResponseWriter, r *http.Request) {
+	apiKey := auth.FromContext(r.Context())
+	if apiKey == nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Missing API key", http.StatusUnauthorized)
+		return
+	}
+
+	query := hook.RunQuery{
+		Status: r.URL.Query().Get("status"),
+		HookID: r.URL.Query().Get("hook"),
+		Cage:   r.URL.Query().Get("cage"),
+	}
+	switch query.Status {
+	case "", hook.RunSucceeded, hook.RunFailed, hook.RunCancelled:
+	default:
+		http.Error(w, "Invalid status", http.StatusBadRequest)
+		return
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		var err error
+		query.Limit, err = strconv.Atoi(limit)
+		if err != nil || query.Limit < 1 || query.Limit > maxPageLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxPageLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Restrict the query to readable cages, so that the limit counts only
+	// runs which are listed
+	cages, err := hook.ListRunCages(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to retrieve hook runs", http.StatusInternalServerError)
+		return
+	}
+	query.Cages = make([]string, 0, len(cages))
+	for _, cage := range cages {
+		if auth.Allowed(apiKey, auth.PermRead, cage) {
+			query.Cages = append(query.Cages, cage)
+		}
+	}
+
+	runs, err := hook.ListRuns(r.Context(), query)
+	if err != nil {
+		http.Error(w, "Failed to retrieve hook runs", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(responseListRuns{Runs: runs})
+}
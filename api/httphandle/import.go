@@ -0,0 +1,85 @@
+package httphandle
+
+import (
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/hook"
+)
+
+// importFormats maps request content types to import formats.
+var importFormats = map[string]string{
+	"application/x-ndjson": cage.FormatNDJSON,
+	"application/ndjson":   cage.FormatNDJSON,
+	"application/json":     cage.FormatJSON,
+	"text/csv":             cage.FormatCSV,
+}
+
+// HandleImportRecords handles a bulk import of records into a cage. Expects
+// the key as a URL parameter and a body of NDJSON, a JSON array or CSV, as
+// given by the Content-Type or otherwise detected. Accepts optional query
+// parameters: hooks (each, batch or none), map (repeated column=path pairs
+// for CSV), coerce (false to keep CSV values as strings) and batch_size.
+// Returns the number of imported records and any per-line errors as JSON.
+// Requires a key with write permission, even on public cages, as imports may
+// skip the hooks run on creating records.
+func HandleImportRecords(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		http.Error(w, "Missing cage key", http.StatusBadRequest)
+		return
+	}
+
+	if !authorize(w, r, auth.PermWrite, key) {
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	opts := cage.ImportOptions{
+		Format:  importFormats[contentType],
+		Mapping: make(map[string]string),
+		Coerce:  r.URL.Query().Get("coerce") != "false",
+	}
+
+	for _, pair := range r.URL.Query()["map"] {
+		column, path, ok := strings.Cut(pair, "=")
+		if !ok {
+			http.Error(w, "Invalid map, expected column=path", http.StatusBadRequest)
+			return
+		}
+		opts.Mapping[column] = path
+	}
+
+	if batchSize := r.URL.Query().Get("batch_size"); batchSize != "" {
+		var err error
+		if opts.BatchSize, err = strconv.Atoi(batchSize); err != nil || opts.BatchSize < 1 {
+			http.Error(w, "Invalid batch_size", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var err error
+	if opts.OnBatch, err = hook.ImportHooks(r.Context(), r.URL.Query().Get("hooks")); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := cage.ImportRecordsContext(r.Context(), r.Body, key, opts, requestActor(r))
+	if result == nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		// Records read before the input broke off were still imported
+		slog.Warn("Import ended early", "cage", key, "imported", result.Imported, "error", err)
+		result.Errors = append(result.Errors, cage.ImportError{Err: err, Error: err.Error()})
+	}
+
+	json.NewEncoder(w).Encode(result)
+}
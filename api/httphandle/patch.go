@@ -0,0 +1,95 @@
+package httphandle
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+)
+
+// HandlePatchRecord handles a partial update of an existing caged record.
+// Expects the UUID as a URL parameter and either a JSON Merge Patch
+// (application/merge-patch+json) or a JSON Patch (application/json-patch+json)
+// payload, and honors If-Match like HandleUpdateRecord. Returns the patched
+// record as JSON.
+func HandlePatchRecord(w http.ResponseWriter, r *http.Request) {
+	uuid, err := db.ParseUUID(chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		return
+	}
+
+	format, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if format != cage.MergePatch && format != cage.JSONPatch {
+		w.Header().Set("Accept-Patch", cage.MergePatch+", "+cage.JSONPatch)
+		http.Error(w, "Unsupported patch format", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read patch", http.StatusBadRequest)
+		return
+	}
+
+	record, err := cage.GetRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
+		return
+	}
+
+	if !authorize(w, r, auth.PermWrite, record.Cage) {
+		return
+	}
+
+	version, ok := checkIfMatch(w, r, record)
+	if !ok {
+		return
+	}
+
+	err = db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		patched, before, err := cage.PatchRecordTx(exec, uuid, version, format, patch, requestActor(r))
+		if err != nil {
+			return err
+		}
+		record = patched
+		// Run hooks in the same transaction as the patch
+		return hook.RunUpdateHooks(r.Context(), exec, record, before)
+	})
+	switch {
+	case errors.Is(err, cage.ErrRecordNotFound):
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	case errors.Is(err, cage.ErrVersionMismatch):
+		writeVersionMismatch(w, r)
+		return
+	case errors.Is(err, cage.ErrBadPatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, cage.ErrPatchConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case writeSchemaError(w, err), writeHookError(w, err), writeDuplicateError(w, err):
+		return
+	case err != nil:
+		slog.Error("Failed to patch record", "uuid", uuid, "error", err)
+		http.Error(w, "Failed to patch record", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", recordETag(record))
+	json.NewEncoder(w).Encode(record)
+}
@@ -0,0 +1,104 @@
+package httphandle
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+)
+
+// HandleListRevisions handles the retrieval of the history of a caged record.
+// Expects the UUID as a URL parameter. Returns the revisions as JSON, newest
+// first.
+func HandleListRevisions(w http.ResponseWriter, r *http.Request) {
+	uuid, err := db.ParseUUID(chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := cage.ListRevisionsContext(r.Context(), uuid)
+	if err != nil {
+		http.Error(w, "Failed to retrieve revisions", http.StatusInternalServerError)
+		return
+	}
+
+	if len(revisions) == 0 {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	}
+
+	if !authorize(w, r, auth.PermRead, revisions[0].Cage) {
+		return
+	}
+
+	json.NewEncoder(w).Encode(revisions)
+}
+
+// HandleRestoreRevision handles restoring a caged record to one of its
+// revisions. Expects the UUID and revision number as URL parameters, and
+// honors If-Match like HandleUpdateRecord. Returns the restored record as JSON.
+func HandleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	uuid, err := db.ParseUUID(chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		return
+	}
+
+	revision, err := strconv.ParseInt(chi.URLParam(r, "rev"), 10, 32)
+	if err != nil || revision < 1 {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	record, err := cage.GetRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrRecordNotFound) {
+		http.Error(w, "Record not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
+		return
+	}
+
+	if !authorize(w, r, auth.PermWrite, record.Cage) {
+		return
+	}
+
+	if _, ok := checkIfMatch(w, r, record); !ok {
+		return
+	}
+
+	before := record.Data
+	err = db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		if err := cage.RestoreRevisionTx(exec, record, int32(revision), requestActor(r)); err != nil {
+			return err
+		}
+		// A restore changes the record data like any other update
+		return hook.RunUpdateHooks(r.Context(), exec, record, before)
+	})
+	if errors.Is(err, cage.ErrRevisionNotFound) {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, cage.ErrVersionMismatch) {
+		writeVersionMismatch(w, r)
+		return
+	} else if writeSchemaError(w, err) || writeHookError(w, err) || writeDuplicateError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to restore record", "uuid", uuid, "revision", revision, "error", err)
+		http.Error(w, "Failed to restore record", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", recordETag(record))
+	json.NewEncoder(w).Encode(record)
+}
@@ -0,0 +1,242 @@
+package httphandle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/stream"
+)
+
+// keepAliveInterval is how often idle change streams are sent a keep-alive.
+const keepAliveInterval = 30 * time.Second
+
+// socketWriteTimeout is the maximum duration of a single WebSocket write.
+const socketWriteTimeout = 10 * time.Second
+
+// keyCheckInterval is how often the API key of a change stream is checked,
+// so that streams end soon after their key is revoked.
+const keyCheckInterval = time.Minute
+
+var (
+	// errStreamReset is returned by streamEvents if the subscription ended
+	// before the client went away, meaning the client should resume the
+	// stream.
+	errStreamReset = errors.New("stream reset")
+	// errKeyRevoked is returned by streamEvents if the API key of the stream
+	// was revoked or no longer grants access to the cage.
+	errKeyRevoked = errors.New("api key revoked")
+)
+
+// HandleStreamRecords handles pushing changes to the records of a cage as
+// they happen. Expects the key as a URL parameter. Events are sent as
+// Server-Sent Events, or as JSON messages if the request is a WebSocket
+// upgrade. Streams resume after the event named by the Last-Event-ID header
+// or last_event_id query parameter, if set.
+func HandleStreamRecords(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		http.Error(w, "Missing cage key", http.StatusBadRequest)
+		return
+	}
+
+	if !authorize(w, r, auth.PermRead, key) {
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	var after *db.UUID
+	if lastID != "" {
+		uuid, err := db.ParseUUID(lastID)
+		if err != nil {
+			http.Error(w, "Invalid last event ID", http.StatusBadRequest)
+			return
+		}
+		after = &uuid
+	}
+
+	// Subscribe before replaying, so that no events are missed in between
+	sub, err := stream.Subscribe(key)
+	if errors.Is(err, stream.ErrUnavailable) {
+		http.Error(w, "Event stream unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer sub.Close()
+
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		streamWebSocket(w, r, sub, key, after)
+	} else {
+		streamSSE(w, r, sub, key, after)
+	}
+}
+
+// streamSSE streams the events of a cage as Server-Sent Events.
+func streamSSE(w http.ResponseWriter, r *http.Request, sub *stream.Subscription, key string, after *db.UUID) {
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("Failed to start event stream", "cage", key, "error", err)
+		return
+	}
+
+	send := func(event *cage.Event) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.UUID, event.Action, data); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	ping := func() error {
+		if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	// EventSource clients reconnect on their own once the response ends, and
+	// are refused if their key was revoked
+	err := streamEvents(r.Context(), sub, key, after, send, ping)
+	if err != nil && !errors.Is(err, errStreamReset) && !errors.Is(err, errKeyRevoked) {
+		slog.Error("Failed to stream events", "cage", key, "error", err)
+	}
+}
+
+// streamWebSocket streams the events of a cage over a WebSocket.
+func streamWebSocket(w http.ResponseWriter, r *http.Request, sub *stream.Subscription, key string, after *db.UUID) {
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		OriginPatterns: []string{"*"}, // Any origin, as with CORS
+	})
+	if err != nil {
+		slog.Error("Failed to accept WebSocket", "cage", key, "error", err)
+		return
+	}
+	defer conn.CloseNow()
+
+	// Clients only send control frames, handled while reading
+	ctx := conn.CloseRead(r.Context())
+
+	send := func(event *cage.Event) error {
+		ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+		defer cancel()
+		return wsjson.Write(ctx, conn, event)
+	}
+
+	ping := func() error {
+		ctx, cancel := context.WithTimeout(ctx, socketWriteTimeout)
+		defer cancel()
+		return conn.Ping(ctx)
+	}
+
+	err = streamEvents(ctx, sub, key, after, send, ping)
+	if errors.Is(err, errStreamReset) {
+		conn.Close(websocket.StatusTryAgainLater, "resume from the last event")
+		return
+	} else if errors.Is(err, errKeyRevoked) {
+		conn.Close(websocket.StatusPolicyViolation, "api key revoked")
+		return
+	} else if err != nil && ctx.Err() == nil {
+		slog.Error("Failed to stream events", "cage", key, "error", err)
+		conn.Close(websocket.StatusInternalError, "failed to stream events")
+		return
+	}
+
+	conn.Close(websocket.StatusNormalClosure, "")
+}
+
+// streamEvents calls send with each event of a cage as it is delivered to sub
+// until ctx is done, first replaying any events published after the given
+// event. ping is called while the stream is idle. Returns errStreamReset if
+// events may have been missed, after which the client should resume the
+// stream, or errKeyRevoked once the API key of the stream is revoked.
+func streamEvents(ctx context.Context, sub *stream.Subscription, key string, after *db.UUID, send func(*cage.Event) error, ping func() error) error {
+	replayed := make(map[db.UUID]bool)
+	if after != nil {
+		events, err := cage.ListEventsContext(ctx, key, *after)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := send(event); err != nil {
+				return err
+			}
+			replayed[event.UUID] = true
+		}
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	keyCheck := time.NewTicker(keyCheckInterval)
+	defer keyCheck.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-keyCheck.C:
+			if err := checkStreamKey(ctx, key); err != nil {
+				return err
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				return errStreamReset
+			}
+			if replayed[event.UUID] {
+				continue
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// checkStreamKey checks that the API key a stream was opened with, if any,
+// is still active and may read the cage. Returns errKeyRevoked if not.
+func checkStreamKey(ctx context.Context, cageKey string) error {
+	key := auth.FromContext(ctx)
+	if key == nil {
+		return nil
+	}
+
+	key, err := auth.GetActiveAPIKey(key.UUID)
+	if errors.Is(err, auth.ErrInvalidKey) {
+		return errKeyRevoked
+	} else if err != nil {
+		return err
+	}
+
+	if !auth.Allowed(key, auth.PermRead, cageKey) {
+		return errKeyRevoked
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package httphandle
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/octacian/backroom/api/config"
+)
+
+// untimedRoutes are long-lived routes, which only time out if configured for
+// their route in config.RC.Timeouts.Routes.
+var untimedRoutes = map[string]bool{
+	"GET /cage/{key}/export":  true,
+	"POST /cage/{key}/import": true,
+	"GET /cage/{key}/stream":  true,
+}
+
+// RouteTimeout returns the timeout of a route, identified by its method and
+// pattern as registered with the router. Zero means no timeout.
+// See config.RC.Timeouts for configuration.
+func RouteTimeout(method, pattern string) time.Duration {
+	route := method + " " + pattern
+
+	// Configuration keys are not case sensitive
+	for key, timeout := range config.RC.Timeouts.Routes {
+		if strings.EqualFold(key, route) {
+			return timeout
+		}
+	}
+
+	if untimedRoutes[route] {
+		return 0
+	}
+	return config.RC.Timeouts.Default
+}
+
+// Timeout cancels the context of requests to a route once they have run for
+// its RouteTimeout. Handlers pass the context to the database and hooks, so
+// stop work which has run too long and respond as they would to any other
+// failure. The response itself is left unbuffered, so that handlers may still
+// flush or hijack it.
+func Timeout(method, pattern string) func(http.Handler) http.Handler {
+	timeout := RouteTimeout(method, pattern)
+	return func(next http.Handler) http.Handler {
+		if timeout == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
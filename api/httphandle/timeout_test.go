@@ -0,0 +1,33 @@
+package httphandle
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/config"
+)
+
+func TestRouteTimeout(t *testing.T) {
+	prev := config.RC.Timeouts
+	config.RC.Timeouts.Default = 30 * time.Second
+	config.RC.Timeouts.Routes = map[string]time.Duration{"get /cage/{key}/export": time.Hour}
+	t.Cleanup(func() { config.RC.Timeouts = prev })
+
+	tests := []struct {
+		method  string
+		pattern string
+		want    time.Duration
+	}{
+		{http.MethodGet, "/record/{uuid}", 30 * time.Second},
+		{http.MethodPost, "/cage/{key}/import", 0},
+		{http.MethodGet, "/cage/{key}/stream", 0},
+		{http.MethodGet, "/cage/{key}/export", time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := RouteTimeout(tt.method, tt.pattern); got != tt.want {
+			t.Errorf("RouteTimeout(%s %s) = %v, want %v", tt.method, tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package httphandle
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/octacian/backroom/api/auth"
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/hook"
+)
+
+// HandleListTrash handles the retrieval of the trashed records of a cage.
+// Expects the key as a URL parameter. Returns the records as JSON, most
+// recently trashed first.
+func HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		http.Error(w, "Missing cage key", http.StatusBadRequest)
+		return
+	}
+
+	if !authorize(w, r, auth.PermRead, key) {
+		return
+	}
+
+	records, err := cage.ListTrashContext(r.Context(), key)
+	if err != nil {
+		http.Error(w, "Failed to retrieve records", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(responseListRecords{Records: records})
+}
+
+// HandleRestoreRecord handles taking a caged record out of the trash.
+// Expects the UUID as a URL parameter. Returns the restored record as JSON.
+func HandleRestoreRecord(w http.ResponseWriter, r *http.Request) {
+	uuid, err := db.ParseUUID(chi.URLParam(r, "uuid"))
+	if err != nil {
+		http.Error(w, "Invalid UUID format", http.StatusBadRequest)
+		return
+	}
+
+	trashed, err := cage.GetTrashedRecordContext(r.Context(), uuid)
+	if errors.Is(err, cage.ErrNotInTrash) {
+		http.Error(w, "Record not in trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to retrieve record", http.StatusInternalServerError)
+		return
+	}
+
+	if !authorize(w, r, auth.PermWrite, trashed.Cage) {
+		return
+	}
+
+	record, err := cage.RestoreRecordContext(r.Context(), uuid, requestActor(r))
+	if errors.Is(err, cage.ErrNotInTrash) {
+		http.Error(w, "Record not in trash", http.StatusNotFound)
+		return
+	} else if writeDuplicateError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to restore record", "uuid", uuid, "error", err)
+		http.Error(w, "Failed to restore record", http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(record)
+}
+
+// HandlePurgeTrash handles permanently deleting the trashed records of a
+// cage. Expects the key as a URL parameter and accepts an optional older_than
+// query parameter, only purging records trashed at or before that time.
+// Returns a success message as JSON.
+func HandlePurgeTrash(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if key == "" {
+		http.Error(w, "Missing cage key", http.StatusBadRequest)
+		return
+	}
+
+	before := time.Now()
+	if olderThan := r.URL.Query().Get("older_than"); olderThan != "" {
+		var err error
+		if before, err = cage.ParseTime(olderThan); err != nil {
+			http.Error(w, "Invalid older_than: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !authorize(w, r, auth.PermWrite, key) {
+		return
+	}
+
+	var purged []*cage.Record
+	err := db.TransactContext(r.Context(), func(tx *sql.Tx) error {
+		exec := db.WithContext(r.Context(), tx)
+		var err error
+		if purged, err = cage.PurgeTrashTx(exec, key, before, requestActor(r)); err != nil {
+			return err
+		}
+		// Run hooks for each record in the same transaction as the purge
+		for _, record := range purged {
+			if err := hook.RunHooksByAction(r.Context(), exec, hook.ActionPurge, record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if writeHookError(w, err) {
+		return
+	} else if err != nil {
+		slog.Error("Failed to purge records", "cage", key, "error", err)
+		http.Error(w, "Failed to purge records", http.StatusInternalServerError)
+		return
+	}
+
+	response := responseDelete{
+		Success: true,
+		Deleted: len(purged),
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,115 @@
+// Package idempotency stores the responses to requests made with an
+// idempotency key, so that retries of a request are answered with its
+// original response rather than being run again.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/octacian/backroom/api/.gen/backroom/public/model"
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+)
+
+var ErrKeyReused = errors.New("idempotency key reused with a different request")
+
+// MaxKeyLength is the length of the longest idempotency key accepted.
+const MaxKeyLength = 255
+
+// Response is the stored response to a request made with an idempotency key.
+// Wraps generated model.IdempotencyKey type.
+type Response = model.IdempotencyKey
+
+// NewResponse returns a response with the given status, naming the record it
+// created and storing body as JSON.
+func NewResponse(status int, recordUUID db.UUID, body any) (*Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonb db.JSONB
+	if err := json.Unmarshal(data, &jsonb); err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		RecordUUID: recordUUID,
+		Status:     int32(status),
+		Response:   jsonb,
+	}, nil
+}
+
+// Hash returns a digest identifying a request by its parts, such that a key
+// reused for a different request can be told apart from a retry.
+func Hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Run runs fn within a new transaction, storing the response it returns under
+// the actor and key in the same transaction, so that the response is kept if
+// and only if the writes made by fn are committed. The key is claimed before
+// fn runs, so a concurrent request made with the same key waits for this one
+// to end, then replays its response. If a response is already stored for the
+// key within the window, it is returned instead of running fn, along with
+// replayed set. Returns ErrKeyReused if the stored response is for a request
+// with a different hash. If key is empty, fn is simply run within a new
+// transaction. See config.RC.Idempotency for configuration.
+func Run(ctx context.Context, actor, key, hash string, fn func(tx *sql.Tx) (*Response, error)) (res *Response, replayed bool, err error) {
+	if key == "" {
+		err = db.TransactContext(ctx, func(tx *sql.Tx) error {
+			res, err = fn(tx)
+			return err
+		})
+		return res, false, err
+	}
+
+	cutoff := time.Now().Add(-config.RC.Idempotency.Window)
+	err = db.TransactContext(ctx, func(tx *sql.Tx) error {
+		exec := db.WithContext(ctx, tx)
+		if err := store.ClaimKey(exec, actor, key, cutoff); err != nil {
+			return err
+		}
+		if res, err = store.GetResponse(exec, actor, key, cutoff); err != nil || res != nil {
+			replayed = res != nil
+			return err
+		}
+
+		if res, err = fn(tx); err != nil {
+			return err
+		}
+
+		res.Actor = actor
+		res.Key = key
+		res.RequestHash = hash
+		res.CreatedAt = time.Now().UTC()
+		return store.PutResponse(exec, res)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if replayed && res.RequestHash != hash {
+		return nil, false, ErrKeyReused
+	}
+
+	if !replayed {
+		if err := store.PruneResponses(db.SQLDB, cutoff); err != nil {
+			slog.Error("Failed to prune idempotency keys", "error", err)
+		}
+	}
+
+	return res, replayed, nil
+}
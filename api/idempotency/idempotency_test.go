@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octacian/backroom/api/config"
+	"github.com/octacian/backroom/api/db"
+	"github.com/octacian/backroom/api/db/dbtest"
+)
+
+// openSQLite opens a throwaway database using the SQLite store, with keys
+// kept for an hour.
+func openSQLite(t *testing.T) {
+	t.Helper()
+	dbtest.Open(t, dbtest.Store(SetStore, Store(&SQLiteStore{})))
+	window := config.RC.Idempotency.Window
+	config.RC.Idempotency.Window = time.Hour
+	t.Cleanup(func() { config.RC.Idempotency.Window = window })
+}
+
+func TestSQLiteRunReplays(t *testing.T) {
+	openSQLite(t)
+	ctx := context.Background()
+
+	runs := 0
+	fn := func(tx *sql.Tx) (*Response, error) {
+		runs++
+		return NewResponse(http.StatusCreated, db.NewUUID(), map[string]int{"run": runs})
+	}
+
+	first, replayed, err := Run(ctx, "tester", "key", Hash([]byte("request")), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed {
+		t.Error("first run was replayed")
+	}
+
+	second, replayed, err := Run(ctx, "tester", "key", Hash([]byte("request")), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !replayed || runs != 1 || second.RecordUUID != first.RecordUUID {
+		t.Errorf("retry replayed = %v after %d runs, want the first response replayed", replayed, runs)
+	}
+
+	if _, _, err := Run(ctx, "tester", "key", Hash([]byte("other")), fn); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("different request error = %v, want ErrKeyReused", err)
+	}
+
+	// Keys are scoped to the actor using them
+	if _, replayed, err := Run(ctx, "other", "key", Hash([]byte("request")), fn); err != nil || replayed {
+		t.Errorf("another actor replayed = %v, error = %v, want a new run", replayed, err)
+	}
+}
+
+func TestSQLiteRunDiscardsFailures(t *testing.T) {
+	openSQLite(t)
+	ctx := context.Background()
+
+	failure := errors.New("failed")
+	_, _, err := Run(ctx, "tester", "key", Hash(), func(tx *sql.Tx) (*Response, error) {
+		return nil, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("error = %v, want the failure", err)
+	}
+
+	// A failed request stores nothing, so may be retried
+	_, replayed, err := Run(ctx, "tester", "key", Hash(), func(tx *sql.Tx) (*Response, error) {
+		return NewResponse(http.StatusCreated, db.NewUUID(), map[string]bool{"ok": true})
+	})
+	if err != nil || replayed {
+		t.Errorf("retry replayed = %v, error = %v, want a new run", replayed, err)
+	}
+}
+
+func TestSQLiteRunConcurrent(t *testing.T) {
+	openSQLite(t)
+	ctx := context.Background()
+
+	var runs atomic.Int32
+	fn := func(tx *sql.Tx) (*Response, error) {
+		runs.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		return NewResponse(http.StatusCreated, db.NewUUID(), map[string]bool{"ok": true})
+	}
+
+	var wg sync.WaitGroup
+	replays := make([]bool, 2)
+	for i := range replays {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, replayed, err := Run(ctx, "tester", "key", Hash([]byte("request")), fn)
+			if err != nil {
+				t.Error(err)
+			}
+			replays[i] = replayed
+		}()
+	}
+	wg.Wait()
+
+	// The key is claimed before running, so the later request replays
+	if runs.Load() != 1 || replays[0] == replays[1] {
+		t.Errorf("concurrent requests ran %d times, replayed %v, want one run and one replay", runs.Load(), replays)
+	}
+}
@@ -0,0 +1,69 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/postgres"
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/octacian/backroom/api/.gen/backroom/public/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// PostgresStore is a Store backed by the Postgres database.
+type PostgresStore struct{}
+
+// ClaimKey implements Store, taking an advisory lock on the key held until
+// the transaction ends. Keys sharing a hash merely wait on one another.
+func (s *PostgresStore) ClaimKey(exec db.Executor, actor, key string, cutoff time.Time) error {
+	if _, err := exec.Exec("SELECT pg_advisory_xact_lock(hashtext($1), hashtext($2))", actor, key); err != nil {
+		return err
+	}
+
+	expired := table.IdempotencyKey.DELETE().
+		WHERE(
+			table.IdempotencyKey.Actor.EQ(postgres.String(actor)).
+				AND(table.IdempotencyKey.Key.EQ(postgres.String(key))).
+				AND(table.IdempotencyKey.CreatedAt.LT_EQ(postgres.TimestampzT(cutoff))),
+		)
+
+	_, err := expired.Exec(exec)
+	return err
+}
+
+// GetResponse implements Store.
+func (s *PostgresStore) GetResponse(exec db.Executor, actor, key string, cutoff time.Time) (*Response, error) {
+	stmt := table.IdempotencyKey.SELECT(table.IdempotencyKey.AllColumns).
+		WHERE(
+			table.IdempotencyKey.Actor.EQ(postgres.String(actor)).
+				AND(table.IdempotencyKey.Key.EQ(postgres.String(key))).
+				AND(table.IdempotencyKey.CreatedAt.GT(postgres.TimestampzT(cutoff))),
+		)
+
+	var res Response
+	err := stmt.Query(exec, &res)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// PutResponse implements Store.
+func (s *PostgresStore) PutResponse(exec db.Executor, res *Response) error {
+	insert := table.IdempotencyKey.INSERT(table.IdempotencyKey.AllColumns).MODEL(res)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// PruneResponses implements Store.
+func (s *PostgresStore) PruneResponses(exec db.Executor, cutoff time.Time) error {
+	stmt := table.IdempotencyKey.DELETE().
+		WHERE(table.IdempotencyKey.CreatedAt.LT_EQ(postgres.TimestampzT(cutoff)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
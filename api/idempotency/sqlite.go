@@ -0,0 +1,65 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-jet/jet/v2/qrm"
+	"github.com/go-jet/jet/v2/sqlite"
+	"github.com/octacian/backroom/api/.gen/backroom/sqlite/table"
+	"github.com/octacian/backroom/api/db"
+)
+
+// SQLiteStore is a Store backed by an SQLite database.
+type SQLiteStore struct{}
+
+// ClaimKey implements Store. Transactions begin by taking the database write
+// lock, so the key is already held, and only expired responses are deleted.
+func (s *SQLiteStore) ClaimKey(exec db.Executor, actor, key string, cutoff time.Time) error {
+	expired := table.IdempotencyKey.DELETE().
+		WHERE(
+			table.IdempotencyKey.Actor.EQ(sqlite.String(actor)).
+				AND(table.IdempotencyKey.Key.EQ(sqlite.String(key))).
+				AND(table.IdempotencyKey.CreatedAt.LT_EQ(db.SQLiteTime(cutoff))),
+		)
+
+	_, err := expired.Exec(exec)
+	return err
+}
+
+// GetResponse implements Store.
+func (s *SQLiteStore) GetResponse(exec db.Executor, actor, key string, cutoff time.Time) (*Response, error) {
+	stmt := table.IdempotencyKey.SELECT(table.IdempotencyKey.AllColumns).
+		WHERE(
+			table.IdempotencyKey.Actor.EQ(sqlite.String(actor)).
+				AND(table.IdempotencyKey.Key.EQ(sqlite.String(key))).
+				AND(table.IdempotencyKey.CreatedAt.GT(db.SQLiteTime(cutoff))),
+		)
+
+	var res Response
+	err := stmt.Query(exec, &res)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// PutResponse implements Store.
+func (s *SQLiteStore) PutResponse(exec db.Executor, res *Response) error {
+	insert := table.IdempotencyKey.INSERT(table.IdempotencyKey.AllColumns).MODEL(res)
+
+	_, err := insert.Exec(exec)
+	return err
+}
+
+// PruneResponses implements Store.
+func (s *SQLiteStore) PruneResponses(exec db.Executor, cutoff time.Time) error {
+	stmt := table.IdempotencyKey.DELETE().
+		WHERE(table.IdempotencyKey.CreatedAt.LT_EQ(db.SQLiteTime(cutoff)))
+
+	_, err := stmt.Exec(exec)
+	return err
+}
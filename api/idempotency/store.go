@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"time"
+
+	"github.com/octacian/backroom/api/db"
+)
+
+// Store persists the responses to requests made with an idempotency key. Each
+// method runs on the given executor, so that responses may be stored in the
+// same transaction as the writes they describe.
+type Store interface {
+	// ClaimKey takes a key for the rest of the transaction, waiting for any
+	// concurrent transaction which claimed it to end, then deletes any
+	// response stored for it at or before cutoff.
+	ClaimKey(exec db.Executor, actor, key string, cutoff time.Time) error
+	// GetResponse retrieves the response stored for a key after cutoff.
+	// Returns nil if there is no such response.
+	GetResponse(exec db.Executor, actor, key string, cutoff time.Time) (*Response, error)
+	// PutResponse stores a response for a key claimed with ClaimKey.
+	PutResponse(exec db.Executor, res *Response) error
+	// PruneResponses deletes responses stored at or before cutoff.
+	PruneResponses(exec db.Executor, cutoff time.Time) error
+}
+
+// store is the Store used by the package functions.
+var store Store = &PostgresStore{}
+
+// InitStore selects the Store matching the configured database driver.
+func InitStore() {
+	if db.IsSQLite() {
+		store = &SQLiteStore{}
+	} else {
+		store = &PostgresStore{}
+	}
+}
+
+// SetStore replaces the Store used by the package functions, returning the
+// previous one.
+func SetStore(s Store) Store {
+	prev := store
+	store = s
+	return prev
+}
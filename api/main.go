@@ -7,9 +7,6 @@ import (
 
 	"github.com/lmittmann/tint"
 	"github.com/octacian/backroom/api/cmd"
-	"github.com/octacian/backroom/api/config"
-	"github.com/octacian/backroom/api/db"
-	"github.com/octacian/backroom/api/hook"
 	slogmulti "github.com/samber/slog-multi"
 )
 
@@ -33,16 +30,6 @@ func main() {
 
 	slog.SetDefault(logger)
 
-	// Initialize configuration
-	config.Init()
-
-	// Initialize database connection
-	db.InitDB()
-	defer db.CloseDB()
-
-	// Initialize hook adapters
-	hook.InitAdapters()
-
 	// Initialize command line interface
 	cmd.Execute()
 }
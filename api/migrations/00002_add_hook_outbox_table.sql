@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS hook_outbox (
+	id BIGSERIAL NOT NULL PRIMARY KEY,
+	hook_id VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	record_uuid char(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	data JSONB NOT NULL,
+	status VARCHAR(32) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS hook_outbox_pending ON hook_outbox (run_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS hook_outbox_record_uuid ON hook_outbox (record_uuid);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS hook_outbox;
+
+DROP INDEX IF EXISTS hook_outbox_pending;
+
+DROP INDEX IF EXISTS hook_outbox_record_uuid;
+
+-- +goose StatementEnd
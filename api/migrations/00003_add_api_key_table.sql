@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS api_key (
+	uuid char(27) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	hash char(64) NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_hash ON api_key (hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_active_name ON api_key (name) WHERE revoked_at IS NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_key;
+
+DROP INDEX IF EXISTS api_key_hash;
+
+DROP INDEX IF EXISTS api_key_active_name;
+
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE record ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+
+ALTER TABLE record ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
+
+-- Backfill existing records from the creation time embedded in their KSUID:
+-- the base62 encoded UUID decodes to a 160-bit integer, the top 32 bits of
+-- which are seconds since the KSUID epoch (1400000000).
+UPDATE record SET created_at = ksuid.created_at, updated_at = ksuid.created_at
+FROM (
+	SELECT uuid, to_timestamp(1400000000 + div(sum(
+		(strpos('0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz', substr(uuid, i, 1)) - 1)::numeric
+		* power(62::numeric, 27 - i)
+	), power(2::numeric, 128))) AS created_at
+	FROM record, generate_series(1, 27) AS i
+	GROUP BY uuid
+) AS ksuid
+WHERE record.uuid = ksuid.uuid;
+
+ALTER TABLE record ALTER COLUMN created_at SET DEFAULT NOW(), ALTER COLUMN created_at SET NOT NULL;
+
+ALTER TABLE record ALTER COLUMN updated_at SET DEFAULT NOW(), ALTER COLUMN updated_at SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS record_cage_created_at ON record (cage, created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS record_cage_created_at;
+
+ALTER TABLE record DROP COLUMN IF EXISTS created_at;
+
+ALTER TABLE record DROP COLUMN IF EXISTS updated_at;
+
+-- +goose StatementEnd
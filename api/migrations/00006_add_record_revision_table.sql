@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS record_revision (
+	id BIGSERIAL NOT NULL PRIMARY KEY,
+	record_uuid char(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	revision INTEGER NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	data JSONB NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS record_revision_record_uuid_revision ON record_revision (record_uuid, revision);
+
+-- Seed the history of existing records with their current data, as the
+-- actor responsible is unknown
+INSERT INTO record_revision (record_uuid, cage, revision, action, data, created_at)
+SELECT uuid, cage, 1, 'create', data, updated_at FROM record;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS record_revision;
+
+DROP INDEX IF EXISTS record_revision_record_uuid_revision;
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE record ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS record_trash ON record (cage, deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS record_trash;
+
+ALTER TABLE record DROP COLUMN IF EXISTS deleted_at;
+
+-- +goose StatementEnd
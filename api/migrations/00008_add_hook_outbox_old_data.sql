@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE hook_outbox ADD COLUMN IF NOT EXISTS old_data JSONB;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE hook_outbox DROP COLUMN IF EXISTS old_data;
+
+-- +goose StatementEnd
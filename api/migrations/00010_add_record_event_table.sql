@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS record_event (
+	uuid char(27) NOT NULL PRIMARY KEY,
+	record_uuid char(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	version INTEGER NOT NULL,
+	data JSONB NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS record_event_cage_uuid ON record_event (cage, uuid);
+
+CREATE INDEX IF NOT EXISTS record_event_created_at ON record_event (created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS record_event;
+
+DROP INDEX IF EXISTS record_event_cage_uuid;
+
+DROP INDEX IF EXISTS record_event_created_at;
+
+-- +goose StatementEnd
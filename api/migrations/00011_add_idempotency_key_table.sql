@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_key (
+	actor VARCHAR(255) NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	request_hash CHAR(64) NOT NULL,
+	record_uuid char(27) NOT NULL,
+	status INTEGER NOT NULL,
+	response JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (actor, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_key_created_at ON idempotency_key (created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_key;
+
+DROP INDEX IF EXISTS idempotency_key_created_at;
+
+-- +goose StatementEnd
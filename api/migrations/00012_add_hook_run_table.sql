@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS hook_run (
+	id BIGSERIAL NOT NULL PRIMARY KEY,
+	hook_id VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	adapter VARCHAR(32) NOT NULL,
+	record_uuid char(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	data JSONB NOT NULL,
+	old_data JSONB,
+	status VARCHAR(32) NOT NULL,
+	attempt INTEGER NOT NULL,
+	duration_ms BIGINT NOT NULL,
+	error TEXT,
+	replay_of BIGINT,
+	started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS hook_run_record_uuid ON hook_run (record_uuid);
+
+CREATE INDEX IF NOT EXISTS hook_run_status ON hook_run (status, started_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS hook_run;
+
+DROP INDEX IF EXISTS hook_run_record_uuid;
+
+DROP INDEX IF EXISTS hook_run_status;
+
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE record_event ADD COLUMN seq BIGINT;
+
+UPDATE record_event SET seq = ordered.seq
+FROM (SELECT uuid, ROW_NUMBER() OVER (ORDER BY uuid) AS seq FROM record_event) AS ordered
+WHERE record_event.uuid = ordered.uuid;
+
+CREATE SEQUENCE IF NOT EXISTS record_event_seq_seq OWNED BY record_event.seq;
+
+SELECT setval('record_event_seq_seq', COALESCE(MAX(seq), 0) + 1, false) FROM record_event;
+
+ALTER TABLE record_event
+	ALTER COLUMN seq SET DEFAULT nextval('record_event_seq_seq'),
+	ALTER COLUMN seq SET NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS record_event_seq ON record_event (seq);
+
+CREATE INDEX IF NOT EXISTS record_event_cage_seq ON record_event (cage, seq);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS record_event_cage_seq;
+
+DROP INDEX IF EXISTS record_event_seq;
+
+ALTER TABLE record_event DROP COLUMN IF EXISTS seq;
+
+-- +goose StatementEnd
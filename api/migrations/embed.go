@@ -2,5 +2,8 @@ package migrations
 
 import "embed"
 
-//go:embed *.sql
+// Migrations holds the Postgres migrations, along with the SQLite migrations
+// in the sqlite directory.
+//
+//go:embed *.sql sqlite/*.sql
 var Migrations embed.FS
@@ -0,0 +1,101 @@
+-- SQLite support arrived with version 10 of the Postgres schema, so the SQLite
+-- schema starts there, matching it table for table. Later migrations must be
+-- added for both databases under the same version.
+--
+-- JSON is stored as text for use with the JSON1 functions, and times as text
+-- in the form written by the driver, which sorts chronologically in UTC.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS record (
+	uuid CHAR(27) NOT NULL PRIMARY KEY,
+	cage VARCHAR(255) NOT NULL,
+	data TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	deleted_at TIMESTAMP,
+	version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS record_cage ON record (cage);
+
+CREATE INDEX IF NOT EXISTS record_cage_created_at ON record (cage, created_at);
+
+CREATE INDEX IF NOT EXISTS record_trash ON record (cage, deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS hook_outbox (
+	id INTEGER NOT NULL PRIMARY KEY,
+	hook_id VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	record_uuid CHAR(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	data TEXT NOT NULL,
+	status VARCHAR(32) NOT NULL DEFAULT 'pending',
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	run_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	old_data TEXT
+);
+
+CREATE INDEX IF NOT EXISTS hook_outbox_pending ON hook_outbox (run_at) WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS hook_outbox_record_uuid ON hook_outbox (record_uuid);
+
+CREATE TABLE IF NOT EXISTS api_key (
+	uuid CHAR(27) NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	hash CHAR(64) NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_hash ON api_key (hash);
+
+CREATE UNIQUE INDEX IF NOT EXISTS api_key_active_name ON api_key (name) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS record_revision (
+	id INTEGER NOT NULL PRIMARY KEY,
+	record_uuid CHAR(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	revision INTEGER NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	data TEXT NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS record_revision_record_uuid_revision ON record_revision (record_uuid, revision);
+
+CREATE TABLE IF NOT EXISTS record_event (
+	uuid CHAR(27) NOT NULL PRIMARY KEY,
+	record_uuid CHAR(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	version INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS record_event_cage_uuid ON record_event (cage, uuid);
+
+CREATE INDEX IF NOT EXISTS record_event_created_at ON record_event (created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS record_event;
+
+DROP TABLE IF EXISTS record_revision;
+
+DROP TABLE IF EXISTS api_key;
+
+DROP TABLE IF EXISTS hook_outbox;
+
+DROP TABLE IF EXISTS record;
+
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS idempotency_key (
+	actor VARCHAR(255) NOT NULL,
+	key VARCHAR(255) NOT NULL,
+	request_hash CHAR(64) NOT NULL,
+	record_uuid CHAR(27) NOT NULL,
+	status INTEGER NOT NULL,
+	response TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	PRIMARY KEY (actor, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_key_created_at ON idempotency_key (created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_key;
+
+-- +goose StatementEnd
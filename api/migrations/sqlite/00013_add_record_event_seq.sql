@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE record_event_new (
+	uuid CHAR(27) NOT NULL UNIQUE,
+	record_uuid CHAR(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	version INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	seq INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT
+);
+
+INSERT INTO record_event_new (uuid, record_uuid, cage, action, version, data, actor, created_at)
+SELECT uuid, record_uuid, cage, action, version, data, actor, created_at FROM record_event ORDER BY uuid;
+
+DROP TABLE record_event;
+
+ALTER TABLE record_event_new RENAME TO record_event;
+
+CREATE INDEX IF NOT EXISTS record_event_cage_seq ON record_event (cage, seq);
+
+CREATE INDEX IF NOT EXISTS record_event_created_at ON record_event (created_at);
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE record_event_old (
+	uuid CHAR(27) NOT NULL PRIMARY KEY,
+	record_uuid CHAR(27) NOT NULL,
+	cage VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	version INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	actor VARCHAR(255),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+INSERT INTO record_event_old (uuid, record_uuid, cage, action, version, data, actor, created_at)
+SELECT uuid, record_uuid, cage, action, version, data, actor, created_at FROM record_event;
+
+DROP TABLE record_event;
+
+ALTER TABLE record_event_old RENAME TO record_event;
+
+CREATE INDEX IF NOT EXISTS record_event_cage_uuid ON record_event (cage, uuid);
+
+CREATE INDEX IF NOT EXISTS record_event_created_at ON record_event (created_at);
+
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX IF NOT EXISTS hook_run_cage ON hook_run (cage, id);
+
+CREATE INDEX IF NOT EXISTS hook_run_replay_of ON hook_run (replay_of) WHERE replay_of IS NOT NULL;
+
+-- +goose StatementEnd
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS hook_run_replay_of;
+
+DROP INDEX IF EXISTS hook_run_cage;
+
+-- +goose StatementEnd
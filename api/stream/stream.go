@@ -0,0 +1,186 @@
+// Package stream delivers record events to subscribers as they are published
+// by any process sharing the database, as watched for by cage.WatchEvents.
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/octacian/backroom/api/cage"
+	"github.com/octacian/backroom/api/config"
+)
+
+// subscriptionBuffer is the number of events held for a subscriber which is
+// yet to receive them. Subscribers which fall further behind are dropped.
+const subscriptionBuffer = 256
+
+// pruneInterval is how often events beyond their retention are deleted.
+const pruneInterval = 10 * time.Minute
+
+// retryInterval is how long to wait before watching for events again after
+// watching failed.
+const retryInterval = 5 * time.Second
+
+// ErrUnavailable is returned by Subscribe while events aren't being watched
+// for, e.g. because the database can't be reached.
+var ErrUnavailable = errors.New("event stream unavailable")
+
+// Subscription receives the events of a cage. Its channel is closed if the
+// subscriber falls behind or events may have been missed, after which the
+// subscriber should resume from the last event it received.
+type Subscription struct {
+	cage   string
+	events chan *cage.Event
+	closed bool
+}
+
+// Events returns the channel on which events are delivered.
+func (s *Subscription) Events() <-chan *cage.Event {
+	return s.events
+}
+
+// Close stops delivery to the subscription.
+func (s *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	s.close()
+}
+
+// close removes the subscription and closes its channel. Callers must hold mu.
+func (s *Subscription) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(subscriptions, s)
+	close(s.events)
+}
+
+var (
+	mu            sync.Mutex
+	subscriptions = make(map[*Subscription]struct{})
+	watching      bool // Whether Run is watching for events
+)
+
+// Subscribe starts delivering the events of a cage as they are published.
+// Returns ErrUnavailable unless Run is watching for events.
+func Subscribe(cageKey string) (*Subscription, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !watching {
+		return nil, ErrUnavailable
+	}
+
+	sub := &Subscription{cage: cageKey, events: make(chan *cage.Event, subscriptionBuffer)}
+	subscriptions[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Run watches for events and delivers them to subscribers until ctx is
+// cancelled, pruning events beyond their retention as it goes. Watching is
+// retried if it fails, closing every subscription in the meantime. See
+// config.RC.Stream for configuration.
+func Run(ctx context.Context) {
+	slog.Info("Event stream started", "driver", config.RC.Database.Driver)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		prune := time.NewTicker(pruneInterval)
+		defer prune.Stop()
+		pruneEvents()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-prune.C:
+				pruneEvents()
+			}
+		}
+	}()
+
+	for {
+		err := cage.WatchEvents(ctx, func(event *cage.Event) {
+			if event == nil {
+				resume()
+				return
+			}
+			deliver(event)
+		})
+		stop()
+		if ctx.Err() != nil {
+			break
+		}
+
+		slog.Error("Failed to watch for record events", "error", err, "retry", retryInterval)
+		select {
+		case <-ctx.Done():
+		case <-time.After(retryInterval):
+		}
+	}
+
+	<-done
+	slog.Info("Event stream stopped")
+}
+
+// resume marks events as being watched for, closing every subscription
+// opened before now as events may have been missed.
+func resume() {
+	mu.Lock()
+	defer mu.Unlock()
+	if watching && len(subscriptions) > 0 {
+		slog.Warn("Record events may have been missed, resetting subscriptions")
+	}
+	watching = true
+	closeAll()
+}
+
+// stop marks events as no longer being watched for, closing every
+// subscription.
+func stop() {
+	mu.Lock()
+	defer mu.Unlock()
+	watching = false
+	closeAll()
+}
+
+// deliver sends an event to subscribers of its cage.
+func deliver(event *cage.Event) {
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range subscriptions {
+		if sub.cage != event.Cage {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			slog.Warn("Dropping slow event subscriber", "cage", sub.cage)
+			sub.close()
+		}
+	}
+}
+
+// closeAll closes every subscription. Callers must hold mu.
+func closeAll() {
+	for sub := range subscriptions {
+		sub.close()
+	}
+}
+
+// pruneEvents deletes events older than the configured retention.
+func pruneEvents() {
+	count, err := cage.PruneEvents(time.Now().Add(-config.RC.Stream.Retention))
+	if err != nil {
+		slog.Error("Failed to prune events", "error", err)
+	} else if count > 0 {
+		slog.Info("Pruned events", "count", count)
+	}
+}
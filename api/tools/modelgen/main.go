@@ -9,11 +9,14 @@ import (
 
 	"github.com/go-jet/jet/v2/generator/metadata"
 	"github.com/go-jet/jet/v2/generator/postgres"
+	"github.com/go-jet/jet/v2/generator/sqlite"
 	"github.com/go-jet/jet/v2/generator/template"
 	postgres2 "github.com/go-jet/jet/v2/postgres"
+	sqlite2 "github.com/go-jet/jet/v2/sqlite"
 	_ "github.com/lib/pq"
 	"github.com/octacian/backroom/api/config"
 	"github.com/octacian/backroom/api/db"
+	"github.com/pressly/goose/v3"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +31,11 @@ var rootCmd = &cobra.Command{
 		// initialize configuration
 		config.Init()
 
+		if db.IsSQLite() {
+			generateSQLite()
+			return
+		}
+
 		split := strings.Split(config.RC.Database.Host, ":")
 		if len(split) != 2 {
 			fmt.Printf("Invalid database path: %s\n", config.RC.Database.Host)
@@ -59,7 +67,7 @@ var rootCmd = &cobra.Command{
 								UseField(func(column metadata.Column) template.TableModelField {
 									defaultTableModelField := template.DefaultTableModelField(column)
 
-									if column.Name == "uuid" {
+									if column.Name == "uuid" || strings.HasSuffix(column.Name, "_uuid") {
 										defaultTableModelField.Type = template.NewType(db.UUID{})
 									} else if column.DataType.Name == "jsonb" {
 										defaultTableModelField.Type = template.NewType(db.JSONB{})
@@ -82,6 +90,32 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// generateSQLite generates JET table files from the SQLite schema. Models are
+// shared with the Postgres schema, so aren't generated.
+func generateSQLite() {
+	db.InitDB()
+	defer db.CloseDB()
+
+	if err := goose.Up(db.SQLDB, db.MigrationsDir()); err != nil {
+		slog.Error("Couldn't migrate SQLite database", "err", err)
+		return
+	}
+
+	model := template.DefaultModel()
+	model.Skip = true
+
+	tmpl := template.Default(sqlite2.Dialect).
+		UseSchema(func(schema metadata.Schema) template.Schema {
+			return template.DefaultSchema(schema).
+				UsePath("backroom/sqlite").
+				UseModel(model)
+		})
+
+	if err := sqlite.GenerateDB(db.SQLDB, "./.gen", tmpl); err != nil {
+		slog.Error("Couldn't generate jet table files", "err", err)
+	}
+}
+
 func execute() {
 	if err := rootCmd.Execute(); err != nil {
 		slog.Error("Couldn't execute root command", "err", err)